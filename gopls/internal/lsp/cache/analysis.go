@@ -12,6 +12,7 @@ import (
 	"crypto/sha256"
 	"encoding/gob"
 	"errors"
+	"flag"
 	"fmt"
 	"go/ast"
 	"go/token"
@@ -212,7 +213,9 @@ func (s *snapshot) Analyze(ctx context.Context, id PackageID, analyzers []*sourc
 	}
 
 	// Report diagnostics only from enabled actions that succeeded.
-	// Errors from creating or analyzing packages are ignored.
+	// Errors from creating or analyzing packages are ignored, except
+	// that a panic in an enabled analyzer is reported as a diagnostic,
+	// so that the user knows why its diagnostics are missing.
 	// Diagnostics are reported in the order of the analyzers argument.
 	//
 	// TODO(adonovan): ignoring action errors gives the caller no way
@@ -224,6 +227,12 @@ func (s *snapshot) Analyze(ctx context.Context, id PackageID, analyzers []*sourc
 	var results []*source.Diagnostic
 	for _, a := range enabled {
 		summary := res.Actions[a.Name]
+		if summary.Panic != "" {
+			if diag := analyzerPanicDiagnostic(s.Metadata(id), a.Name, summary.Panic); diag != nil {
+				results = append(results, diag)
+			}
+			continue
+		}
 		if summary.Err != "" {
 			continue // action failed
 		}
@@ -234,6 +243,22 @@ func (s *snapshot) Analyze(ctx context.Context, id PackageID, analyzers []*sourc
 	return results, nil
 }
 
+// analyzerPanicDiagnostic returns the diagnostic reporting that the named
+// analyzer panicked on the package m with the given recovered value. The
+// diagnostic is placed at the start of the package's first file, or is nil if
+// the package has no files.
+func analyzerPanicDiagnostic(m *source.Metadata, analyzer, value string) *source.Diagnostic {
+	if m == nil || len(m.CompiledGoFiles) == 0 {
+		return nil
+	}
+	return &source.Diagnostic{
+		URI:      m.CompiledGoFiles[0],
+		Severity: protocol.SeverityWarning,
+		Source:   source.AnalyzerErrorKind(analyzer),
+		Message:  fmt.Sprintf("analyzer %s crashed, so its diagnostics are missing: %s", analyzer, value),
+	}
+}
+
 // analysisKey is the type of keys in the snapshot.analyses map.
 type analysisKey struct {
 	analyzerNames string
@@ -301,6 +326,18 @@ type actionSummary struct {
 	FactsHash   source.Hash // hash(Facts)
 	Diagnostics []gobDiagnostic
 	Err         string // "" => success
+	Panic       string // if the analyzer itself panicked, the recovered value
+}
+
+// An analyzerPanic is the error of an action whose analyzer panicked.
+type analyzerPanic struct {
+	analyzer string      // the name of the analyzer
+	pkgPath  string      // the path of the package being analyzed
+	value    interface{} // the recovered value
+}
+
+func (p *analyzerPanic) Error() string {
+	return fmt.Sprintf("analysis %s for package %s panicked: %v", p.analyzer, p.pkgPath, p.value)
 }
 
 // analyze is a memoization of analyzeImpl.
@@ -343,6 +380,7 @@ func (s *snapshot) analyze(ctx context.Context, id PackageID, analyzers []*analy
 		s.analyses.Set(key, entry, nil) // nothing needs releasing
 	}
 	s.mu.Unlock()
+	s.view.stats.record(&s.view.stats.analysisHits, &s.view.stats.analysisMisses, hit)
 
 	// Await result.
 	ap := entry.(analysisPromise)
@@ -490,7 +528,7 @@ func analyzeImpl(ctx context.Context, snapshot *snapshot, analyzers []*analysis.
 
 // analysisCacheKey returns a cache key that is a cryptographic digest
 // of the all the values that might affect type checking and analysis:
-// the analyzer names, package metadata, names and contents of
+// the analyzer names and flags, package metadata, names and contents of
 // compiled Go files, and vdeps information (export data and facts).
 //
 // TODO(adonovan): safety: define our own flavor of Metadata
@@ -503,10 +541,13 @@ func analysisCacheKey(analyzers []*analysis.Analyzer, m *source.Metadata, compil
 	// unambiguous encoding of all the relevant data.
 	// If it's ambiguous, we risk collisons.
 
-	// analyzers
+	// analyzers, and the values of their flags
 	fmt.Fprintf(hasher, "analyzers: %d\n", len(analyzers))
 	for _, a := range analyzers {
 		fmt.Fprintln(hasher, a.Name)
+		a.Flags.VisitAll(func(f *flag.Flag) {
+			fmt.Fprintf(hasher, "flag %s=%s\n", f.Name, f.Value)
+		})
 	}
 
 	// package metadata
@@ -901,6 +942,10 @@ func execActions(actions []*action) {
 				act.result, act.summary, act.err = act.exec()
 				if act.err != nil {
 					act.summary = &actionSummary{Err: act.err.Error()}
+					var p *analyzerPanic
+					if errors.As(act.err, &p) && p.analyzer == act.a.Name {
+						act.summary.Panic = fmt.Sprint(p.value)
+					}
 					// TODO(adonovan): suppress logging. But
 					// shouldn't the root error's causal chain
 					// include this information?
@@ -1097,7 +1142,7 @@ func (act *action) exec() (interface{}, *actionSummary, error) {
 					panic(r)
 				} else {
 					// In production, suppress the panic and press on.
-					err = fmt.Errorf("analysis %s for package %s panicked: %v", analyzer.Name, pass.Pkg.Path(), r)
+					err = &analyzerPanic{analyzer.Name, pass.Pkg.Path(), r}
 				}
 			}
 		}()
@@ -0,0 +1,164 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/lsp/source"
+	"golang.org/x/tools/gopls/internal/span"
+	"golang.org/x/tools/internal/bug"
+	"golang.org/x/tools/internal/facts"
+	"golang.org/x/tools/internal/testenv"
+)
+
+func TestAnalysisCacheKeyFlags(t *testing.T) {
+	a := &analysis.Analyzer{Name: "a"}
+	a.Flags.Bool("strict", false, "")
+	m := &source.Metadata{ID: "p", Name: "p", PkgPath: "p", TypesSizes: &types.StdSizes{WordSize: 8, MaxAlign: 8}}
+
+	before := analysisCacheKey([]*analysis.Analyzer{a}, m, nil, nil)
+	if err := a.Flags.Set("strict", "true"); err != nil {
+		t.Fatal(err)
+	}
+	if after := analysisCacheKey([]*analysis.Analyzer{a}, m, nil, nil); after == before {
+		t.Errorf("analysisCacheKey did not change with the value of a flag")
+	}
+}
+
+func TestInvalidateAnalysesOnOptionsChange(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	folder := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(folder, "go.mod"), []byte("module example.com\n\ngo 1.18\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(folder, "a.go"), []byte("package a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	session := NewSession(ctx, New(nil, nil), nil)
+	defer session.Shutdown(context.Background())
+	options := source.DefaultOptions().Clone()
+	options.Env = map[string]string{"GOPACKAGESDRIVER": "off", "GOROOT": ""}
+	view, before, release, err := session.NewView(ctx, "test", span.URIFromPath(folder), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	active, err := before.ActiveMetadata(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(active) != 1 {
+		t.Fatalf("got %d active packages, want 1", len(active))
+	}
+	id := active[0].ID
+	// Record analysis results directly, as if the analyzers had run.
+	for _, names := range []string{"assign", "assign,bools"} {
+		before.(*snapshot).analyses.Set(analysisKey{names, id}, nil, nil)
+	}
+	if _, err := before.TypeCheck(ctx, source.TypecheckFull, id); err != nil {
+		t.Fatal(err)
+	}
+
+	// Disable the bools analyzer.
+	modified := options.Clone()
+	modified.Analyses = map[string]bool{"bools": false}
+	newView, err := session.SetViewOptions(ctx, view, modified)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newView != view {
+		t.Fatalf("SetViewOptions replaced the view, want the analyses of the view invalidated")
+	}
+	s, release2 := view.Snapshot(ctx)
+	defer release2()
+	if s == before {
+		t.Fatal("SetViewOptions did not replace the snapshot of the view")
+	}
+	after := s.(*snapshot)
+	analyses := after.analyses
+	if _, ok := analyses.Get(analysisKey{"assign,bools", id}); ok {
+		t.Errorf("result of bools analyzer is still cached")
+	}
+	if _, ok := analyses.Get(analysisKey{"assign", id}); !ok {
+		t.Errorf("result of assign analyzer was discarded")
+	}
+	var packages int
+	after.packages.Range(func(_, _ interface{}) { packages++ })
+	if packages == 0 {
+		t.Errorf("type-checked packages were discarded")
+	}
+}
+
+func TestAnalyzerPanic(t *testing.T) {
+	defer func(panicOnBugs bool) { bug.PanicOnBugs = panicOnBugs }(bug.PanicOnBugs)
+	bug.PanicOnBugs = false
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", "package p\n", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{}
+	tpkg, err := new(types.Config).Check("p", fset, []*ast.File{f}, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := &analysisPackage{
+		m:            &source.Metadata{ID: "p", Name: "p", PkgPath: "p"},
+		fset:         fset,
+		files:        []*ast.File{f},
+		types:        tpkg,
+		compiles:     true,
+		factsDecoder: facts.NewDecoder(tpkg),
+		typesInfo:    info,
+		typesSizes:   types.SizesFor("gc", "amd64"),
+	}
+
+	crash := &analysis.Analyzer{
+		Name: "crash",
+		Run:  func(*analysis.Pass) (interface{}, error) { panic("boom") },
+	}
+	user := &analysis.Analyzer{
+		Name:     "user",
+		Requires: []*analysis.Analyzer{crash},
+		Run:      func(*analysis.Pass) (interface{}, error) { return nil, nil },
+	}
+	crashAct := &action{a: crash, pkg: pkg}
+	userAct := &action{a: user, pkg: pkg, hdeps: []*action{crashAct}}
+	execActions([]*action{userAct})
+
+	if got := crashAct.summary; got == nil || got.Panic != "boom" || got.Err == "" {
+		t.Errorf("summary of panicking analyzer = %+v, want Panic %q and an error", got, "boom")
+	}
+	// The failure of a dependency is not a panic of the dependent analyzer.
+	if got := userAct.summary; got == nil || got.Panic != "" || got.Err == "" {
+		t.Errorf("summary of dependent analyzer = %+v, want an error but no panic", got)
+	}
+
+	uri := span.URIFromPath(filepath.FromSlash("/src/p/p.go"))
+	m := &source.Metadata{ID: "p", CompiledGoFiles: []span.URI{uri}}
+	diag := analyzerPanicDiagnostic(m, "crash", "boom")
+	if diag == nil || diag.URI != uri || diag.Source != "crash" || diag.Severity != protocol.SeverityWarning {
+		t.Errorf("analyzerPanicDiagnostic = %+v, want a warning from crash in %s", diag, uri)
+	}
+	if diag := analyzerPanicDiagnostic(&source.Metadata{ID: "p"}, "crash", "boom"); diag != nil {
+		t.Errorf("analyzerPanicDiagnostic for a package without files = %+v, want nil", diag)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/types/objectpath"
+	"golang.org/x/tools/gopls/internal/lsp/source"
+)
+
+// ExportedAPI returns the exported declarations of the package with the
+// given id.
+//
+// Only the exported declarations are needed, so the package is type-checked
+// in ParseExported mode, which is cheaper than a full type check.
+func (s *snapshot) ExportedAPI(ctx context.Context, id PackageID) ([]source.ExportedDecl, error) {
+	ph, err := s.buildPackageHandle(ctx, id, source.ParseExported)
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := ph.await(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	return exportedDecls(pkg.GetTypes()), nil
+}
+
+// exportedDecls returns the exported package-level objects of pkg, each
+// followed by the exported methods and fields of its type, if it is a type.
+func exportedDecls(pkg *types.Package) []source.ExportedDecl {
+	var decls []source.ExportedDecl
+	qual := types.RelativeTo(pkg)
+	add := func(kind string, obj types.Object) {
+		path, err := objectpath.For(obj)
+		if err != nil {
+			return // e.g. a method of an unexported embedded type
+		}
+		decls = append(decls, source.ExportedDecl{
+			Path:      path,
+			Kind:      kind,
+			Signature: exportedSignature(obj, qual),
+		})
+	}
+
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		if !obj.Exported() {
+			continue
+		}
+		switch obj := obj.(type) {
+		case *types.Const:
+			add("const", obj)
+		case *types.Var:
+			add("var", obj)
+		case *types.Func:
+			add("func", obj)
+		case *types.TypeName:
+			add("type", obj)
+			if obj.IsAlias() {
+				continue // its methods and fields belong to the aliased type
+			}
+			named, ok := obj.Type().(*types.Named)
+			if !ok {
+				continue
+			}
+			for i := 0; i < named.NumMethods(); i++ {
+				if m := named.Method(i); m.Exported() {
+					add("method", m)
+				}
+			}
+			switch u := named.Underlying().(type) {
+			case *types.Struct:
+				for i := 0; i < u.NumFields(); i++ {
+					if f := u.Field(i); f.Exported() {
+						add("field", f)
+					}
+				}
+			case *types.Interface:
+				for i := 0; i < u.NumExplicitMethods(); i++ {
+					if m := u.ExplicitMethod(i); m.Exported() {
+						add("method", m)
+					}
+				}
+			}
+		}
+	}
+	return decls
+}
+
+// exportedSignature returns the signature of obj, as printed by
+// types.ObjectString, except that the unexported fields of a struct type
+// declared by obj are omitted, since they are not part of the API.
+func exportedSignature(obj types.Object, qual types.Qualifier) string {
+	sig := types.ObjectString(obj, qual)
+	tname, ok := obj.(*types.TypeName)
+	if !ok || tname.IsAlias() {
+		return sig
+	}
+	st, ok := tname.Type().Underlying().(*types.Struct)
+	if !ok {
+		return sig
+	}
+	var (
+		fields []*types.Var
+		tags   []string
+	)
+	for i := 0; i < st.NumFields(); i++ {
+		if f := st.Field(i); f.Exported() {
+			fields = append(fields, f)
+			tags = append(tags, st.Tag(i))
+		}
+	}
+	if len(fields) == st.NumFields() {
+		return sig
+	}
+	// The signature ends with the underlying struct type.
+	under := types.TypeString(st, qual)
+	if !strings.HasSuffix(sig, under) {
+		return sig
+	}
+	return strings.TrimSuffix(sig, under) + types.TypeString(types.NewStruct(fields, tags), qual)
+}
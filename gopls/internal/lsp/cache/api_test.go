@@ -0,0 +1,75 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+)
+
+func TestExportedDecls(t *testing.T) {
+	const src = `package p
+
+const C = 1
+
+var v, V int
+
+func F(x int) error { return nil }
+
+type T struct {
+	A int
+	b string
+	C bool
+	*U
+	u
+}
+
+type U struct{ a int }
+
+type u struct{}
+
+func (T) M() *T { return nil }
+func (T) m()    {}
+
+type I interface {
+	N()
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := new(types.Config).Check("p", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct{ path, kind, sig string }{
+		{"C", "const", "const C untyped int"},
+		{"F", "func", "func F(x int) error"},
+		{"I", "type", "type I interface{N()}"},
+		{"I.UM0", "method", "func (I).N()"},
+		{"T", "type", "type T struct{A int; C bool; *U}"},
+		{"T.M0", "method", "func (T).M() *T"},
+		{"T.UF0", "field", "field A int"},
+		{"T.UF2", "field", "field C bool"},
+		{"T.UF3", "field", "field U *U"},
+		{"U", "type", "type U struct{}"},
+		{"V", "var", "var V int"},
+	}
+	got := exportedDecls(pkg)
+	if len(got) != len(want) {
+		t.Fatalf("exportedDecls returned %d declarations, want %d: %v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		if g := got[i]; string(g.Path) != w.path || g.Kind != w.kind || g.Signature != w.sig {
+			t.Errorf("exportedDecls()[%d] = {%s %s %q}, want {%s %s %q}", i, g.Path, g.Kind, g.Signature, w.path, w.kind, w.sig)
+		}
+	}
+}
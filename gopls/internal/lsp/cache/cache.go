@@ -175,6 +175,7 @@ func NewSession(ctx context.Context, c *Cache, optionsOverrides func(*source.Opt
 		options:     options,
 		overlays:    make(map[span.URI]*overlay),
 	}
+	s.gocmdRunner.SetMaxConcurrency(options.GoCommandConcurrency)
 	event.Log(ctx, "New session", KeyCreateSession.Of(s))
 	return s
 }
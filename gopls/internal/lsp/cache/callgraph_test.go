@@ -0,0 +1,83 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/go/types/objectpath"
+	"golang.org/x/tools/gopls/internal/lsp/source"
+	"golang.org/x/tools/internal/testenv"
+)
+
+func TestPackageCallGraph(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	files := map[string]string{
+		"go.mod": "module example.com\n\ngo 1.18\n",
+		"p/p.go": `package p
+
+type t struct{}
+
+func (t) m() { helper(); t{}.n() }
+func (t) n() {}
+
+func helper() { F() }
+
+func F() { t{}.m() }
+
+func G() {
+	helper()
+	F()
+	func() { t{}.n() }()
+}
+`,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	_, snapshot := newTestSnapshot(ctx, t, files, nil)
+	active, err := snapshot.ActiveMetadata(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := active[0].ID
+	graph, err := snapshot.PackageCallGraph(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkgs, err := snapshot.TypeCheck(ctx, source.TypecheckFull, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := func(path objectpath.Path) string {
+		obj, err := objectpath.Object(pkgs[0].GetTypes(), path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return obj.Name()
+	}
+	got := make(map[string][]string)
+	for caller, callees := range graph {
+		names := []string{}
+		for _, callee := range callees {
+			names = append(names, name(callee))
+		}
+		got[name(caller)] = names
+	}
+	// The unexported function helper has no objectpath, so it is missing
+	// along with its edges from m and G and to F.
+	want := map[string][]string{
+		"F": {"m"},
+		"G": {"F", "n"},
+		"m": {"n"},
+		"n": {},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("PackageCallGraph() = %v, want %v", got, want)
+	}
+}
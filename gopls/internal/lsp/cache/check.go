@@ -78,6 +78,7 @@ func (s *snapshot) buildPackageHandle(ctx context.Context, id PackageID, mode so
 	entry, hit := s.packages.Get(packageKey)
 	m := s.meta.metadata[id]
 	s.mu.Unlock()
+	s.view.stats.record(&s.view.stats.typeCheckHits, &s.view.stats.typeCheckMisses, hit)
 
 	if m == nil {
 		return nil, fmt.Errorf("no metadata for %s", id)
@@ -326,6 +327,19 @@ func typeCheckImpl(ctx context.Context, snapshot *snapshot, goFiles, compiledGoF
 	// the snapshot is possibly destroyed.
 	defer wg.Wait()
 
+	// If the view bounds type-checking, wait for the dependencies before
+	// taking a slot, so that no package holds one while it waits for
+	// dependencies that need one.
+	if sema := snapshot.view.typeCheckSema; sema != nil {
+		wg.Wait()
+		select {
+		case sema <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		defer func() { <-sema }()
+	}
+
 	var filter *unexportedFilter
 	if mode == source.ParseExported {
 		filter = &unexportedFilter{uses: map[string]bool{}}
@@ -0,0 +1,103 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/gopls/internal/lsp/source"
+	"golang.org/x/tools/gopls/internal/span"
+	"golang.org/x/tools/internal/testenv"
+)
+
+// TestBoundedTypeCheck checks that type-checking a diamond of packages
+// completes when the view allows only one package to be type-checked at a
+// time.
+func TestBoundedTypeCheck(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	files := map[string]string{
+		"go.mod": "module example.com\n\ngo 1.18\n",
+		"a/a.go": "package a\n\nimport (\n\t\"example.com/b\"\n\t\"example.com/c\"\n)\n\nvar A = b.B + c.C\n",
+		"b/b.go": "package b\n\nimport \"example.com/d\"\n\nvar B = d.D\n",
+		"c/c.go": "package c\n\nimport \"example.com/d\"\n\nvar C = d.D\n",
+		"d/d.go": "package d\n\nconst D = 1\n",
+		"e/e.go": "package e\n\nimport \"example.com/a\"\n\nvar E = a.A\n",
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	view, snapshot := newTestSnapshot(ctx, t, files, func(options *source.Options) {
+		options.TypeCheckConcurrency = 1
+	})
+	if cap(view.typeCheckSema) != 1 {
+		t.Fatalf("view.typeCheckSema has capacity %d, want 1", cap(view.typeCheckSema))
+	}
+
+	active, err := snapshot.ActiveMetadata(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []PackageID
+	for _, m := range active {
+		ids = append(ids, m.ID)
+	}
+	if len(ids) != 5 {
+		t.Fatalf("got %d active packages, want 5: %v", len(ids), ids)
+	}
+	pkgs, err := snapshot.TypeCheck(source.WithTypeCheckConcurrency(ctx, 2), source.TypecheckFull, ids...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, p := range pkgs {
+		if p.ID() != ids[i] {
+			t.Errorf("TypeCheck returned %s at index %d, want %s", p.ID(), i, ids[i])
+		}
+		if diags := p.(*pkg).diagnostics; len(diags) > 0 {
+			t.Errorf("%s has diagnostics: %v", p.ID(), diags)
+		}
+	}
+	if len(view.typeCheckSema) != 0 {
+		t.Errorf("%d type-checking slots are still held", len(view.typeCheckSema))
+	}
+}
+
+// newTestSnapshot returns a new view of a temporary folder containing the
+// given files, keyed by slash-separated relative path, and its snapshot.
+// If setOptions is non-nil, it is applied to the options of the view.
+// The view is shut down when the test completes.
+func newTestSnapshot(ctx context.Context, t *testing.T, files map[string]string, setOptions func(*source.Options)) (*View, source.Snapshot) {
+	t.Helper()
+	folder := t.TempDir()
+	for name, content := range files {
+		filename := filepath.Join(folder, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	session := NewSession(ctx, New(nil, nil), nil)
+	options := source.DefaultOptions().Clone()
+	options.Env = map[string]string{"GOPACKAGESDRIVER": "off", "GOROOT": ""}
+	if setOptions != nil {
+		setOptions(options)
+	}
+	view, snapshot, release, err := session.NewView(ctx, "test", span.URIFromPath(folder), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		release()
+		session.Shutdown(context.Background())
+	})
+	return view, snapshot
+}
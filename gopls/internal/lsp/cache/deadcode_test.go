@@ -0,0 +1,153 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/internal/testenv"
+)
+
+func TestUnreachableFunctions(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	tests := []struct {
+		pkg, src string
+		want     []string // names of the unreachable functions and methods
+	}{
+		{
+			"roots",
+			`package roots
+
+type t struct{}
+
+func (t) live() {}
+func (t) dead() {}
+
+func Exported() { t{}.live() }
+`,
+			[]string{"dead"},
+		},
+		{
+			"chain",
+			`package chain
+
+type t struct{}
+
+func (t) a() { t{}.b() }
+func (t) b() {}
+`,
+			[]string{"a", "b"},
+		},
+		{
+			"iface",
+			`package iface
+
+type I interface{ m() }
+
+type t struct{}
+
+func (t) m() { t{}.n() }
+func (t) n() {}
+func (t) o() {}
+`,
+			[]string{"o"},
+		},
+		{
+			"values",
+			`package values
+
+import "sort"
+
+type t struct{}
+
+func (t) v() {}
+func (t) w() {}
+func (t) x() {}
+func (t) dead() {}
+
+var V = t{}.v
+
+func Exported() func() {
+	sort.Ints(nil)
+	return t{}.w
+}
+
+func (t) Run(f func()) { f() }
+
+func Call() { t{}.Run(t{}.x) }
+`,
+			[]string{"dead"},
+		},
+		{
+			"funcs",
+			`package funcs
+
+func used() {}
+
+func unused() { calledByUnused() }
+
+func calledByUnused() {}
+
+func viaInit() {}
+
+func init() { viaInit() }
+
+func Exported() { used() }
+`,
+			[]string{"unused", "calledByUnused"},
+		},
+		{
+			"main",
+			`package main
+
+type t struct{}
+
+func (t) a() {}
+func (t) b() {}
+func (t) c() {}
+
+func init() { t{}.a() }
+
+func main() { t{}.b() }
+`,
+			[]string{"c"},
+		},
+	}
+
+	files := map[string]string{"go.mod": "module example.com\n\ngo 1.18\n"}
+	for _, test := range tests {
+		files[test.pkg+"/p.go"] = test.src
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	_, snapshot := newTestSnapshot(ctx, t, files, nil)
+	active, err := snapshot.ActiveMetadata(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make(map[PackagePath]PackageID)
+	for _, m := range active {
+		ids[m.PkgPath] = m.ID
+	}
+
+	for _, test := range tests {
+		id := ids[PackagePath("example.com/"+test.pkg)]
+		fns, err := snapshot.UnreachableFunctions(ctx, id)
+		if err != nil {
+			t.Fatalf("UnreachableFunctions(%s): %v", test.pkg, err)
+		}
+		var got []string
+		for _, fn := range fns {
+			got = append(got, fn.Name())
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("UnreachableFunctions(%s) = %v, want %v", test.pkg, got, test.want)
+		}
+	}
+}
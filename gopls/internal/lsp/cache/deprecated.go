@@ -0,0 +1,133 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/lsp/source"
+	"golang.org/x/tools/gopls/internal/span"
+)
+
+// DeprecatedUsages reports, as hints, the references in the given file to
+// symbols of other packages whose documentation marks them as deprecated.
+func (s *snapshot) DeprecatedUsages(ctx context.Context, uri span.URI) ([]*source.Diagnostic, error) {
+	pkg, pgf, err := source.PackageForFile(ctx, s, uri, source.TypecheckFull, source.NarrowestPackage)
+	if err != nil {
+		return nil, err
+	}
+	info := pkg.GetTypesInfo()
+
+	// notices memoizes the deprecation notice of each referenced object.
+	type notice struct {
+		text       string
+		deprecated bool
+	}
+	notices := make(map[types.Object]notice)
+
+	var (
+		diags []*source.Diagnostic
+		err2  error
+	)
+	ast.Inspect(pgf.File, func(n ast.Node) bool {
+		id, ok := n.(*ast.Ident)
+		if !ok || err2 != nil {
+			return err2 == nil
+		}
+		obj := info.Uses[id]
+		if obj == nil || obj.Pkg() == nil || obj.Pkg().Path() == string(pkg.PkgPath()) {
+			return true // builtin, or a use within the declaring package
+		}
+		if _, ok := obj.(*types.PkgName); ok {
+			return true
+		}
+		note, ok := notices[obj]
+		if !ok {
+			if declPkg, err := source.FindPackageFromPos(pkg, obj.Pos()); err == nil {
+				note.text, note.deprecated = deprecationNotice(objectDoc(declPkg.GetSyntax(), obj))
+			}
+			notices[obj] = note
+		}
+		if !note.deprecated {
+			return true
+		}
+		rng, err := pgf.NodeRange(id)
+		if err != nil {
+			err2 = err
+			return false
+		}
+		msg := fmt.Sprintf("%s is deprecated", id.Name)
+		if note.text != "" {
+			msg += ": " + note.text
+		}
+		diags = append(diags, &source.Diagnostic{
+			URI:      uri,
+			Range:    rng,
+			Severity: protocol.SeverityHint,
+			Source:   source.Deprecation,
+			Message:  msg,
+			Tags:     []protocol.DiagnosticTag{protocol.Deprecated},
+		})
+		return true
+	})
+	if err2 != nil {
+		return nil, err2
+	}
+	return diags, nil
+}
+
+// objectDoc returns the doc comment of the declaration of obj within files,
+// or nil if it has none. For a spec of a declaration group without its own
+// doc comment, the doc comment of the group is returned.
+func objectDoc(files []*ast.File, obj types.Object) *ast.CommentGroup {
+	decl, field := source.FindDeclAndField(files, obj.Pos())
+	if field != nil {
+		return field.Doc
+	}
+	switch decl := decl.(type) {
+	case *ast.FuncDecl:
+		return decl.Doc
+	case *ast.GenDecl:
+		for _, spec := range decl.Specs {
+			if !(spec.Pos() <= obj.Pos() && obj.Pos() < spec.End()) {
+				continue
+			}
+			switch spec := spec.(type) {
+			case *ast.ValueSpec:
+				if spec.Doc != nil {
+					return spec.Doc
+				}
+			case *ast.TypeSpec:
+				if spec.Doc != nil {
+					return spec.Doc
+				}
+			}
+		}
+		return decl.Doc
+	}
+	return nil
+}
+
+// deprecationNotice reports whether doc contains a paragraph beginning with
+// "Deprecated: ", by convention, and returns the text of that paragraph
+// following the marker, with line breaks replaced by spaces.
+func deprecationNotice(doc *ast.CommentGroup) (string, bool) {
+	const marker = "Deprecated: "
+	for _, para := range strings.Split(doc.Text(), "\n\n") {
+		para = strings.TrimSpace(para)
+		if para+" " == marker {
+			return "", true
+		}
+		if strings.HasPrefix(para, marker) {
+			return strings.Join(strings.Fields(para[len(marker):]), " "), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,88 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+)
+
+func TestDeprecationNotice(t *testing.T) {
+	const src = `package p
+
+// A is deprecated.
+//
+// Deprecated: use B
+// instead.
+func A() {}
+
+// B is not deprecated, despite mentioning Deprecated: in passing.
+func B() {}
+
+// Deprecated:
+var C int
+
+// Group is deprecated as a whole.
+//
+// Deprecated: use Other.
+const (
+	D = iota
+	// E has its own doc.
+	E
+)
+
+type T struct {
+	// F is a field.
+	//
+	// Deprecated: use G.
+	F int
+	G int
+}
+
+type (
+	// U is deprecated.
+	//
+	// Deprecated: use T.
+	U int
+)
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := new(types.Config).Check("p", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	field := func(typ, name string) types.Object {
+		obj, _, _ := types.LookupFieldOrMethod(pkg.Scope().Lookup(typ).Type(), false, pkg, name)
+		return obj
+	}
+
+	tests := []struct {
+		obj            types.Object
+		wantDeprecated bool
+		wantText       string
+	}{
+		{pkg.Scope().Lookup("A"), true, "use B instead."},
+		{pkg.Scope().Lookup("B"), false, ""},
+		{pkg.Scope().Lookup("C"), true, ""},
+		{pkg.Scope().Lookup("D"), true, "use Other."},
+		{pkg.Scope().Lookup("E"), false, ""},
+		{field("T", "F"), true, "use G."},
+		{field("T", "G"), false, ""},
+		{pkg.Scope().Lookup("U"), true, "use T."},
+	}
+	for _, test := range tests {
+		text, deprecated := deprecationNotice(objectDoc([]*ast.File{f}, test.obj))
+		if deprecated != test.wantDeprecated || text != test.wantText {
+			t.Errorf("deprecationNotice(%s) = (%q, %t), want (%q, %t)", test.obj.Name(), text, deprecated, test.wantText, test.wantDeprecated)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/lsp/safetoken"
+	"golang.org/x/tools/gopls/internal/lsp/source"
+	"golang.org/x/tools/gopls/internal/span"
+)
+
+// DuplicateImports reports, as warnings, the import specs of the given
+// file whose path is imported by another spec. Only the file's header is
+// parsed; no type checking is needed.
+func (s *snapshot) DuplicateImports(ctx context.Context, uri span.URI) ([]*source.Diagnostic, error) {
+	fh, err := s.GetFile(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	pgf, err := s.ParseGo(ctx, fh, source.ParseHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	var diags []*source.Diagnostic
+	for _, dup := range duplicateImports(pgf.File) {
+		rng, err := pgf.NodeRange(dup.spec)
+		if err != nil {
+			return nil, err
+		}
+		keptRng, err := pgf.NodeRange(dup.kept)
+		if err != nil {
+			return nil, err
+		}
+		path := dup.spec.Path.Value
+		msg := fmt.Sprintf("%s is imported more than once", path)
+		if name := importName(dup.kept); name != importName(dup.spec) && dup.spec.Name != nil && dup.spec.Name.Name != "_" {
+			msg = fmt.Sprintf("%s is imported more than once, here as %s", path, dup.spec.Name.Name)
+		}
+		diag := &source.Diagnostic{
+			URI:      uri,
+			Range:    rng,
+			Severity: protocol.SeverityWarning,
+			Source:   source.DuplicateImport,
+			Message:  msg,
+			Related: []source.RelatedInformation{{
+				URI:     uri,
+				Range:   keptRng,
+				Message: "first imported here",
+			}},
+		}
+		if dup.removable {
+			edit, err := deleteImportEdit(pgf, dup.decl, dup.spec)
+			if err != nil {
+				return nil, err
+			}
+			diag.SuggestedFixes = []source.SuggestedFix{{
+				Title:      fmt.Sprintf("Remove duplicate import of %s", path),
+				Edits:      map[span.URI][]protocol.TextEdit{uri: {edit}},
+				ActionKind: protocol.QuickFix,
+			}}
+		}
+		diags = append(diags, diag)
+	}
+	return diags, nil
+}
+
+// A duplicateImport is an import spec whose path is imported by an earlier
+// spec of the same file.
+type duplicateImport struct {
+	decl      *ast.GenDecl    // the import declaration containing spec
+	spec      *ast.ImportSpec // the redundant spec
+	kept      *ast.ImportSpec // the spec that is kept
+	removable bool            // spec may be removed without breaking references
+}
+
+// duplicateImports returns the redundant import specs of file, in order.
+//
+// For each path, the spec that is kept is the first one that is not a blank
+// import, if any. Every other spec of the path is redundant; it may be
+// removed if it is a blank import or has the same name as the kept spec.
+func duplicateImports(file *ast.File) []duplicateImport {
+	type importSpec struct {
+		decl *ast.GenDecl
+		spec *ast.ImportSpec
+	}
+	var paths []string
+	specs := make(map[string][]importSpec)
+	for _, decl := range file.Decls {
+		decl, ok := decl.(*ast.GenDecl)
+		if !ok || decl.Tok != token.IMPORT {
+			continue
+		}
+		for _, spec := range decl.Specs {
+			spec := spec.(*ast.ImportSpec)
+			path, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				continue // a syntax error is reported by the parser
+			}
+			if specs[path] == nil {
+				paths = append(paths, path)
+			}
+			specs[path] = append(specs[path], importSpec{decl, spec})
+		}
+	}
+
+	var dups []duplicateImport
+	for _, path := range paths {
+		list := specs[path]
+		if len(list) < 2 {
+			continue
+		}
+		kept := list[0].spec
+		for _, is := range list {
+			if importName(is.spec) != "_" {
+				kept = is.spec
+				break
+			}
+		}
+		for _, is := range list {
+			if is.spec == kept {
+				continue
+			}
+			name := importName(is.spec)
+			dups = append(dups, duplicateImport{
+				decl:      is.decl,
+				spec:      is.spec,
+				kept:      kept,
+				removable: name == "_" || name == importName(kept),
+			})
+		}
+	}
+	sort.Slice(dups, func(i, j int) bool { return dups[i].spec.Pos() < dups[j].spec.Pos() })
+	return dups
+}
+
+// importName returns the explicit name of the import spec, or "" if it has
+// none.
+func importName(spec *ast.ImportSpec) string {
+	if spec.Name == nil {
+		return ""
+	}
+	return spec.Name.Name
+}
+
+// deleteImportEdit returns the edit that deletes spec, an import spec of
+// decl. If spec is the only spec of decl, the entire declaration is
+// deleted. Whole lines are deleted when the deleted node occupies them
+// alone, apart from a trailing comment.
+func deleteImportEdit(pgf *source.ParsedGoFile, decl *ast.GenDecl, spec *ast.ImportSpec) (protocol.TextEdit, error) {
+	var node ast.Node = spec
+	if len(decl.Specs) == 1 {
+		node = decl
+	}
+	start, end, err := safetoken.Offsets(pgf.Tok, node.Pos(), node.End())
+	if err != nil {
+		return protocol.TextEdit{}, err
+	}
+	content := pgf.Mapper.Content
+
+	lineStart := strings.LastIndexByte(string(content[:start]), '\n') + 1
+	lineEnd := len(content)
+	if i := strings.IndexByte(string(content[end:]), '\n'); i >= 0 {
+		lineEnd = end + i + 1
+	}
+	before := strings.TrimSpace(string(content[lineStart:start]))
+	after := strings.TrimSpace(string(content[end:lineEnd]))
+	if before == "" && (after == "" || strings.HasPrefix(after, "//")) {
+		start, end = lineStart, lineEnd
+	}
+
+	rng, err := pgf.Mapper.OffsetRange(start, end)
+	if err != nil {
+		return protocol.TextEdit{}, err
+	}
+	return protocol.TextEdit{Range: rng}, nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/lsp/source"
+	"golang.org/x/tools/gopls/internal/span"
+)
+
+func TestDuplicateImports(t *testing.T) {
+	const src = `package p
+
+import "os"
+
+import (
+	"fmt"
+	_ "strings"
+	"strings" // used
+	f "fmt"
+	"fmt"
+)
+
+import "os"
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, parser.ImportsOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uri := span.URIFromPath("/p.go")
+	pgf := &source.ParsedGoFile{
+		URI:    uri,
+		File:   f,
+		Tok:    fset.File(f.Pos()),
+		Mapper: protocol.NewMapper(uri, []byte(src)),
+	}
+
+	var got []string
+	for _, dup := range duplicateImports(f) {
+		line := fset.Position(dup.spec.Pos()).Line
+		keptLine := fset.Position(dup.kept.Pos()).Line
+		got = append(got, fmt.Sprintf("%d (kept %d): %t", line, keptLine, dup.removable))
+	}
+	want := []string{
+		"7 (kept 8): true",  // _ "strings"
+		"9 (kept 6): false", // f "fmt"
+		"10 (kept 6): true", // "fmt"
+		"13 (kept 3): true", // "os"
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("duplicateImports() = %q, want %q", got, want)
+	}
+
+	// Deleting a grouped spec deletes its line; deleting the only spec of a
+	// declaration deletes the declaration.
+	dups := duplicateImports(f)
+	for _, test := range []struct {
+		dup  duplicateImport
+		want string
+	}{
+		{dups[0], "\t_ \"strings\"\n"},
+		{dups[3], "import \"os\"\n"},
+	} {
+		edit, err := deleteImportEdit(pgf, test.dup.decl, test.dup.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		start, end, err := pgf.Mapper.RangeOffsets(edit.Range)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := src[start:end]; got != test.want || edit.NewText != "" {
+			t.Errorf("deleteImportEdit deletes %q, want %q", got, test.want)
+		}
+	}
+}
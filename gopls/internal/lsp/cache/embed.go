@@ -0,0 +1,192 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/tools/gopls/internal/lsp/source"
+	"golang.org/x/tools/gopls/internal/span"
+)
+
+// EmbeddedFiles returns the files embedded by each Go file of the package
+// with the given id, keyed by the URI of the embedding file.
+//
+// Patterns are resolved relative to the directory of the embedding file,
+// following the rules of the go command: files within a directory matched by
+// a pattern are embedded recursively, excluding files whose names begin with
+// '.' or '_' (unless the pattern has the "all:" prefix) and subdirectories
+// that belong to another module. Files that cannot be embedded are silently
+// omitted.
+func (s *snapshot) EmbeddedFiles(ctx context.Context, id PackageID) (map[span.URI][]span.URI, error) {
+	m := s.Metadata(id)
+	if m == nil {
+		return nil, fmt.Errorf("no metadata for %s", id)
+	}
+	result := make(map[span.URI][]span.URI)
+	for _, uri := range m.GoFiles {
+		fh, err := s.GetFile(ctx, uri)
+		if err != nil {
+			return nil, err
+		}
+		pgf, err := s.ParseGo(ctx, fh, source.ParseFull)
+		if err != nil {
+			return nil, err
+		}
+		var patterns []string
+		for _, cg := range pgf.File.Comments {
+			for _, c := range cg.List {
+				args := strings.TrimPrefix(c.Text, "//go:embed")
+				if args == c.Text || args != "" && !unicode.IsSpace(rune(args[0])) {
+					continue // not an embed directive
+				}
+				pats, err := parseEmbedPatterns(args)
+				if err != nil {
+					continue // the type checker reports malformed directives
+				}
+				patterns = append(patterns, pats...)
+			}
+		}
+		if len(patterns) == 0 {
+			continue
+		}
+		files := resolveEmbedPatterns(filepath.Dir(uri.Filename()), patterns)
+		if len(files) > 0 {
+			result[uri] = files
+		}
+	}
+	return result, nil
+}
+
+// parseEmbedPatterns splits the arguments of a //go:embed directive into
+// patterns. Patterns are separated by spaces, and may be quoted using Go
+// string or raw string syntax.
+func parseEmbedPatterns(args string) ([]string, error) {
+	var patterns []string
+	for {
+		args = strings.TrimLeftFunc(args, unicode.IsSpace)
+		if args == "" {
+			return patterns, nil
+		}
+		var pattern string
+		switch args[0] {
+		default:
+			i := len(args)
+			for j, c := range args {
+				if unicode.IsSpace(c) {
+					i = j
+					break
+				}
+			}
+			pattern, args = args[:i], args[i:]
+
+		case '`':
+			i := strings.Index(args[1:], "`")
+			if i < 0 {
+				return nil, fmt.Errorf("invalid quoted string in //go:embed: %s", args)
+			}
+			pattern, args = args[1:1+i], args[2+i:]
+
+		case '"':
+			i := 1
+			for ; i < len(args); i++ {
+				if args[i] == '\\' {
+					i++
+					continue
+				}
+				if args[i] == '"' {
+					break
+				}
+			}
+			if i >= len(args) {
+				return nil, fmt.Errorf("invalid quoted string in //go:embed: %s", args)
+			}
+			q, err := strconv.Unquote(args[:i+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid quoted string in //go:embed: %s", args[:i+1])
+			}
+			pattern, args = q, args[i+1:]
+		}
+		if r, _ := utf8.DecodeRuneInString(args); args != "" && !unicode.IsSpace(r) {
+			return nil, fmt.Errorf("invalid quoted string in //go:embed: %s", args)
+		}
+		patterns = append(patterns, pattern)
+	}
+}
+
+// resolveEmbedPatterns returns the sorted, de-duplicated URIs of the files
+// matched by the given //go:embed patterns, relative to dir. Invalid
+// patterns, which the go command rejects, match nothing.
+func resolveEmbedPatterns(dir string, patterns []string) []span.URI {
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		all := false
+		if p := strings.TrimPrefix(pattern, "all:"); p != pattern {
+			all = true
+			pattern = p
+		}
+		// As in the go command, patterns must be unrooted slash-separated
+		// paths without "." or ".." elements, and may not be "." itself.
+		if pattern == "." || !fs.ValidPath(pattern) {
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(dir, filepath.FromSlash(pattern)))
+		if err != nil {
+			continue
+		}
+		for _, match := range matches {
+			info, err := os.Lstat(match)
+			if err != nil {
+				continue
+			}
+			switch {
+			case info.Mode().IsRegular():
+				seen[match] = true
+			case info.IsDir():
+				filepath.WalkDir(match, func(path string, d fs.DirEntry, err error) error {
+					if err != nil {
+						return nil
+					}
+					if path == match {
+						return nil
+					}
+					if !all && (strings.HasPrefix(d.Name(), ".") || strings.HasPrefix(d.Name(), "_")) {
+						if d.IsDir() {
+							return filepath.SkipDir
+						}
+						return nil
+					}
+					if d.IsDir() {
+						// Directories containing a go.mod file belong to
+						// another module, and are not embedded.
+						if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+							return filepath.SkipDir
+						}
+						return nil
+					}
+					if d.Type().IsRegular() {
+						seen[path] = true
+					}
+					return nil
+				})
+			}
+		}
+	}
+	var uris []span.URI
+	for path := range seen {
+		uris = append(uris, span.URIFromPath(path))
+	}
+	sort.Slice(uris, func(i, j int) bool { return uris[i] < uris[j] })
+	return uris
+}
@@ -0,0 +1,100 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseEmbedPatterns(t *testing.T) {
+	tests := []struct {
+		args    string
+		want    []string
+		wantErr bool
+	}{
+		{" a.txt b/*.txt", []string{"a.txt", "b/*.txt"}, false},
+		{` "with space.txt" ` + "`raw.txt`", []string{"with space.txt", "raw.txt"}, false},
+		{` "unterminated`, nil, true},
+		{` "a"b`, nil, true},
+	}
+	for _, test := range tests {
+		got, err := parseEmbedPatterns(test.args)
+		if (err != nil) != test.wantErr {
+			t.Errorf("parseEmbedPatterns(%q) returned error %v, want error: %t", test.args, err, test.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseEmbedPatterns(%q) = %q, want %q", test.args, got, test.want)
+		}
+	}
+}
+
+func TestResolveEmbedPatterns(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"a.txt",
+		".hidden.txt",
+		"static/index.html",
+		"static/.hidden",
+		"static/_ignored",
+		"static/sub/page.html",
+		"static/nested/go.mod",
+		"static/nested/other.html",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rel := func(patterns ...string) []string {
+		var got []string
+		for _, uri := range resolveEmbedPatterns(dir, patterns) {
+			got = append(got, filepath.ToSlash(strings.TrimPrefix(uri.Filename(), dir+string(filepath.Separator))))
+		}
+		return got
+	}
+
+	if got, want := rel("*.txt"), []string{".hidden.txt", "a.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("*.txt: got %v, want %v", got, want)
+	}
+	if got, want := rel("static"), []string{"static/index.html", "static/sub/page.html"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("static: got %v, want %v", got, want)
+	}
+	if got, want := rel("all:static"), []string{"static/.hidden", "static/_ignored", "static/index.html", "static/sub/page.html"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("all:static: got %v, want %v", got, want)
+	}
+
+	// Patterns escaping or naming the package directory are invalid, even
+	// if they would match files.
+	if err := os.MkdirAll(filepath.Join(dir, "pkg"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, pattern := range []string{
+		".",
+		"all:.",
+		"./a.txt",
+		"static/../a.txt",
+		"static/./index.html",
+		"static/",
+		"",
+		"/" + filepath.ToSlash(filepath.Join(dir, "a.txt")),
+	} {
+		if got := rel(pattern); len(got) > 0 {
+			t.Errorf("%q: got %v, want no files", pattern, got)
+		}
+	}
+	got := resolveEmbedPatterns(filepath.Join(dir, "pkg"), []string{"../a.txt", "../static"})
+	if len(got) > 0 {
+		t.Errorf("../a.txt ../static: got %v, want no files", got)
+	}
+}
@@ -7,6 +7,7 @@ package cache
 import (
 	"sort"
 
+	"golang.org/x/mod/module"
 	"golang.org/x/tools/gopls/internal/lsp/source"
 	"golang.org/x/tools/gopls/internal/span"
 )
@@ -103,6 +104,114 @@ func (g *metadataGraph) build() {
 	}
 }
 
+// affectedTests returns the sorted IDs of the test packages that
+// transitively depend on the specified package, plus its own test packages.
+func (g *metadataGraph) affectedTests(changed PackageID) []PackageID {
+	var ids []PackageID
+	for id, m := range g.testedReverseClosure(changed) {
+		if m.ForTest != "" {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// testedReverseClosure returns the reflexive transitive closure of the
+// importers of the specified package and of its test variants, which
+// recompile its files rather than import it. Intermediate test variants are
+// excluded.
+func (g *metadataGraph) testedReverseClosure(id PackageID) map[PackageID]*source.Metadata {
+	seeds := []PackageID{id}
+	if m := g.metadata[id]; m != nil {
+		for otherID, other := range g.metadata {
+			if other.ForTest == m.PkgPath && otherID != id {
+				seeds = append(seeds, otherID)
+			}
+		}
+	}
+	closure := g.reverseReflexiveTransitiveClosure(seeds...)
+	for id, m := range closure {
+		if m.IsIntermediateTestVariant() {
+			delete(closure, id)
+		}
+	}
+	return closure
+}
+
+// requiredModules returns the modules, sorted by path, that provide the
+// packages transitively imported by the specified packages, excluding the
+// specified packages themselves. Standard library packages, which belong to
+// no module, are ignored.
+func (g *metadataGraph) requiredModules(ids ...PackageID) []module.Version {
+	roots := make(map[PackageID]bool)
+	for _, id := range ids {
+		roots[id] = true
+	}
+	seen := make(map[PackageID]bool)
+	modules := make(map[string]module.Version)
+	var visit func(id PackageID)
+	visit = func(id PackageID) {
+		if seen[id] {
+			return
+		}
+		seen[id] = true
+		m := g.metadata[id]
+		if m == nil {
+			return
+		}
+		if mod := m.Module; mod != nil && !roots[id] {
+			modules[mod.Path] = module.Version{Path: mod.Path, Version: mod.Version}
+		}
+		for _, dep := range m.DepsByPkgPath {
+			visit(dep)
+		}
+	}
+	for _, id := range ids {
+		visit(id)
+	}
+
+	result := make([]module.Version, 0, len(modules))
+	for _, mod := range modules {
+		result = append(result, mod)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Path < result[j].Path })
+	return result
+}
+
+// nearestOpenPackage returns the package nearest to the specified package,
+// in a breadth-first search of its reflexive transitive dependencies, that
+// has an open compiled Go file, along with the first such file. It returns
+// the empty ID if there is none. Ties are broken by package path.
+func (g *metadataGraph) nearestOpenPackage(id PackageID, isOpen func(span.URI) bool) (PackageID, span.URI) {
+	seen := map[PackageID]bool{id: true}
+	queue := []PackageID{id}
+	for len(queue) > 0 {
+		m := g.metadata[queue[0]]
+		queue = queue[1:]
+		if m == nil {
+			continue
+		}
+		for _, uri := range m.CompiledGoFiles {
+			if isOpen(uri) {
+				return m.ID, uri
+			}
+		}
+		pkgPaths := make([]PackagePath, 0, len(m.DepsByPkgPath))
+		for pkgPath := range m.DepsByPkgPath {
+			pkgPaths = append(pkgPaths, pkgPath)
+		}
+		sort.Slice(pkgPaths, func(i, j int) bool { return pkgPaths[i] < pkgPaths[j] })
+		for _, pkgPath := range pkgPaths {
+			if dep := m.DepsByPkgPath[pkgPath]; !seen[dep] {
+				seen[dep] = true
+				queue = append(queue, dep)
+			}
+		}
+	}
+	return "", ""
+}
+
 // reverseReflexiveTransitiveClosure returns a new mapping containing the
 // metadata for the specified packages along with any package that
 // transitively imports one of them, keyed by ID, including all the initial packages.
@@ -0,0 +1,145 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/gopls/internal/lsp/source"
+	"golang.org/x/tools/gopls/internal/span"
+)
+
+func TestAffectedTests(t *testing.T) {
+	// a <- b <- c, where each of a and b has in-package and external tests,
+	// and b's external test forces an intermediate test variant of c.
+	g := &metadataGraph{metadata: make(map[PackageID]*source.Metadata)}
+	add := func(id PackageID, pkgPath, forTest PackagePath, deps ...PackageID) {
+		m := &source.Metadata{ID: id, PkgPath: pkgPath, ForTest: forTest, DepsByPkgPath: make(map[PackagePath]PackageID)}
+		for _, dep := range deps {
+			m.DepsByPkgPath[g.metadata[dep].PkgPath] = dep
+		}
+		g.metadata[id] = m
+	}
+	add("a", "a", "")
+	add("a [a.test]", "a", "a")
+	add("a_test [a.test]", "a_test", "a", "a [a.test]")
+	add("b", "b", "", "a")
+	add("b [b.test]", "b", "b", "a")
+	add("c", "c", "", "b")
+	add("c [b.test]", "c", "b", "b [b.test]")
+	add("b_test [b.test]", "b_test", "b", "b [b.test]", "c [b.test]")
+	add("d", "d", "")
+	add("d [d.test]", "d", "d")
+	g.build()
+
+	tests := []struct {
+		changed PackageID
+		want    []PackageID
+	}{
+		{"a", []PackageID{"a [a.test]", "a_test [a.test]", "b [b.test]", "b_test [b.test]"}},
+		{"b", []PackageID{"b [b.test]", "b_test [b.test]"}},
+		{"c", nil},
+		{"d", []PackageID{"d [d.test]"}},
+		{"missing", nil},
+	}
+	for _, test := range tests {
+		if got := g.affectedTests(test.changed); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("affectedTests(%s) = %v, want %v", test.changed, got, test.want)
+		}
+	}
+}
+
+func TestRequiredModules(t *testing.T) {
+	main := &packages.Module{Path: "example.com/big", Main: true}
+	dep := &packages.Module{Path: "example.com/dep", Version: "v1.2.0"}
+	other := &packages.Module{Path: "example.com/other", Version: "v0.1.0"}
+	g := &metadataGraph{metadata: make(map[PackageID]*source.Metadata)}
+	add := func(id PackageID, mod *packages.Module, deps ...PackageID) {
+		m := &source.Metadata{ID: id, PkgPath: PackagePath(id), Module: mod, DepsByPkgPath: make(map[PackagePath]PackageID)}
+		for _, dep := range deps {
+			m.DepsByPkgPath[PackagePath(dep)] = dep
+		}
+		g.metadata[id] = m
+	}
+	add("fmt", nil)
+	add("example.com/other/o", other)
+	add("example.com/dep/d", dep, "fmt")
+	add("example.com/big/util", main, "example.com/other/o")
+	add("example.com/big/a", main, "example.com/dep/d", "fmt")
+	add("example.com/big/b", main, "example.com/big/a", "example.com/big/util")
+	g.build()
+
+	tests := []struct {
+		ids  []PackageID
+		want []module.Version
+	}{
+		{[]PackageID{"example.com/big/a"}, []module.Version{{Path: "example.com/dep", Version: "v1.2.0"}}},
+		{[]PackageID{"example.com/big/a", "example.com/big/b", "example.com/big/util"}, []module.Version{
+			{Path: "example.com/dep", Version: "v1.2.0"},
+			{Path: "example.com/other", Version: "v0.1.0"},
+		}},
+		{[]PackageID{"example.com/big/b"}, []module.Version{
+			{Path: "example.com/big"},
+			{Path: "example.com/dep", Version: "v1.2.0"},
+			{Path: "example.com/other", Version: "v0.1.0"},
+		}},
+		{[]PackageID{"fmt"}, []module.Version{}},
+	}
+	for _, test := range tests {
+		if got := g.requiredModules(test.ids...); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("requiredModules(%v) = %v, want %v", test.ids, got, test.want)
+		}
+	}
+}
+
+func TestNearestOpenPackage(t *testing.T) {
+	// a <- b <- c, a <- d, with a file of a and a file of d open.
+	g := &metadataGraph{metadata: make(map[PackageID]*source.Metadata)}
+	add := func(id PackageID, deps ...PackageID) {
+		m := &source.Metadata{
+			ID:              id,
+			PkgPath:         PackagePath(id),
+			CompiledGoFiles: []span.URI{span.URIFromPath("/src/" + string(id) + "/x.go")},
+			DepsByPkgPath:   make(map[PackagePath]PackageID),
+		}
+		for _, dep := range deps {
+			m.DepsByPkgPath[PackagePath(dep)] = dep
+		}
+		g.metadata[id] = m
+	}
+	add("a")
+	add("b", "a")
+	add("c", "b")
+	add("d", "a")
+	add("e")
+	g.build()
+
+	open := map[span.URI]bool{
+		span.URIFromPath("/src/a/x.go"): true,
+		span.URIFromPath("/src/d/x.go"): true,
+	}
+	isOpen := func(uri span.URI) bool { return open[uri] }
+	for _, test := range []struct {
+		id, want PackageID
+	}{
+		{"a", "a"},
+		{"b", "a"},
+		{"c", "a"},
+		{"d", "d"},
+		{"e", ""},
+		{"missing", ""},
+	} {
+		got, uri := g.nearestOpenPackage(test.id, isOpen)
+		if got != test.want {
+			t.Errorf("nearestOpenPackage(%s) = %s, want %s", test.id, got, test.want)
+		}
+		if got != "" && !open[uri] {
+			t.Errorf("nearestOpenPackage(%s) returned file %s, which is not open", test.id, uri)
+		}
+	}
+}
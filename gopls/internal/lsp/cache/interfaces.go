@@ -0,0 +1,315 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/types/objectpath"
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/lsp/source"
+	"golang.org/x/tools/gopls/internal/span"
+	"golang.org/x/tools/internal/typeparams"
+)
+
+// SatisfiedInterfaces returns the locations of the declarations of the
+// non-empty interfaces of workspace packages that are implemented by the type
+// denoted by typ in the package with the given id, or by a pointer to it.
+func (s *snapshot) SatisfiedInterfaces(ctx context.Context, id PackageID, typ objectpath.Path) ([]protocol.Location, error) {
+	if err := s.awaitLoaded(ctx); err != nil {
+		return nil, err
+	}
+	ids := []PackageID{id}
+	for _, m := range s.workspaceMetadata() {
+		if m.ID != id {
+			ids = append(ids, m.ID)
+		}
+	}
+	// Type-check all packages in the same mode, so that they share the types
+	// of their common dependencies.
+	pkgs, err := s.TypeCheck(ctx, source.TypecheckFull, ids...)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := objectpath.Object(pkgs[0].GetTypes(), typ)
+	if err != nil {
+		return nil, err
+	}
+	tname, ok := obj.(*types.TypeName)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a type", obj)
+	}
+	T := tname.Type()
+
+	var locs []protocol.Location
+	seen := make(map[protocol.Location]bool)
+	for _, pkg := range pkgs {
+		scope := pkg.GetTypes().Scope()
+		for _, name := range scope.Names() {
+			iname, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || iname.IsAlias() || iname == tname {
+				continue
+			}
+			named, ok := iname.Type().(*types.Named)
+			if !ok || typeparams.ForNamed(named).Len() > 0 {
+				continue // generic interfaces must be instantiated to be implemented
+			}
+			iface, ok := named.Underlying().(*types.Interface)
+			if !ok || iface.NumMethods() == 0 {
+				continue
+			}
+			if !types.Implements(T, iface) && (types.IsInterface(T) || !types.Implements(types.NewPointer(T), iface)) {
+				continue
+			}
+			loc, err := objLocation(pkg, iname)
+			if err != nil {
+				return nil, err
+			}
+			if !seen[loc] { // test variants declare the same interfaces
+				seen[loc] = true
+				locs = append(locs, loc)
+			}
+		}
+	}
+	sort.Slice(locs, func(i, j int) bool {
+		li, lj := locs[i], locs[j]
+		if li.URI == lj.URI {
+			return protocol.CompareRange(li.Range, lj.Range) < 0
+		}
+		return li.URI < lj.URI
+	})
+	return locs, nil
+}
+
+func (s *snapshot) WorkspaceInterfaces(ctx context.Context) ([]source.InterfaceDecl, error) {
+	if err := s.awaitLoaded(ctx); err != nil {
+		return nil, err
+	}
+	var ids []PackageID
+	for _, m := range s.workspaceMetadata() {
+		ids = append(ids, m.ID)
+	}
+	pkgs, err := s.TypeCheck(ctx, source.TypecheckWorkspace, ids...)
+	if err != nil {
+		return nil, err
+	}
+
+	var decls []source.InterfaceDecl
+	seen := make(map[protocol.Location]bool)
+	for _, pkg := range pkgs {
+		for _, tname := range declaredInterfaces(pkg.GetTypes()) {
+			path, err := objectpath.For(tname)
+			if err != nil {
+				continue
+			}
+			loc, err := objLocation(pkg, tname)
+			if err != nil {
+				return nil, err
+			}
+			if seen[loc] { // test variants declare the same interfaces
+				continue
+			}
+			seen[loc] = true
+			iface := tname.Type().Underlying().(*types.Interface)
+			methods := make([]string, iface.NumMethods())
+			for i := range methods {
+				methods[i] = iface.Method(i).Name()
+			}
+			sort.Strings(methods)
+			decls = append(decls, source.InterfaceDecl{
+				Package:  pkg.PkgPath(),
+				Path:     path,
+				Location: loc,
+				Methods:  methods,
+			})
+		}
+	}
+	sort.Slice(decls, func(i, j int) bool {
+		li, lj := decls[i].Location, decls[j].Location
+		if li.URI == lj.URI {
+			return protocol.CompareRange(li.Range, lj.Range) < 0
+		}
+		return li.URI < lj.URI
+	})
+	return decls, nil
+}
+
+func (s *snapshot) MethodSet(ctx context.Context, id PackageID, typ objectpath.Path) ([]source.MethodInfo, error) {
+	pkgs, err := s.TypeCheck(ctx, source.TypecheckFull, id)
+	if err != nil {
+		return nil, err
+	}
+	pkg := pkgs[0]
+	obj, err := objectpath.Object(pkg.GetTypes(), typ)
+	if err != nil {
+		return nil, err
+	}
+	tname, ok := obj.(*types.TypeName)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a type", obj)
+	}
+
+	qual := types.RelativeTo(pkg.GetTypes())
+	var methods []source.MethodInfo
+	for _, sel := range methodSet(tname.Type()) {
+		m := sel.Obj()
+		info := source.MethodInfo{
+			Name:      m.Name(),
+			Signature: source.FormatSignature(m, qual),
+			Promoted:  len(sel.Index()) > 1,
+		}
+		// Methods of packages loaded from export data have no syntax.
+		if declPkg, err := source.FindPackageFromPos(pkg, m.Pos()); err == nil {
+			if loc, err := objLocation(declPkg, m); err == nil {
+				info.Location = loc
+			}
+		}
+		methods = append(methods, info)
+	}
+	sort.Slice(methods, func(i, j int) bool { return methods[i].Name < methods[j].Name })
+	return methods, nil
+}
+
+func (s *snapshot) TypesWithMethod(ctx context.Context, methodName, signature string) (map[PackagePath][]objectpath.Path, error) {
+	if err := s.awaitLoaded(ctx); err != nil {
+		return nil, err
+	}
+	var ids []PackageID
+	for _, m := range s.workspaceMetadata() {
+		ids = append(ids, m.ID)
+	}
+	pkgs, err := s.TypeCheck(ctx, source.TypecheckWorkspace, ids...)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[PackagePath][]objectpath.Path)
+	var (
+		evaluated bool
+		firstErr  error
+	)
+	for _, pkg := range pkgs {
+		// The signature may refer to types that are declared in only some
+		// packages; skip the others.
+		sig, err := parseSignature(pkg.GetTypes(), signature)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		evaluated = true
+		seen := make(map[objectpath.Path]bool)
+		for _, path := range result[pkg.PkgPath()] {
+			seen[path] = true // test variants declare the same types
+		}
+		for _, tname := range typesWithMethod(pkg.GetTypes(), methodName, sig) {
+			path, err := objectpath.For(tname)
+			if err != nil || seen[path] {
+				continue
+			}
+			seen[path] = true
+			result[pkg.PkgPath()] = append(result[pkg.PkgPath()], path)
+		}
+	}
+	if !evaluated && firstErr != nil {
+		return nil, firstErr
+	}
+	for _, paths := range result {
+		sort.Slice(paths, func(i, j int) bool { return paths[i] < paths[j] })
+	}
+	return result, nil
+}
+
+// parseSignature evaluates the function signature s, such as
+// "func() string" or "() string", in the scope of pkg.
+func parseSignature(pkg *types.Package, s string) (*types.Signature, error) {
+	expr := s
+	if !strings.HasPrefix(strings.TrimSpace(s), "func") {
+		expr = "func" + s
+	}
+	tv, err := types.Eval(token.NewFileSet(), pkg, token.NoPos, expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature %q: %v", s, err)
+	}
+	sig, ok := tv.Type.(*types.Signature)
+	if !ok || !tv.IsType() {
+		return nil, fmt.Errorf("invalid signature %q: not a function type", s)
+	}
+	return sig, nil
+}
+
+// typesWithMethod returns the non-interface named types declared at package
+// level in pkg, in scope order, whose method sets (or those of the
+// corresponding pointer types) include a method with the given name and a
+// signature identical to sig. Aliases are excluded.
+func typesWithMethod(pkg *types.Package, name string, sig *types.Signature) []*types.TypeName {
+	var tnames []*types.TypeName
+	scope := pkg.Scope()
+	for _, n := range scope.Names() {
+		tname, ok := scope.Lookup(n).(*types.TypeName)
+		if !ok || tname.IsAlias() || types.IsInterface(tname.Type()) {
+			continue
+		}
+		for _, sel := range methodSet(tname.Type()) {
+			// Identical ignores receivers.
+			if sel.Obj().Name() == name && types.Identical(sel.Obj().Type(), sig) {
+				tnames = append(tnames, tname)
+				break
+			}
+		}
+	}
+	return tnames
+}
+
+// methodSet returns the method set of T, or of *T if T is not an interface.
+func methodSet(T types.Type) []*types.Selection {
+	if !types.IsInterface(T) {
+		T = types.NewPointer(T)
+	}
+	mset := types.NewMethodSet(T)
+	sels := make([]*types.Selection, mset.Len())
+	for i := range sels {
+		sels[i] = mset.At(i)
+	}
+	return sels
+}
+
+// declaredInterfaces returns the names of the interface types declared at
+// package level in pkg, in scope order. Aliases are excluded.
+func declaredInterfaces(pkg *types.Package) []*types.TypeName {
+	var tnames []*types.TypeName
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		tname, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || tname.IsAlias() {
+			continue
+		}
+		if types.IsInterface(tname.Type()) {
+			tnames = append(tnames, tname)
+		}
+	}
+	return tnames
+}
+
+// objLocation returns the location of the name of obj, which must be
+// declared in pkg.
+func objLocation(pkg source.Package, obj types.Object) (protocol.Location, error) {
+	tok := pkg.FileSet().File(obj.Pos())
+	if tok == nil {
+		return protocol.Location{}, fmt.Errorf("no file for %s", obj)
+	}
+	pgf, err := pkg.File(span.URIFromPath(tok.Name()))
+	if err != nil {
+		return protocol.Location{}, err
+	}
+	return pgf.Mapper.PosLocation(pgf.Tok, obj.Pos(), obj.Pos()+token.Pos(len(obj.Name())))
+}
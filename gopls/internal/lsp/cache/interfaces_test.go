@@ -0,0 +1,200 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/go/types/objectpath"
+	"golang.org/x/tools/internal/testenv"
+)
+
+func TestDeclaredInterfaces(t *testing.T) {
+	const src = `package p
+
+type I interface{ M() }
+
+type Alias = I
+
+type T struct{}
+
+type empty interface{}
+
+type RW interface {
+	I
+	Read() int
+}
+
+func f() {
+	type local interface{ L() }
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := new(types.Config).Check("p", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, tname := range declaredInterfaces(pkg) {
+		got = append(got, tname.Name())
+	}
+	if want := []string{"I", "RW", "empty"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("declaredInterfaces() = %v, want %v", got, want)
+	}
+}
+
+func TestMethodSet(t *testing.T) {
+	const src = `package p
+
+type Inner struct{}
+
+func (Inner) A()  {}
+func (*Inner) B() {}
+
+type T struct {
+	Inner
+}
+
+func (T) C()  {}
+func (*T) D() {}
+
+type I interface {
+	E()
+	J
+}
+
+type J interface{ F() }
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := new(types.Config).Check("p", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		typ  string
+		want []string
+	}{
+		{"T", []string{"A promoted", "B promoted", "C", "D"}},
+		{"I", []string{"E", "F"}},
+	} {
+		var got []string
+		for _, sel := range methodSet(pkg.Scope().Lookup(test.typ).Type()) {
+			desc := sel.Obj().Name()
+			if len(sel.Index()) > 1 {
+				desc += " promoted"
+			}
+			got = append(got, desc)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("methodSet(%s) = %q, want %q", test.typ, got, test.want)
+		}
+	}
+}
+
+func TestTypesWithMethod(t *testing.T) {
+	const src = `package p
+
+type A int
+
+func (A) String() string { return "" }
+
+type B struct{}
+
+func (*B) String() string { return "" }
+
+type C struct{ A }
+
+type D struct{}
+
+func (D) String(int) string { return "" }
+
+type E struct{}
+
+func (E) Error() string { return "" }
+
+type S interface{ String() string }
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := new(types.Config).Check("p", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name, sig string
+		want      []string
+	}{
+		{"String", "func() string", []string{"A", "B", "C"}},
+		{"String", "(int) string", []string{"D"}},
+		{"Error", "func() string", []string{"E"}},
+		{"String", "func() error", nil},
+	} {
+		sig, err := parseSignature(pkg, test.sig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, tname := range typesWithMethod(pkg, test.name, sig) {
+			got = append(got, tname.Name())
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("typesWithMethod(%s, %s) = %q, want %q", test.name, test.sig, got, test.want)
+		}
+	}
+
+	for _, bad := range []string{"func(", "int", "func() undefined"} {
+		if _, err := parseSignature(pkg, bad); err == nil {
+			t.Errorf("parseSignature(%q) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestTypesWithMethodPartialSignature(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	files := map[string]string{
+		"go.mod": "module example.com\n\ngo 1.18\n",
+		"a/a.go": "package a\n\ntype Key int\n\ntype T struct{}\n\nfunc (T) Get(Key) string { return \"\" }\n",
+		"b/b.go": "package b\n\ntype U struct{}\n\nfunc (U) Get(int) string { return \"\" }\n",
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	_, snapshot := newTestSnapshot(ctx, t, files, nil)
+
+	// Key is declared only in package a.
+	got, err := snapshot.TypesWithMethod(ctx, "Get", "(Key) string")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[PackagePath][]objectpath.Path{"example.com/a": {"T"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TypesWithMethod(Get, (Key) string) = %v, want %v", got, want)
+	}
+
+	if _, err := snapshot.TypesWithMethod(ctx, "Get", "(Undefined) string"); err == nil {
+		t.Errorf("TypesWithMethod(Get, (Undefined) string) succeeded, want error")
+	}
+}
@@ -231,6 +231,9 @@ func (s *snapshot) load(ctx context.Context, allowNetwork bool, scopes ...loadSc
 	event.Log(ctx, fmt.Sprintf("%s: updating metadata for %d packages", eventName, len(updates)))
 
 	s.meta = s.meta.Clone(updates)
+	for id := range updates {
+		delete(s.staleMetadata, id)
+	}
 	s.resetIsActivePackageLocked()
 
 	s.workspacePackages = computeWorkspacePackagesLocked(s, s.meta)
@@ -5,15 +5,23 @@
 package cache
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	"golang.org/x/mod/modfile"
 	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
 	"golang.org/x/tools/gopls/internal/lsp/command"
 	"golang.org/x/tools/gopls/internal/lsp/protocol"
 	"golang.org/x/tools/gopls/internal/lsp/source"
@@ -178,6 +186,381 @@ func parseWorkImpl(ctx context.Context, fh source.FileHandle) (*source.ParsedWor
 	}, parseErr
 }
 
+// GoVersions returns the minor version of the go directive of each active
+// go.mod file. Modules with no go directive are assumed to be at go 1.16, as
+// by the go command. Unparseable go.mod files are omitted.
+func (s *snapshot) GoVersions(ctx context.Context) (map[span.URI]int, error) {
+	versions := make(map[span.URI]int)
+	for modURI := range s.workspace.ActiveModFiles() {
+		fh, err := s.GetFile(ctx, modURI)
+		if err != nil {
+			return nil, err
+		}
+		pm, err := s.ParseMod(ctx, fh)
+		if err != nil || pm.File == nil {
+			continue
+		}
+		minor := 16
+		if pm.File.Go != nil {
+			v, ok := goMinorVersion(pm.File.Go.Version)
+			if !ok {
+				continue
+			}
+			minor = v
+		}
+		versions[modURI] = minor
+	}
+	return versions, nil
+}
+
+// ReplaceTargetDirs returns the sorted, de-duplicated directories to which
+// the replace directives of the active go.mod files point. Replacements by
+// another module version, rather than a local directory, are ignored.
+func (s *snapshot) ReplaceTargetDirs(ctx context.Context) ([]span.URI, error) {
+	seen := make(map[span.URI]bool)
+	for modURI := range s.workspace.ActiveModFiles() {
+		fh, err := s.GetFile(ctx, modURI)
+		if err != nil {
+			return nil, err
+		}
+		pm, err := s.ParseMod(ctx, fh)
+		if err != nil || pm.File == nil {
+			continue
+		}
+		for _, r := range pm.File.Replace {
+			if r.New.Version != "" {
+				continue
+			}
+			seen[span.URIFromPath(absolutePath(span.Dir(modURI), r.New.Path))] = true
+		}
+	}
+	dirs := make([]span.URI, 0, len(seen))
+	for dir := range seen {
+		dirs = append(dirs, dir)
+	}
+	sort.Slice(dirs, func(i, j int) bool { return dirs[i] < dirs[j] })
+	return dirs, nil
+}
+
+// ImportPathForDir returns the import path that the go command assigns to
+// the package in dir, according to the module path and root directory of
+// the innermost active module, or local replace target, containing dir.
+// A directory within a replace target has an import path within the
+// replaced module path.
+func (s *snapshot) ImportPathForDir(ctx context.Context, dir span.URI) (ImportPath, error) {
+	type root struct {
+		dir  string
+		path string // module path
+	}
+	var roots []root
+	for modURI := range s.workspace.ActiveModFiles() {
+		fh, err := s.GetFile(ctx, modURI)
+		if err != nil {
+			return "", err
+		}
+		pm, err := s.ParseMod(ctx, fh)
+		if err != nil || pm.File == nil || pm.File.Module == nil {
+			continue
+		}
+		modDir := span.Dir(modURI)
+		roots = append(roots, root{modDir.Filename(), pm.File.Module.Mod.Path})
+		for _, r := range pm.File.Replace {
+			if r.New.Version == "" {
+				roots = append(roots, root{absolutePath(modDir, r.New.Path), r.Old.Path})
+			}
+		}
+	}
+
+	filename := dir.Filename()
+	var best *root
+	for i, r := range roots {
+		if source.InDir(r.dir, filename) && (best == nil || len(r.dir) > len(best.dir)) {
+			best = &roots[i]
+		}
+	}
+	if best == nil {
+		return "", fmt.Errorf("%s is not within a module", filename)
+	}
+
+	// A go.mod file below the root makes dir part of a different module.
+	for d := filename; d != best.dir && source.InDir(best.dir, d); d = filepath.Dir(d) {
+		if _, err := os.Stat(filepath.Join(d, "go.mod")); err == nil {
+			return "", fmt.Errorf("%s is within module %s, which is not active", filename, d)
+		}
+	}
+
+	rel, err := filepath.Rel(best.dir, filename)
+	if err != nil {
+		return "", err
+	}
+	if rel == "." {
+		return ImportPath(best.path), nil
+	}
+	return ImportPath(path.Join(best.path, filepath.ToSlash(rel))), nil
+}
+
+func (s *snapshot) MinimalRequires(ctx context.Context, ids []PackageID) ([]module.Version, error) {
+	if err := s.awaitLoaded(ctx); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	meta := s.meta
+	s.mu.Unlock()
+
+	for _, id := range ids {
+		if meta.metadata[id] == nil {
+			return nil, fmt.Errorf("no metadata for %s", id)
+		}
+	}
+	return meta.requiredModules(ids...), nil
+}
+
+// BuildList avoids running the go command when the module has no
+// requirements and there is no go.work file, in which case the build list is
+// just the main module. Otherwise it runs "go list -m all": the metadata
+// records only the modules that provide loaded packages, not the complete
+// module graph.
+func (s *snapshot) BuildList(ctx context.Context, modURI span.URI) ([]module.Version, error) {
+	fh, err := s.GetFile(ctx, modURI)
+	if err != nil {
+		return nil, err
+	}
+	pm, err := s.ParseMod(ctx, fh)
+	if err != nil {
+		return nil, err
+	}
+	if pm.File.Module == nil {
+		return nil, fmt.Errorf("%s has no module directive", modURI)
+	}
+	if len(pm.File.Require) == 0 && s.WorkFile() == "" {
+		return []module.Version{{Path: pm.File.Module.Mod.Path}}, nil
+	}
+
+	inv := &gocommand.Invocation{
+		Verb:       "list",
+		Args:       []string{"-m", "-json", "all"},
+		WorkingDir: filepath.Dir(modURI.Filename()),
+	}
+	stdout, err := s.RunGoCommandDirect(ctx, source.Normal, inv)
+	if err != nil {
+		return nil, err
+	}
+	return parseBuildList(stdout.Bytes())
+}
+
+// parseBuildList parses the output of "go list -m -json all".
+func parseBuildList(data []byte) ([]module.Version, error) {
+	modules, err := parseModuleList(data)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]module.Version, len(modules))
+	for i, m := range modules {
+		list[i] = module.Version{Path: m.Path, Version: m.Version}
+	}
+	return list, nil
+}
+
+// A listedModule is a module reported by "go list -m -json".
+type listedModule struct {
+	Path, Version string
+	Main          bool
+	Indirect      bool
+	Replace       *struct{ Path, Version string }
+}
+
+// parseModuleList parses the output of "go list -m -json".
+func parseModuleList(data []byte) ([]listedModule, error) {
+	var modules []listedModule
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for dec.More() {
+		var m listedModule
+		if err := dec.Decode(&m); err != nil {
+			return nil, fmt.Errorf("decoding module list: %w", err)
+		}
+		modules = append(modules, m)
+	}
+	return modules, nil
+}
+
+func (s *snapshot) PackagesInModule(ctx context.Context, modulePath string) ([]PackageID, error) {
+	if err := s.awaitLoaded(ctx); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ids []PackageID
+	for id, m := range s.meta.metadata {
+		if m.Module != nil && m.Module.Path == modulePath && m.ForTest == "" {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+func (s *snapshot) PackagesNeedingRequire(ctx context.Context, modPath string) ([]PackageID, error) {
+	if err := s.awaitLoaded(ctx); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ids []PackageID
+	for id := range s.workspacePackages {
+		m := s.meta.metadata[id]
+		if m == nil {
+			continue
+		}
+		for _, depID := range m.DepsByPkgPath {
+			if dep := s.meta.metadata[depID]; dep != nil && dep.Module != nil && dep.Module.Path == modPath {
+				ids = append(ids, id)
+				break
+			}
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+func (s *snapshot) ReplaceDowngrades(ctx context.Context, pm *source.ParsedModule) ([]*source.Diagnostic, error) {
+	return replaceDowngrades(pm)
+}
+
+// replaceDowngrades implements Snapshot.ReplaceDowngrades. Replacements
+// by a different module path, or by a directory, are not considered, since
+// their versions are not comparable with the required version.
+func replaceDowngrades(pm *source.ParsedModule) ([]*source.Diagnostic, error) {
+	if pm.File == nil {
+		return nil, nil
+	}
+	required := make(map[string]string)
+	for _, req := range pm.File.Require {
+		required[req.Mod.Path] = req.Mod.Version
+	}
+	var diags []*source.Diagnostic
+	for _, rep := range pm.File.Replace {
+		if rep.New.Path != rep.Old.Path || rep.New.Version == "" {
+			continue
+		}
+		version, ok := required[rep.Old.Path]
+		if !ok {
+			continue
+		}
+		// A replace of a specific version applies only to that version.
+		if rep.Old.Version != "" && rep.Old.Version != version {
+			continue
+		}
+		if semver.Compare(rep.New.Version, version) >= 0 {
+			continue
+		}
+		rng, err := pm.Mapper.OffsetRange(rep.Syntax.Start.Byte, rep.Syntax.End.Byte)
+		if err != nil {
+			return nil, err
+		}
+		diags = append(diags, &source.Diagnostic{
+			URI:      pm.URI,
+			Range:    rng,
+			Severity: protocol.SeverityHint,
+			Source:   source.ReplaceDowngrade,
+			Message:  fmt.Sprintf("%s is required at %s, but replaced by the older version %s", rep.Old.Path, version, rep.New.Version),
+		})
+	}
+	return diags, nil
+}
+
+func (s *snapshot) ShadowingReplaces(ctx context.Context) ([]source.ReplaceConflict, error) {
+	workURI := s.WorkFile()
+	if workURI == "" {
+		return nil, nil
+	}
+	fh, err := s.GetFile(ctx, workURI)
+	if err != nil {
+		return nil, err
+	}
+	pw, err := s.ParseWork(ctx, fh)
+	if err != nil {
+		return nil, err
+	}
+	if pw.File == nil {
+		return nil, nil
+	}
+
+	// Determine the module path of each member.
+	type member struct {
+		dir string
+		use *modfile.Use
+	}
+	members := make(map[string]member) // by module path
+	var pms []*source.ParsedModule
+	for _, use := range pw.File.Use {
+		dir := absolutePath(span.Dir(workURI), use.Path)
+		mfh, err := s.GetFile(ctx, span.URIFromPath(filepath.Join(dir, "go.mod")))
+		if err != nil {
+			return nil, err
+		}
+		pm, err := s.ParseMod(ctx, mfh)
+		if err != nil || pm.File == nil || pm.File.Module == nil {
+			continue // reported by go.mod diagnostics
+		}
+		members[pm.File.Module.Mod.Path] = member{filepath.Clean(dir), use}
+		pms = append(pms, pm)
+	}
+
+	var conflicts []source.ReplaceConflict
+	for _, pm := range pms {
+		for _, r := range pm.File.Replace {
+			m, ok := members[r.Old.Path]
+			if !ok {
+				continue
+			}
+			if r.New.Version == "" && filepath.Clean(absolutePath(span.Dir(pm.URI), r.New.Path)) == m.dir {
+				continue // consistent with the workspace
+			}
+			replaceRng, err := pm.Mapper.OffsetRange(r.Syntax.Start.Byte, r.Syntax.End.Byte)
+			if err != nil {
+				return nil, err
+			}
+			useRng, err := pw.Mapper.OffsetRange(m.use.Syntax.Start.Byte, m.use.Syntax.End.Byte)
+			if err != nil {
+				return nil, err
+			}
+			conflicts = append(conflicts, source.ReplaceConflict{
+				ModulePath: r.Old.Path,
+				Replace:    protocol.Location{URI: protocol.URIFromSpanURI(pm.URI), Range: replaceRng},
+				Use:        protocol.Location{URI: protocol.URIFromSpanURI(workURI), Range: useRng},
+			})
+		}
+	}
+	sort.Slice(conflicts, func(i, j int) bool {
+		ci, cj := conflicts[i].Replace, conflicts[j].Replace
+		if ci.URI != cj.URI {
+			return ci.URI < cj.URI
+		}
+		return protocol.CompareRange(ci.Range, cj.Range) < 0
+	})
+	return conflicts, nil
+}
+
+// goMinorVersion returns the minor version of a go directive version such as
+// "1.20", "1.21.0", or "1.21rc1".
+func goMinorVersion(version string) (int, bool) {
+	rest := strings.TrimPrefix(version, "1.")
+	if rest == version {
+		return 0, false
+	}
+	end := 0
+	for end < len(rest) && '0' <= rest[end] && rest[end] <= '9' {
+		end++
+	}
+	minor, err := strconv.Atoi(rest[:end])
+	if err != nil {
+		return 0, false
+	}
+	return minor, true
+}
+
 // goSum reads the go.sum file for the go.mod file at modURI, if it exists. If
 // it doesn't exist, it returns nil.
 func (s *snapshot) goSum(ctx context.Context, modURI span.URI) []byte {
@@ -204,6 +587,54 @@ func sumFilename(modURI span.URI) string {
 	return strings.TrimSuffix(modURI.Filename(), ".mod") + ".sum"
 }
 
+// UpdateGoSumForRequire runs "go mod download" for the module path@version
+// in the context of the go.mod file modURI, and returns the resulting
+// contents of its go.sum file, which include the checksums of that module.
+// Neither the go.mod nor the go.sum file on disk is modified.
+//
+// It fails if network access is disabled by GOPROXY=off, since the checksums
+// could not be fetched.
+func (s *snapshot) UpdateGoSumForRequire(ctx context.Context, modURI span.URI, path, version string) ([]byte, error) {
+	ctx, done := event.Start(ctx, "cache.UpdateGoSumForRequire", tag.URI.Of(modURI))
+	defer done()
+
+	if s.goProxyOff() {
+		return nil, fmt.Errorf("cannot fetch checksums for %s@%s: network access is disabled by GOPROXY=off", path, version)
+	}
+	if s.workspaceMode()&tempModfile == 0 {
+		return nil, source.ErrTmpModfileUnsupported
+	}
+	inv := &gocommand.Invocation{
+		Verb:       "mod",
+		Args:       []string{"download", path + "@" + version},
+		WorkingDir: filepath.Dir(modURI.Filename()),
+	}
+	tmpURI, inv, cleanup, err := s.goCommandInvocation(ctx, source.WriteTemporaryModFile|source.AllowNetwork, inv)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	err = s.withGoCommandTimeout(ctx, source.WriteTemporaryModFile, func(ctx context.Context) error {
+		_, err := s.view.gocmdRunner.Run(ctx, *inv)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	// The go command records the checksums alongside the temporary go.mod
+	// file.
+	return ioutil.ReadFile(sumFilename(tmpURI))
+}
+
+// goProxyOff reports whether the view's environment sets GOPROXY=off.
+func (s *snapshot) goProxyOff() bool {
+	proxy, ok := s.view.Options().Env["GOPROXY"]
+	if !ok {
+		proxy = s.view.goEnv["GOPROXY"]
+	}
+	return proxy == "off"
+}
+
 // ModWhy returns the "go mod why" result for each module named in a
 // require statement in the go.mod file.
 // TODO(adonovan): move to new mod_why.go file.
@@ -366,6 +797,56 @@ func (s *snapshot) extractGoCommandErrors(ctx context.Context, goCmdError error)
 	return srcErrs
 }
 
+var (
+	missingGoSumModuleRe   = regexp.MustCompile(`(?m)([^\s:]+)@([^\s:/]+)(?:/go\.mod)?: missing go\.sum entry`)
+	missingGoSumDownloadRe = regexp.MustCompile(`(?m)go mod download ([^\s]+)$`)
+	missingGoSumPackageRe  = regexp.MustCompile(`missing go\.sum entry for module providing package ([^\s]+)(?: \(imported by ([^\s)]+)\))?`)
+)
+
+// missingGoSumError returns a *source.MissingGoSumError describing err, if
+// it is a go command error caused by missing go.sum entries, or nil.
+func missingGoSumError(err error) *source.MissingGoSumError {
+	if err == nil || !strings.Contains(err.Error(), "missing go.sum entry") {
+		return nil
+	}
+	msg := err.Error()
+	result := &source.MissingGoSumError{Err: err}
+	seen := make(map[string]bool)
+	for _, m := range missingGoSumModuleRe.FindAllStringSubmatch(msg, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			result.Modules = append(result.Modules, module.Version{Path: m[1], Version: m[2]})
+		}
+	}
+	for _, m := range missingGoSumDownloadRe.FindAllStringSubmatch(msg, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			result.Modules = append(result.Modules, module.Version{Path: m[1]})
+		}
+	}
+	for _, m := range missingGoSumPackageRe.FindAllStringSubmatch(msg, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			result.Packages = append(result.Packages, m[1])
+			result.Importers = append(result.Importers, m[2])
+		}
+	}
+	return result
+}
+
+// loadCriticalError returns the critical error for a failed load, with
+// diagnostics extracted from the go command error.
+func (s *snapshot) loadCriticalError(ctx context.Context, err error, diags ...*source.Diagnostic) *source.CriticalError {
+	var mainErr error = err
+	if sumErr := missingGoSumError(err); sumErr != nil {
+		mainErr = sumErr
+	}
+	return &source.CriticalError{
+		MainError:   mainErr,
+		Diagnostics: append(diags, s.extractGoCommandErrors(ctx, err)...),
+	}
+}
+
 var moduleVersionInErrorRe = regexp.MustCompile(`[:\s]([+-._~0-9A-Za-z]+)@([+-._~0-9A-Za-z]+)[:\s]`)
 
 // matchErrorToModule matches a go command error message to a go.mod file.
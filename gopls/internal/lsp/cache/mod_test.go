@@ -0,0 +1,286 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/lsp/source"
+	"golang.org/x/tools/gopls/internal/span"
+	"golang.org/x/tools/internal/testenv"
+)
+
+func TestGoMinorVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    int
+		wantOK  bool
+	}{
+		{"1.16", 16, true},
+		{"1.21.0", 21, true},
+		{"1.21rc1", 21, true},
+		{"2.0", 0, false},
+		{"", 0, false},
+	}
+	for _, test := range tests {
+		got, ok := goMinorVersion(test.version)
+		if got != test.want || ok != test.wantOK {
+			t.Errorf("goMinorVersion(%q) = %d, %t, want %d, %t", test.version, got, ok, test.want, test.wantOK)
+		}
+	}
+}
+
+func TestMissingGoSumError(t *testing.T) {
+	tests := []struct {
+		msg           string
+		wantModules   []module.Version
+		wantPackages  []string
+		wantImporters []string
+		wantFix       string
+	}{
+		{
+			msg: "go: example.com/a@v1.2.3: missing go.sum entry for go.mod file; to add it:\n\tgo mod download example.com/a",
+			wantModules: []module.Version{
+				{Path: "example.com/a", Version: "v1.2.3"},
+			},
+			wantFix: "go mod download example.com/a",
+		},
+		{
+			msg: "verifying example.com/b@v0.1.0/go.mod: missing go.sum entry; to add it:\n\tgo mod download example.com/b",
+			wantModules: []module.Version{
+				{Path: "example.com/b", Version: "v0.1.0"},
+			},
+			wantFix: "go mod download example.com/b",
+		},
+		{
+			msg:           "main.go:3:8: missing go.sum entry for module providing package example.com/c/pkg (imported by example.com/m); to add:\n\tgo get example.com/m",
+			wantPackages:  []string{"example.com/c/pkg"},
+			wantImporters: []string{"example.com/m"},
+			wantFix:       "go get example.com/m",
+		},
+		{
+			msg:           "missing go.sum entry for module providing package example.com/c/pkg",
+			wantPackages:  []string{"example.com/c/pkg"},
+			wantImporters: []string{""},
+			wantFix:       "go mod tidy",
+		},
+	}
+	for _, test := range tests {
+		err := missingGoSumError(errors.New(test.msg))
+		if err == nil {
+			t.Errorf("missingGoSumError(%q) = nil", test.msg)
+			continue
+		}
+		if !reflect.DeepEqual(err.Modules, test.wantModules) || !reflect.DeepEqual(err.Packages, test.wantPackages) || !reflect.DeepEqual(err.Importers, test.wantImporters) {
+			t.Errorf("missingGoSumError(%q) = %v, %v, %v, want %v, %v, %v", test.msg, err.Modules, err.Packages, err.Importers, test.wantModules, test.wantPackages, test.wantImporters)
+		}
+		if got := strings.Join(err.FixCommand(), " "); got != test.wantFix {
+			t.Errorf("missingGoSumError(%q).FixCommand() = %q, want %q", test.msg, got, test.wantFix)
+		}
+		if !strings.Contains(err.Error(), strconv.Quote(test.wantFix)) {
+			t.Errorf("missingGoSumError(%q).Error() = %q, want it to suggest %q", test.msg, err.Error(), test.wantFix)
+		}
+	}
+	if err := missingGoSumError(errors.New("no required module provides package example.com/d")); err != nil {
+		t.Errorf("missingGoSumError(unrelated error) = %v, want nil", err)
+	}
+}
+
+func TestReplaceDowngrades(t *testing.T) {
+	const src = `module example.com/m
+
+go 1.18
+
+require (
+	example.com/a v1.2.0
+	example.com/b v1.2.0
+	example.com/c v1.2.0
+	example.com/d v1.2.0
+)
+
+replace example.com/a => example.com/a v1.1.0
+
+replace example.com/b => example.com/b v1.3.0
+
+replace example.com/c v1.0.0 => example.com/c v0.9.0
+
+replace example.com/d => ../d
+`
+	uri := span.URIFromPath("/m/go.mod")
+	file, err := modfile.Parse(uri.Filename(), []byte(src), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pm := &source.ParsedModule{URI: uri, File: file, Mapper: protocol.NewMapper(uri, []byte(src))}
+	diags, err := replaceDowngrades(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diags) != 1 {
+		t.Fatalf("got %d diagnostics, want 1: %v", len(diags), diags)
+	}
+	want := protocol.Range{
+		Start: protocol.Position{Line: 11, Character: 0},
+		End:   protocol.Position{Line: 11, Character: 45},
+	}
+	if diags[0].Range != want {
+		t.Errorf("diagnostic range = %v, want %v", diags[0].Range, want)
+	}
+}
+
+func TestParseBuildList(t *testing.T) {
+	const data = `{
+	"Path": "example.com/main",
+	"Main": true,
+	"Dir": "/src/main",
+	"GoMod": "/src/main/go.mod"
+}
+{
+	"Path": "example.com/dep",
+	"Version": "v1.2.3",
+	"Replace": {
+		"Path": "../dep"
+	}
+}
+{
+	"Path": "golang.org/x/mod",
+	"Version": "v0.8.0",
+	"Indirect": true
+}
+`
+	got, err := parseBuildList([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []module.Version{
+		{Path: "example.com/main"},
+		{Path: "example.com/dep", Version: "v1.2.3"},
+		{Path: "golang.org/x/mod", Version: "v0.8.0"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseBuildList() = %v, want %v", got, want)
+	}
+
+	if _, err := parseBuildList([]byte(`{"Path": `)); err == nil {
+		t.Error("parseBuildList(truncated) succeeded, want error")
+	}
+}
+
+func TestModuleTree(t *testing.T) {
+	const graphOut = `example.com/main example.com/a@v1.0.0
+example.com/main example.com/b@v1.1.0
+example.com/main go@1.21
+example.com/a@v1.0.0 example.com/b@v1.0.0
+example.com/b@v1.1.0 example.com/a@v1.0.0
+example.com/b@v1.1.0 example.com/c@v0.1.0
+`
+	graph, err := parseModGraph(graphOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	modules := []listedModule{
+		{Path: "example.com/main", Main: true},
+		{Path: "example.com/a", Version: "v1.0.0"},
+		{Path: "example.com/b", Version: "v1.1.0"},
+		{Path: "example.com/c", Version: "v0.1.0", Indirect: true, Replace: &struct{ Path, Version string }{Path: "../c"}},
+	}
+	root, err := moduleTree(modules, graph)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Render the tree, one node per line, indented by depth.
+	var lines []string
+	var render func(n *source.ModuleNode, depth int)
+	render = func(n *source.ModuleNode, depth int) {
+		line := strings.Repeat("  ", depth) + n.Module.String()
+		if n.Replace != nil {
+			line += " => " + n.Replace.String()
+		}
+		if n.Indirect {
+			line += " indirect"
+		}
+		if n.Cycle {
+			line += " cycle"
+		}
+		lines = append(lines, line)
+		for _, c := range n.Children {
+			render(c, depth+1)
+		}
+	}
+	render(root, 0)
+	got := strings.Join(lines, "\n")
+	want := `example.com/main
+  example.com/a@v1.0.0
+    example.com/b@v1.1.0
+      example.com/a@v1.0.0 cycle
+      example.com/c@v0.1.0 => ../c indirect
+  example.com/b@v1.1.0
+    example.com/a@v1.0.0 cycle
+    example.com/c@v0.1.0 => ../c indirect`
+	if got != want {
+		t.Errorf("moduleTree() =\n%s\nwant:\n%s", got, want)
+	}
+
+	if _, err := parseModGraph("a b c"); err == nil {
+		t.Error("parseModGraph(malformed) succeeded, want error")
+	}
+}
+
+func TestImportPathForDir(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	files := map[string]string{
+		"go.mod":         "module example.com\n\ngo 1.18\n\nrequire example.org/dep v0.0.0\n\nreplace example.org/dep => ./dep\n",
+		"a/b/b.go":       "package b\n",
+		"dep/go.mod":     "module example.org/dep\n\ngo 1.18\n",
+		"dep/sub/sub.go": "package sub\n",
+		"nested/go.mod":  "module example.net/nested\n\ngo 1.18\n",
+		"nested/n/n.go":  "package n\n",
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	view, snapshot := newTestSnapshot(ctx, t, files, nil)
+	if _, err := snapshot.ActiveMetadata(ctx); err != nil {
+		t.Fatal(err)
+	}
+	folder := view.Folder().Filename()
+
+	for _, test := range []struct {
+		dir  string
+		want source.ImportPath // "" means an error is expected
+	}{
+		{".", "example.com"},
+		{"a/b", "example.com/a/b"},
+		{"dep", "example.org/dep"},
+		{"dep/sub", "example.org/dep/sub"},
+		{"nested/n", ""},
+		{"..", ""},
+	} {
+		dir := span.URIFromPath(filepath.Join(folder, filepath.FromSlash(test.dir)))
+		got, err := snapshot.ImportPathForDir(ctx, dir)
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("ImportPathForDir(%s) = %s, want error", test.dir, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ImportPathForDir(%s) failed: %v", test.dir, err)
+		} else if got != test.want {
+			t.Errorf("ImportPathForDir(%s) = %s, want %s", test.dir, got, test.want)
+		}
+	}
+}
@@ -5,16 +5,20 @@
 package cache
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"go/ast"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
 	"golang.org/x/mod/modfile"
+	"golang.org/x/sync/errgroup"
 	"golang.org/x/tools/gopls/internal/lsp/command"
 	"golang.org/x/tools/gopls/internal/lsp/protocol"
 	"golang.org/x/tools/gopls/internal/lsp/source"
@@ -90,6 +94,51 @@ func (s *snapshot) ModTidy(ctx context.Context, pm *source.ParsedModule) (*sourc
 	return res.tidied, res.err
 }
 
+// UntidyModules runs ModTidy for each active module concurrently, and so
+// shares its cached results, which are invalidated by changes to the Go
+// files of the workspace as well as to the go.mod file itself.
+func (s *snapshot) UntidyModules(ctx context.Context) ([]span.URI, error) {
+	if criticalErr := s.GetCriticalError(ctx); criticalErr != nil {
+		return nil, criticalErr.MainError
+	}
+	var (
+		group    errgroup.Group
+		resultMu sync.Mutex
+		result   []span.URI
+	)
+	for modURI := range s.workspace.ActiveModFiles() {
+		modURI := modURI
+		group.Go(func() error {
+			fh, err := s.GetFile(ctx, modURI)
+			if err != nil {
+				return err
+			}
+			pm, err := s.ParseMod(ctx, fh)
+			if err != nil {
+				return err
+			}
+			tidied, err := s.ModTidy(ctx, pm)
+			if err == source.ErrNoModOnDisk {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(tidied.TidiedContent, pm.Mapper.Content) {
+				resultMu.Lock()
+				result = append(result, modURI)
+				resultMu.Unlock()
+			}
+			return nil
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result, nil
+}
+
 // modTidyImpl runs "go mod tidy" on a go.mod file.
 func modTidyImpl(ctx context.Context, snapshot *snapshot, filename string, pm *source.ParsedModule) (*source.TidiedModule, error) {
 	ctx, done := event.Start(ctx, "cache.ModTidy", tag.URI.Of(filename))
@@ -108,7 +157,11 @@ func modTidyImpl(ctx context.Context, snapshot *snapshot, filename string, pm *s
 	// Keep the temporary go.mod file around long enough to parse it.
 	defer cleanup()
 
-	if _, err := snapshot.view.gocmdRunner.Run(ctx, *inv); err != nil {
+	err = snapshot.withGoCommandTimeout(ctx, source.WriteTemporaryModFile, func(ctx context.Context) error {
+		_, err := snapshot.view.gocmdRunner.Run(ctx, *inv)
+		return err
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -282,6 +335,104 @@ func modTidyDiagnostics(ctx context.Context, snapshot *snapshot, pm *source.Pars
 	return diagnostics, nil
 }
 
+// UnusedRequires returns diagnostics for requirements of the module pm that
+// are not used by any of its packages, as determined by the metadata graph.
+//
+// The metadata reflects a single GOOS, GOARCH, and set of build tags, so a
+// requirement may still be needed by files of other build configurations.
+// The diagnostics are therefore informational and offer no quick fix to
+// remove the requirement; ModTidy reports the requirements that
+// "go mod tidy" would remove.
+func (s *snapshot) UnusedRequires(ctx context.Context, pm *source.ParsedModule) ([]*source.Diagnostic, error) {
+	if pm.File == nil {
+		return nil, fmt.Errorf("cannot check requirements of unparseable go.mod file: %v", pm.URI)
+	}
+	if err := s.awaitLoaded(ctx); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	g := s.meta
+	s.mu.Unlock()
+
+	var diagnostics []*source.Diagnostic
+	for _, req := range unusedRequires(pm, g) {
+		rng, err := pm.Mapper.OffsetRange(req.Syntax.Start.Byte, req.Syntax.End.Byte)
+		if err != nil {
+			return nil, err
+		}
+		diagnostics = append(diagnostics, &source.Diagnostic{
+			URI:      pm.URI,
+			Range:    rng,
+			Severity: protocol.SeverityInformation,
+			Source:   source.ModTidyError,
+			Message:  fmt.Sprintf("%s is not used by the packages of this module in the current build configuration", req.Mod.Path),
+		})
+	}
+	return diagnostics, nil
+}
+
+// unusedRequires returns the requirements of pm that are not used by the
+// packages of its module in g.
+//
+// A direct requirement is unused if no package of the module imports a
+// package it provides. An indirect requirement is unused only if it provides
+// no package in the transitive imports of the module's packages.
+func unusedRequires(pm *source.ParsedModule, g *metadataGraph) []*modfile.Require {
+	imported := make(map[string]bool)  // module paths imported directly
+	reachable := make(map[string]bool) // module paths imported transitively
+	seen := make(map[PackageID]bool)
+	var visit func(id PackageID)
+	visit = func(id PackageID) {
+		if seen[id] {
+			return
+		}
+		seen[id] = true
+		m := g.metadata[id]
+		if m == nil {
+			return
+		}
+		if m.Module != nil {
+			reachable[m.Module.Path] = true
+		}
+		for _, depID := range m.DepsByPkgPath {
+			visit(depID)
+		}
+	}
+	for id, m := range g.metadata {
+		if m.Module == nil || moduleGoMod(m) != pm.URI {
+			continue
+		}
+		for _, depID := range m.DepsByPkgPath {
+			if dep := g.metadata[depID]; dep != nil && dep.Module != nil {
+				imported[dep.Module.Path] = true
+			}
+		}
+		visit(id)
+	}
+
+	var unused []*modfile.Require
+	for _, req := range pm.File.Require {
+		if req.Indirect && !reachable[req.Mod.Path] || !req.Indirect && !imported[req.Mod.Path] {
+			unused = append(unused, req)
+		}
+	}
+	return unused
+}
+
+// moduleGoMod returns the URI of the go.mod file of the module of m, or ""
+// if m has no module. Module.GoMod names the temporary modfile if the go
+// command was run with -modfile, so the module directory is preferred.
+func moduleGoMod(m *source.Metadata) span.URI {
+	switch {
+	case m.Module == nil:
+		return ""
+	case m.Module.Dir != "":
+		return modURI(span.URIFromPath(m.Module.Dir))
+	default:
+		return span.URIFromPath(m.Module.GoMod)
+	}
+}
+
 // unusedDiagnostic returns a source.Diagnostic for an unused require.
 func unusedDiagnostic(m *protocol.Mapper, req *modfile.Require, onlyDiagnostic bool) (*source.Diagnostic, error) {
 	rng, err := m.OffsetRange(req.Syntax.Start.Byte, req.Syntax.End.Byte)
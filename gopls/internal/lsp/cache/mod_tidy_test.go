@@ -0,0 +1,126 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/lsp/source"
+	"golang.org/x/tools/gopls/internal/span"
+	"golang.org/x/tools/internal/testenv"
+)
+
+func TestUnusedRequires(t *testing.T) {
+	const content = `module example.com/a
+
+go 1.18
+
+require (
+	example.com/b v1.0.0
+	example.com/c v1.0.0
+	example.com/d v1.0.0 // indirect
+	example.com/e v1.0.0 // indirect
+)
+`
+	gomod := filepath.FromSlash("/a/go.mod")
+	uri := span.URIFromPath(gomod)
+	file, err := modfile.Parse(gomod, []byte(content), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pm := &source.ParsedModule{
+		URI:    uri,
+		File:   file,
+		Mapper: protocol.NewMapper(uri, []byte(content)),
+	}
+
+	mod := func(path string) *packages.Module {
+		return &packages.Module{Path: path, GoMod: filepath.FromSlash("/" + path + "/go.mod")}
+	}
+	g := &metadataGraph{metadata: map[PackageID]*source.Metadata{
+		"example.com/a": {
+			ID:            "example.com/a",
+			Module:        &packages.Module{Path: "example.com/a", GoMod: gomod},
+			DepsByPkgPath: map[PackagePath]PackageID{"example.com/b": "example.com/b"},
+		},
+		"example.com/b": {
+			ID:            "example.com/b",
+			Module:        mod("example.com/b"),
+			DepsByPkgPath: map[PackagePath]PackageID{"example.com/d": "example.com/d"},
+		},
+		"example.com/d": {
+			ID:     "example.com/d",
+			Module: mod("example.com/d"),
+		},
+	}}
+
+	var got []string
+	for _, req := range unusedRequires(pm, g) {
+		got = append(got, req.Mod.Path)
+	}
+	want := []string{"example.com/c", "example.com/e"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unusedRequires() = %v, want %v", got, want)
+	}
+}
+
+func TestModuleGoMod(t *testing.T) {
+	gomod := span.URIFromPath(filepath.FromSlash("/src/a/go.mod"))
+	for _, test := range []struct {
+		module *packages.Module
+		want   span.URI
+	}{
+		{nil, ""},
+		{&packages.Module{Path: "a", Dir: filepath.FromSlash("/src/a"), GoMod: filepath.FromSlash("/tmp/go.123.mod")}, gomod},
+		{&packages.Module{Path: "a", GoMod: filepath.FromSlash("/src/a/go.mod")}, gomod},
+	} {
+		if got := moduleGoMod(&source.Metadata{Module: test.module}); got != test.want {
+			t.Errorf("moduleGoMod(%+v) = %s, want %s", test.module, got, test.want)
+		}
+	}
+}
+
+func TestUnusedRequiresDiagnostics(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	// The dependency is imported only by a file excluded by build tags.
+	files := map[string]string{
+		"go.mod":     "module example.com\n\ngo 1.18\n\nrequire example.org/dep v0.0.0\n\nreplace example.org/dep => ./dep\n",
+		"a/a.go":     "package a\n",
+		"a/tag.go":   "//go:build sometag\n\npackage a\n\nimport _ \"example.org/dep\"\n",
+		"dep/go.mod": "module example.org/dep\n\ngo 1.18\n",
+		"dep/dep.go": "package dep\n",
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	view, snapshot := newTestSnapshot(ctx, t, files, nil)
+	fh, err := snapshot.GetFile(ctx, span.URIFromPath(filepath.Join(view.Folder().Filename(), "go.mod")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pm, err := snapshot.ParseMod(ctx, fh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diags, err := snapshot.UnusedRequires(ctx, pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diags) != 1 {
+		t.Fatalf("UnusedRequires() = %v, want 1 diagnostic", diags)
+	}
+	// Another build configuration may need the requirement, so it must
+	// not be offered for removal.
+	if d := diags[0]; d.Severity != protocol.SeverityInformation || len(d.SuggestedFixes) > 0 {
+		t.Errorf("UnusedRequires() = %+v, want an informational diagnostic without fixes", d)
+	}
+}
@@ -7,6 +7,7 @@ package cache
 import (
 	"context"
 	"os"
+	"sync"
 
 	"golang.org/x/tools/gopls/internal/govulncheck"
 	"golang.org/x/tools/gopls/internal/lsp/source"
@@ -18,49 +19,144 @@ import (
 // ModVuln returns import vulnerability analysis for the given go.mod URI.
 // Concurrent requests are combined into a single command.
 func (s *snapshot) ModVuln(ctx context.Context, modURI span.URI) (*govulncheck.Result, error) {
-	s.mu.Lock()
-	entry, hit := s.modVulnHandles.Get(modURI)
-	s.mu.Unlock()
+	h, err := s.modVulnHandle(ctx, modURI)
+	if err != nil {
+		return nil, err
+	}
+
+	// Await result.
+	v, err := s.awaitPromise(ctx, h.promise)
+	if err != nil {
+		return nil, err
+	}
+	res := v.(modVulnResult)
+	return res.result, res.err
+}
 
-	type modVuln struct {
-		result *govulncheck.Result
-		err    error
+// ModVulnAsync is like ModVuln, but runs the analysis in the background,
+// calling onProgress (if non-nil) with the fraction of work completed.
+//
+// The returned channel receives the result, and is then closed. If the
+// analysis fails or ctx is cancelled, the channel is closed without a value.
+//
+// As with ModVuln, concurrent requests share a single underlying analysis,
+// which is cancelled only once all of its callers have been cancelled.
+func (s *snapshot) ModVulnAsync(ctx context.Context, modURI span.URI, onProgress func(fraction float64)) (<-chan *govulncheck.Result, error) {
+	h, err := s.modVulnHandle(ctx, modURI)
+	if err != nil {
+		return nil, err
 	}
+	unsubscribe := h.progress.subscribe(onProgress)
+	release := s.Acquire()
+	ch := make(chan *govulncheck.Result, 1)
+	go func() {
+		defer close(ch)
+		defer release()
+		defer unsubscribe()
 
-	// Cache miss?
-	if !hit {
-		// If the file handle is an overlay, it may not be written to disk.
-		// The go.mod file has to be on disk for vulncheck to work.
-		//
-		// TODO(hyangah): use overlays for vulncheck.
-		fh, err := s.GetFile(ctx, modURI)
+		v, err := s.awaitPromise(ctx, h.promise)
 		if err != nil {
-			return nil, err
+			return
 		}
-		if _, ok := fh.(*overlay); ok {
-			if info, _ := os.Stat(modURI.Filename()); info == nil {
-				return nil, source.ErrNoModOnDisk
-			}
+		if res := v.(modVulnResult); res.err == nil {
+			ch <- res.result
 		}
+	}()
+	return ch, nil
+}
 
-		handle := memoize.NewPromise("modVuln", func(ctx context.Context, arg interface{}) interface{} {
-			result, err := modVulnImpl(ctx, arg.(*snapshot), modURI)
-			return modVuln{result, err}
-		})
+type modVulnResult struct {
+	result *govulncheck.Result
+	err    error
+}
+
+// A modVulnHandle is the memoized vulnerability analysis of a go.mod file,
+// along with the progress of that analysis.
+type modVulnHandle struct {
+	promise  *memoize.Promise // [modVulnResult]
+	progress *vulnProgress
+}
+
+// modVulnHandle returns the handle for the vulnerability analysis of modURI,
+// creating it if necessary.
+func (s *snapshot) modVulnHandle(ctx context.Context, modURI span.URI) (*modVulnHandle, error) {
+	s.mu.Lock()
+	entry, hit := s.modVulnHandles.Get(modURI)
+	s.mu.Unlock()
 
-		entry = handle
-		s.mu.Lock()
-		s.modVulnHandles.Set(modURI, entry, nil)
-		s.mu.Unlock()
+	// Cache hit?
+	if hit {
+		return entry.(*modVulnHandle), nil
 	}
 
-	// Await result.
-	v, err := s.awaitPromise(ctx, entry.(*memoize.Promise))
+	// If the file handle is an overlay, it may not be written to disk.
+	// The go.mod file has to be on disk for vulncheck to work.
+	//
+	// TODO(hyangah): use overlays for vulncheck.
+	fh, err := s.GetFile(ctx, modURI)
 	if err != nil {
 		return nil, err
 	}
-	res := v.(modVuln)
-	return res.result, res.err
+	if _, ok := fh.(*overlay); ok {
+		if info, _ := os.Stat(modURI.Filename()); info == nil {
+			return nil, source.ErrNoModOnDisk
+		}
+	}
+
+	progress := new(vulnProgress)
+	h := &modVulnHandle{
+		promise: memoize.NewPromise("modVuln", func(ctx context.Context, arg interface{}) interface{} {
+			ctx = vulncheck.WithProgress(ctx, progress.report)
+			result, err := modVulnImpl(ctx, arg.(*snapshot), modURI)
+			return modVulnResult{result, err}
+		}),
+		progress: progress,
+	}
+
+	s.mu.Lock()
+	s.modVulnHandles.Set(modURI, h, nil)
+	s.mu.Unlock()
+	return h, nil
+}
+
+// A vulnProgress broadcasts the progress of a vulnerability analysis to its
+// subscribers.
+type vulnProgress struct {
+	mu          sync.Mutex
+	fraction    float64
+	nextID      int
+	subscribers map[int]func(float64)
+}
+
+// subscribe arranges for f to be called with each progress update, starting
+// with the current progress. It returns a function that unsubscribes f.
+func (p *vulnProgress) subscribe(f func(float64)) func() {
+	if f == nil {
+		return func() {}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.subscribers == nil {
+		p.subscribers = make(map[int]func(float64))
+	}
+	id := p.nextID
+	p.nextID++
+	p.subscribers[id] = f
+	f(p.fraction)
+	return func() {
+		p.mu.Lock()
+		delete(p.subscribers, id)
+		p.mu.Unlock()
+	}
+}
+
+func (p *vulnProgress) report(fraction float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fraction = fraction
+	for _, f := range p.subscribers {
+		f(fraction)
+	}
 }
 
 func modVulnImpl(ctx context.Context, s *snapshot, uri span.URI) (*govulncheck.Result, error) {
@@ -0,0 +1,133 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/tools/gopls/internal/lsp/source"
+	"golang.org/x/tools/gopls/internal/span"
+	"golang.org/x/tools/internal/gocommand"
+)
+
+// ModuleDependencyTree combines the selected versions reported by
+// "go list -m -json all" with the requirement edges reported by
+// "go mod graph".
+func (s *snapshot) ModuleDependencyTree(ctx context.Context, modURI span.URI) (*source.ModuleNode, error) {
+	dir := filepath.Dir(modURI.Filename())
+	stdout, err := s.RunGoCommandDirect(ctx, source.Normal, &gocommand.Invocation{
+		Verb:       "list",
+		Args:       []string{"-m", "-json", "all"},
+		WorkingDir: dir,
+	})
+	if err != nil {
+		return nil, err
+	}
+	modules, err := parseModuleList(stdout.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	stdout, err = s.RunGoCommandDirect(ctx, source.Normal, &gocommand.Invocation{
+		Verb:       "mod",
+		Args:       []string{"graph"},
+		WorkingDir: dir,
+	})
+	if err != nil {
+		return nil, err
+	}
+	graph, err := parseModGraph(stdout.String())
+	if err != nil {
+		return nil, err
+	}
+	return moduleTree(modules, graph)
+}
+
+// parseModGraph parses the output of "go mod graph", returning the
+// requirements of each module version. Pseudo-modules for the go version and
+// toolchain are ignored.
+func parseModGraph(out string) (map[module.Version][]module.Version, error) {
+	graph := make(map[module.Version][]module.Version)
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("malformed module graph line: %q", line)
+		}
+		from, to := parseModuleVersion(fields[0]), parseModuleVersion(fields[1])
+		if to.Path == "go" || to.Path == "toolchain" {
+			continue
+		}
+		graph[from] = append(graph[from], to)
+	}
+	return graph, nil
+}
+
+// parseModuleVersion parses a module as printed by "go mod graph", such as
+// "golang.org/x/mod@v0.7.0", or "example.com/main" for a main module.
+func parseModuleVersion(s string) module.Version {
+	if i := strings.LastIndexByte(s, '@'); i >= 0 {
+		return module.Version{Path: s[:i], Version: s[i+1:]}
+	}
+	return module.Version{Path: s}
+}
+
+// moduleTree returns the tree of the requirement graph rooted at the main
+// module of modules, the module list reported by "go list -m -json all".
+// Each required module is represented at its selected version, whose
+// requirements are its children.
+func moduleTree(modules []listedModule, graph map[module.Version][]module.Version) (*source.ModuleNode, error) {
+	if len(modules) == 0 || !modules[0].Main {
+		return nil, fmt.Errorf("module list does not start with a main module")
+	}
+	selected := make(map[string]listedModule)
+	for _, m := range modules {
+		selected[m.Path] = m
+	}
+
+	nodes := make(map[string]*source.ModuleNode) // by path, complete or in progress
+	inProgress := make(map[string]bool)
+	var visit func(m listedModule) *source.ModuleNode
+	visit = func(m listedModule) *source.ModuleNode {
+		if n, ok := nodes[m.Path]; ok {
+			if inProgress[m.Path] {
+				return &source.ModuleNode{Module: n.Module, Replace: n.Replace, Indirect: n.Indirect, Cycle: true}
+			}
+			return n
+		}
+		n := &source.ModuleNode{
+			Module:   module.Version{Path: m.Path, Version: m.Version},
+			Indirect: m.Indirect,
+		}
+		if m.Replace != nil {
+			n.Replace = &module.Version{Path: m.Replace.Path, Version: m.Replace.Version}
+		}
+		nodes[m.Path] = n
+		inProgress[m.Path] = true
+
+		seen := make(map[string]bool)
+		for _, req := range graph[n.Module] {
+			dep, ok := selected[req.Path]
+			if !ok || dep.Main || seen[req.Path] {
+				continue // e.g. a requirement pruned from the module graph
+			}
+			seen[req.Path] = true
+			n.Children = append(n.Children, visit(dep))
+		}
+		sort.Slice(n.Children, func(i, j int) bool {
+			return n.Children[i].Module.Path < n.Children[j].Module.Path
+		})
+
+		delete(inProgress, m.Path)
+		return n
+	}
+	return visit(modules[0]), nil
+}
@@ -0,0 +1,39 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"testing"
+
+	"golang.org/x/tools/gopls/internal/lsp/source"
+)
+
+func TestOrphanReason(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"//go:build ignore\n\npackage p\n", "excluded by build constraint ignore"},
+		{"package q\n", "package q does not match package p of other files"},
+		{"package q_test\n", "package q_test does not match package p of other files"},
+		{"package p_test\n", "not included in any loaded package"},
+		{"package p\n\nfunc (\n", "syntax error: orphan.go:3:8: expected ')', found 'EOF'"},
+		{"func f() {}\n", "syntax error: orphan.go:1:1: expected 'package', found 'func'"},
+	}
+	for _, test := range tests {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "orphan.go", test.src, parser.ParseComments)
+		pgf := &source.ParsedGoFile{File: f}
+		if list, ok := err.(scanner.ErrorList); ok {
+			pgf.ParseErr = list
+		}
+		if got := orphanReason(pgf, map[string]bool{"p": true}); got != test.want {
+			t.Errorf("orphanReason(%q) = %q, want %q", test.src, got, test.want)
+		}
+	}
+}
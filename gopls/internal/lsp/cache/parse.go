@@ -60,6 +60,7 @@ func (s *snapshot) ParseGo(ctx context.Context, fh source.FileHandle, mode sourc
 	s.mu.Lock()
 	entry, hit := s.parsedGoFiles.Get(key)
 	s.mu.Unlock()
+	s.view.stats.record(&s.view.stats.parseHits, &s.view.stats.parseMisses, hit)
 
 	// cache miss?
 	if !hit {
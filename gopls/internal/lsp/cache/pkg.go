@@ -176,6 +176,16 @@ func (p *pkg) DiagnosticsForFile(uri span.URI) []*source.Diagnostic {
 	return res
 }
 
+func (p *pkg) DiagnosticsForFileBySource(uri span.URI, src source.DiagnosticSource) []*source.Diagnostic {
+	var res []*source.Diagnostic
+	for _, diag := range p.diagnostics {
+		if diag.URI == uri && diag.Source == src {
+			res = append(res, diag)
+		}
+	}
+	return res
+}
+
 func (p *pkg) ReferencesTo(pkgPath PackagePath, objPath objectpath.Path) []protocol.Location {
 	// TODO(adonovan): In future, p.xrefs will be retrieved from a
 	// section of the cache file produced by type checking.
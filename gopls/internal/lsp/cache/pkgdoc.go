@@ -0,0 +1,45 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/doc"
+	"go/parser"
+)
+
+// PackageDoc returns the documentation of the package with the given id.
+//
+// go/doc modifies the syntax trees it is given, so rather than the trees
+// shared by the type-checked package, it documents a private parse of the
+// package's compiled Go files. Files that fail to parse are documented as
+// far as they could be parsed.
+func (s *snapshot) PackageDoc(ctx context.Context, id PackageID) (*doc.Package, error) {
+	if err := s.awaitLoaded(ctx); err != nil {
+		return nil, err
+	}
+	m := s.Metadata(id)
+	if m == nil {
+		return nil, fmt.Errorf("no metadata for %s", id)
+	}
+	var files []*ast.File
+	for _, uri := range m.CompiledGoFiles {
+		fh, err := s.GetFile(ctx, uri)
+		if err != nil {
+			return nil, err
+		}
+		src, err := fh.Read()
+		if err != nil {
+			return nil, err
+		}
+		f, _ := parser.ParseFile(s.FileSet(), uri.Filename(), src, parser.ParseComments)
+		if f != nil {
+			files = append(files, f)
+		}
+	}
+	return doc.NewFromFiles(s.FileSet(), files, string(m.PkgPath))
+}
@@ -0,0 +1,66 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"testing"
+
+	"golang.org/x/tools/gopls/internal/lsp/source"
+	"golang.org/x/tools/internal/testenv"
+)
+
+// TestPackageDocPreservesSyntax checks that computing the documentation of
+// a package does not modify the syntax trees of the type-checked package.
+func TestPackageDocPreservesSyntax(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	files := map[string]string{
+		"go.mod": "module example.com\n\ngo 1.18\n",
+		"p/p.go": `// Package p is documented.
+package p
+
+// F is exported.
+func F() {}
+
+func f() {}
+
+// T has an unexported field.
+type T struct {
+	X int
+	y int
+}
+`,
+	}
+	ctx := context.Background()
+	_, snapshot := newTestSnapshot(ctx, t, files, nil)
+	if _, err := snapshot.ActiveMetadata(ctx); err != nil { // await loading
+		t.Fatal(err)
+	}
+	pkgs, err := snapshot.TypeCheck(ctx, source.TypecheckFull, "example.com/p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	syntax := pkgs[0].GetSyntax()[0]
+	ndecls := len(syntax.Decls)
+
+	doc, err := snapshot.PackageDoc(ctx, "example.com/p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := doc.Doc, "Package p is documented.\n"; got != want {
+		t.Errorf("package doc = %q, want %q", got, want)
+	}
+	if len(doc.Funcs) != 1 || doc.Funcs[0].Name != "F" {
+		t.Errorf("documented functions = %v, want only F", doc.Funcs)
+	}
+	if len(doc.Types) != 1 || doc.Types[0].Name != "T" {
+		t.Errorf("documented types = %v, want only T", doc.Types)
+	}
+
+	if got := len(syntax.Decls); got != ndecls {
+		t.Errorf("after PackageDoc, the package syntax has %d declarations, want %d", got, ndecls)
+	}
+}
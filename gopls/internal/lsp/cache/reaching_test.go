@@ -0,0 +1,134 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/span"
+	"golang.org/x/tools/internal/testenv"
+)
+
+func TestTestsReaching(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	const src = `package a
+
+type Stringer interface{ String() string }
+
+func Direct() {}
+
+func helper() { Direct() }
+
+func ByValue() {}
+
+func run(f func()) { f() }
+
+func Dynamic() {}
+
+type T struct{}
+
+func (T) String() string {
+	Dynamic()
+	return ""
+}
+
+func Initialized() int { return 0 }
+
+var x = Initialized()
+`
+	files := map[string]string{
+		"go.mod": "module example.com\n\ngo 1.18\n",
+		"a/a.go": src,
+		"b/b.go": `package b
+
+type U struct{}
+
+func (U) String() string {
+	Formatted()
+	return ""
+}
+
+func Formatted() {}
+`,
+		"b/b_test.go": `package b
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSprint(t *testing.T) { _ = fmt.Sprint(U{}) }
+
+func TestNothing(t *testing.T) {}
+`,
+		"a/a_test.go": `package a
+
+import "testing"
+
+func TestDirect(t *testing.T) { helper() }
+
+func TestValue(t *testing.T) { run(ByValue) }
+
+func TestIface(t *testing.T) {
+	var s Stringer = T{}
+	_ = s.String()
+}
+
+func TestOther(t *testing.T) {}
+`,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	view, snapshot := newTestSnapshot(ctx, t, files, nil)
+	if _, err := snapshot.ActiveMetadata(ctx); err != nil {
+		t.Fatal(err)
+	}
+	uri := span.URIFromPath(filepath.Join(view.Folder().Filename(), "a", "a.go"))
+
+	all := []string{"TestDirect", "TestIface", "TestOther", "TestValue"}
+	for _, test := range []struct {
+		fn   string
+		want []string
+	}{
+		{"Direct", []string{"TestDirect"}},
+		{"helper", []string{"TestDirect"}},
+		{"ByValue", all}, // referenced as a function value
+		{"Dynamic", all}, // called by a method that may satisfy Stringer
+		{"Initialized", all},
+	} {
+		line := strings.Count(src[:strings.Index(src, "func "+test.fn)], "\n")
+		tests, err := snapshot.TestsReaching(ctx, uri, protocol.Position{Line: uint32(line), Character: 6})
+		if err != nil {
+			t.Fatalf("TestsReaching(%s): %v", test.fn, err)
+		}
+		var got []string
+		for _, test := range tests {
+			got = append(got, test.Name)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("TestsReaching(%s) = %v, want %v", test.fn, got, test.want)
+		}
+	}
+	// U.String is called only through fmt.Stringer, declared in a dependency.
+	buri := span.URIFromPath(filepath.Join(view.Folder().Filename(), "b", "b.go"))
+	tests, err := snapshot.TestsReaching(ctx, buri, protocol.Position{Line: 9, Character: 6})
+	if err != nil {
+		t.Fatalf("TestsReaching(Formatted): %v", err)
+	}
+	var got []string
+	for _, test := range tests {
+		got = append(got, test.Name)
+	}
+	if want := []string{"TestNothing", "TestSprint"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("TestsReaching(Formatted) = %v, want %v", got, want)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -35,9 +36,10 @@ type Session struct {
 	optionsMu sync.Mutex
 	options   *source.Options
 
-	viewMu  sync.Mutex
-	views   []*View
-	viewMap map[span.URI]*View // map of URI->best view
+	viewMu       sync.Mutex
+	views        []*View
+	viewMap      map[span.URI]*View   // map of URI->best view
+	onViewChange func(old, new *View) // may be nil
 
 	overlayMu sync.Mutex
 	overlays  map[span.URI]*overlay
@@ -136,6 +138,31 @@ func (s *Session) SetOptions(options *source.Options) {
 	s.optionsMu.Lock()
 	defer s.optionsMu.Unlock()
 	s.options = options
+	s.gocmdRunner.SetMaxConcurrency(options.GoCommandConcurrency)
+}
+
+// GoCommandStats reports the number of go commands run by the session that
+// are running, and the number that are waiting to run.
+func (s *Session) GoCommandStats() (active, queued int) {
+	return s.gocmdRunner.Stats()
+}
+
+// SetViewChangeHandler sets the function called whenever a view of the
+// session is recreated, with the old and new views, or removed, with a nil
+// new view. The handler is called synchronously, with the session's view
+// lock held, so it must not call methods of the Session.
+func (s *Session) SetViewChangeHandler(handler func(old, new *View)) {
+	s.viewMu.Lock()
+	defer s.viewMu.Unlock()
+	s.onViewChange = handler
+}
+
+// viewChangedLocked calls the view change handler, if any.
+// s.viewMu must be held while calling this function.
+func (s *Session) viewChangedLocked(old, new *View) {
+	if s.onViewChange != nil {
+		s.onViewChange(old, new)
+	}
 }
 
 // Shutdown the session and all views it has created.
@@ -145,6 +172,9 @@ func (s *Session) Shutdown(ctx context.Context) {
 	views = append(views, s.views...)
 	s.views = nil
 	s.viewMap = nil
+	for _, view := range views {
+		s.viewChangedLocked(view, nil)
+	}
 	s.viewMu.Unlock()
 	for _, view := range views {
 		view.shutdown()
@@ -180,6 +210,41 @@ func (s *Session) NewView(ctx context.Context, name string, folder span.URI, opt
 	return view, snapshot, release, nil
 }
 
+// NewCrossView creates a new View for the folder of baseView, whose build
+// configuration overrides GOOS and GOARCH with the given values (if
+// non-empty). This allows checking platform-specific code without changing
+// the user's environment.
+//
+// Cross views never take precedence over other views when choosing the best
+// view for a file.
+func (s *Session) NewCrossView(ctx context.Context, baseView *View, goos, goarch string) (*View, source.Snapshot, func(), error) {
+	if goos == "" && goarch == "" {
+		return nil, nil, nil, fmt.Errorf("cross view for %s requires GOOS or GOARCH", baseView.folder)
+	}
+	s.viewMu.Lock()
+	defer s.viewMu.Unlock()
+
+	target := &View{goos: goos, goarch: goarch}
+	options := target.crossOptions(baseView.Options())
+	var overrides []string
+	if goos != "" {
+		overrides = append(overrides, "GOOS="+goos)
+	}
+	if goarch != "" {
+		overrides = append(overrides, "GOARCH="+goarch)
+	}
+	name := fmt.Sprintf("%s (%s)", baseView.name, strings.Join(overrides, " "))
+	view, snapshot, release, err := s.createView(ctx, name, baseView.folder, options, 0)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	view.goos, view.goarch = goos, goarch
+	s.views = append(s.views, view)
+	// we always need to drop the view map
+	s.viewMap = make(map[span.URI]*View)
+	return view, snapshot, release, nil
+}
+
 func (s *Session) createView(ctx context.Context, name string, folder span.URI, options *source.Options, seqID uint64) (*View, *snapshot, func(), error) {
 	index := atomic.AddInt64(&viewIndex, 1)
 
@@ -216,6 +281,7 @@ func (s *Session) createView(ctx context.Context, name string, folder span.URI,
 	if err != nil {
 		return nil, nil, func() {}, err
 	}
+	workspace.goversion = wsInfo.goversion
 
 	// We want a true background context and not a detached context here
 	// the spans need to be unrelated and no tag values should pollute it.
@@ -228,6 +294,7 @@ func (s *Session) createView(ctx context.Context, name string, folder span.URI,
 		gocmdRunner:          s.gocmdRunner,
 		initialWorkspaceLoad: make(chan struct{}),
 		initializationSema:   make(chan struct{}, 1),
+		stats:                new(cacheCounters),
 		options:              options,
 		baseCtx:              baseCtx,
 		name:                 name,
@@ -241,6 +308,9 @@ func (s *Session) createView(ctx context.Context, name string, folder span.URI,
 		explicitGowork:       goworkURI,
 		workspaceInformation: *wsInfo,
 	}
+	if n := options.TypeCheckConcurrency; n > 0 {
+		v.typeCheckSema = make(chan struct{}, n)
+	}
 	v.importsState = &importsState{
 		ctx: backgroundCtx,
 		processEnv: &imports.ProcessEnv{
@@ -275,6 +345,8 @@ func (s *Session) createView(ctx context.Context, name string, folder span.URI,
 		analyses:             persistent.NewMap(analysisKeyLessInterface),
 		workspacePackages:    make(map[PackageID]PackagePath),
 		unloadableFiles:      make(map[span.URI]struct{}),
+		staleMetadata:        make(map[PackageID]*source.Metadata),
+		excludedOpenFiles:    make(map[span.URI]string),
 		parseModHandles:      persistent.NewMap(uriLessInterface),
 		parseWorkHandles:     persistent.NewMap(uriLessInterface),
 		modTidyHandles:       persistent.NewMap(uriLessInterface),
@@ -351,20 +423,23 @@ func (s *Session) Views() []*View {
 // out of the given set of views.
 func bestViewForURI(uri span.URI, views []*View) *View {
 	// we need to find the best view for this file
-	var longest *View
-	for _, view := range views {
-		if longest != nil && len(longest.Folder()) > len(view.Folder()) {
-			continue
-		}
-		// TODO(rfindley): this should consider the workspace layout (i.e.
-		// go.work).
-		if view.contains(uri) {
-			longest = view
-		}
-	}
-	if longest != nil {
+	//
+	// TODO(rfindley): this should consider the workspace layout (i.e.
+	// go.work).
+	if longest := longestViewContaining(views, func(view *View) bool {
+		return view.contains(uri)
+	}); longest != nil {
 		return longest
 	}
+	// The file may be within a view through a symbolic link, either in the
+	// path of the file or in the path of the view's folder.
+	if resolved := resolveSymlinks(uri); resolved != uri {
+		if longest := longestViewContaining(views, func(view *View) bool {
+			return view.contains(resolved) || source.InDir(resolveSymlinks(view.folder).Filename(), resolved.Filename())
+		}); longest != nil {
+			return longest
+		}
+	}
 	// Try our best to return a view that knows the file.
 	for _, view := range views {
 		if view.knownFile(uri) {
@@ -375,6 +450,24 @@ func bestViewForURI(uri span.URI, views []*View) *View {
 	return views[0]
 }
 
+// longestViewContaining returns the view with the longest folder among the
+// non-cross views for which contains returns true, or nil if there is none.
+func longestViewContaining(views []*View, contains func(*View) bool) *View {
+	var longest *View
+	for _, view := range views {
+		if longest != nil && len(longest.Folder()) > len(view.Folder()) {
+			continue
+		}
+		if view.goos != "" || view.goarch != "" {
+			continue // cross views are only used explicitly
+		}
+		if contains(view) {
+			longest = view
+		}
+	}
+	return longest
+}
+
 // RemoveView removes the view v from the session
 func (s *Session) RemoveView(view *View) {
 	s.viewMu.Lock()
@@ -386,6 +479,7 @@ func (s *Session) RemoveView(view *View) {
 	// delete this view... we don't care about order but we do want to make
 	// sure we can garbage collect the view
 	s.views = removeElement(s.views, i)
+	s.viewChangedLocked(view, nil)
 }
 
 // updateView recreates the view with the given options.
@@ -414,7 +508,7 @@ func (s *Session) updateViewLocked(ctx context.Context, view *View, options *sou
 		return nil, fmt.Errorf("view %q not found", view.id)
 	}
 
-	v, _, release, err := s.createView(ctx, view.name, view.folder, options, seqID)
+	v, _, release, err := s.createView(ctx, view.name, view.folder, view.crossOptions(options), seqID)
 	release()
 
 	if err != nil {
@@ -422,10 +516,13 @@ func (s *Session) updateViewLocked(ctx context.Context, view *View, options *sou
 		// this should not happen and is very bad, but we still need to clean
 		// up the view array if it happens
 		s.views = removeElement(s.views, i)
+		s.viewChangedLocked(view, nil)
 		return nil, err
 	}
+	v.goos, v.goarch = view.goos, view.goarch
 	// substitute the new view into the array where the old view was
 	s.views[i] = v
+	s.viewChangedLocked(view, v)
 	return v, nil
 }
 
@@ -475,6 +572,10 @@ type fileChange struct {
 	// change the actual contents of the file. Opens and closes should not
 	// be treated like other changes, since the file content doesn't change.
 	isUnchanged bool
+
+	// excludedBy is the directory filter that excludes an open file from
+	// the view, or "".
+	excludedBy string
 }
 
 // DidModifyFiles reports a file modification to the session. It returns
@@ -602,6 +703,7 @@ func (s *Session) DidModifyFiles(ctx context.Context, changes []source.FileModif
 					exists:      true,
 					fileHandle:  fh,
 					isUnchanged: isUnchanged,
+					excludedBy:  view.excludingFilter(c.URI),
 				}
 			} else {
 				fsFile, err := s.cache.getFile(ctx, c.URI)
@@ -657,6 +759,89 @@ func (s *Session) DidModifyFiles(ctx context.Context, changes []source.FileModif
 	return snapshotURIs, release, nil
 }
 
+// Reload recreates all views in the session, as if their workspace folders
+// had just been added, while preserving the session's overlays: unsaved edits
+// to open files, and their versions, are carried over to the new views.
+//
+// Like DidModifyFiles, it returns the new snapshots paired with the open
+// files that should be diagnosed in each, along with a release function that
+// must be called when the snapshots are no longer needed.
+func (s *Session) Reload(ctx context.Context) (map[source.Snapshot][]span.URI, func(), error) {
+	s.viewMu.Lock()
+	defer s.viewMu.Unlock()
+
+	// updateViewLocked replaces elements of s.views, so iterate over a copy.
+	for _, view := range append([]*View(nil), s.views...) {
+		if _, err := s.updateViewLocked(ctx, view, view.Options()); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	s.overlayMu.Lock()
+	overlays := make([]*overlay, 0, len(s.overlays))
+	for _, o := range s.overlays {
+		overlays = append(overlays, o)
+	}
+	s.overlayMu.Unlock()
+
+	// The snapshots of the new views have no knowledge of open files, so
+	// apply the overlays to each view containing them.
+	views := make(map[*View]map[span.URI]*fileChange)
+	viewURIs := make(map[*View][]span.URI)
+	for _, o := range overlays {
+		var changedViews []*View
+		for _, view := range s.views {
+			if view.contains(o.uri) {
+				changedViews = append(changedViews, view)
+			}
+		}
+		if len(changedViews) == 0 {
+			bestView, err := s.viewOfLocked(o.uri)
+			if err != nil {
+				return nil, nil, err
+			}
+			changedViews = append(changedViews, bestView)
+		}
+		for _, view := range changedViews {
+			view.canonicalURI(o.uri, true) // ignore result
+			if _, ok := views[view]; !ok {
+				views[view] = make(map[span.URI]*fileChange)
+			}
+			views[view][o.uri] = &fileChange{
+				content:    o.text,
+				exists:     true,
+				fileHandle: o,
+				excludedBy: view.excludingFilter(o.uri),
+			}
+		}
+		view := bestViewForURI(o.uri, changedViews)
+		viewURIs[view] = append(viewURIs[view], o.uri)
+	}
+
+	var releases []func()
+	snapshotURIs := map[source.Snapshot][]span.URI{}
+	for _, view := range s.views {
+		var (
+			snapshot *snapshot
+			release  func()
+		)
+		if changed, ok := views[view]; ok {
+			snapshot, release = view.invalidateContent(ctx, changed, false)
+		} else {
+			snapshot, release = view.getSnapshot()
+		}
+		releases = append(releases, release)
+		snapshotURIs[snapshot] = viewURIs[view]
+	}
+
+	release := func() {
+		for _, release := range releases {
+			release()
+		}
+	}
+	return snapshotURIs, release, nil
+}
+
 // ExpandModificationsToDirectories returns the set of changes with the
 // directory changes removed and expanded to include all of the files in
 // the directory.
@@ -866,6 +1051,22 @@ func (s *Session) Overlays() []source.Overlay {
 	return overlays
 }
 
+// UnsavedOverlays returns the sorted URIs of the session's overlays whose
+// content differs from the state on disk.
+func (s *Session) UnsavedOverlays() []span.URI {
+	s.overlayMu.Lock()
+	defer s.overlayMu.Unlock()
+
+	var uris []span.URI
+	for uri, overlay := range s.overlays {
+		if !overlay.saved {
+			uris = append(uris, uri)
+		}
+	}
+	sort.Slice(uris, func(i, j int) bool { return uris[i] < uris[j] })
+	return uris
+}
+
 // FileWatchingGlobPatterns returns glob patterns to watch every directory
 // known by the view. For views within a module, this is the module root,
 // any directory in the module root, and any replace targets.
@@ -874,11 +1075,9 @@ func (s *Session) FileWatchingGlobPatterns(ctx context.Context) map[string]struc
 	defer s.viewMu.Unlock()
 	patterns := map[string]struct{}{}
 	for _, view := range s.views {
-		snapshot, release := view.getSnapshot()
-		for k, v := range snapshot.fileWatchingGlobPatterns(ctx) {
+		for k, v := range view.WatchPatterns(ctx) {
 			patterns[k] = v
 		}
-		release()
 	}
 	return patterns
 }
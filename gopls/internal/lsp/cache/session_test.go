@@ -0,0 +1,188 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/gopls/internal/lsp/source"
+	"golang.org/x/tools/gopls/internal/span"
+	"golang.org/x/tools/internal/testenv"
+)
+
+func TestReloadPreservesOverlays(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	folder := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod": "module example.com\n\ngo 1.18\n",
+		"a.go":   "package a\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(folder, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	session := NewSession(ctx, New(nil, nil), nil)
+	defer session.Shutdown(context.Background())
+	options := source.DefaultOptions().Clone()
+	options.Env = map[string]string{"GOPACKAGESDRIVER": "off", "GOROOT": ""}
+	view, _, release, err := session.NewView(ctx, "test", span.URIFromPath(folder), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+
+	uri := span.URIFromPath(filepath.Join(folder, "a.go"))
+	const unsaved = "package a\n\nvar A int\n"
+	_, release, err = session.DidModifyFiles(ctx, []source.FileModification{{
+		URI:        uri,
+		Action:     source.Open,
+		Version:    3,
+		Text:       []byte(unsaved),
+		LanguageID: "go",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+
+	snapshots, release, err := session.Reload(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	if views := session.Views(); len(views) != 1 || views[0] == view {
+		t.Fatalf("Reload: got views %v, want a single new view", views)
+	}
+	if len(snapshots) != 1 {
+		t.Fatalf("Reload returned %d snapshots, want 1", len(snapshots))
+	}
+	for snapshot, uris := range snapshots {
+		if snapshot.View() != session.Views()[0] {
+			t.Errorf("Reload returned a snapshot of view %v, want the new view", snapshot.View())
+		}
+		if len(uris) != 1 || uris[0] != uri {
+			t.Errorf("Reload returned files %v to diagnose, want [%s]", uris, uri)
+		}
+		fh, err := snapshot.GetFile(ctx, uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := fh.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != unsaved {
+			t.Errorf("content of %s after Reload = %q, want the unsaved %q", uri, content, unsaved)
+		}
+		if vfh, ok := fh.(source.VersionedFileHandle); !ok || vfh.Version() != 3 {
+			t.Errorf("file handle of %s after Reload = %v, want an overlay at version 3", uri, fh)
+		}
+	}
+}
+
+func TestViewChangeHandler(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	folder := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(folder, "go.mod"), []byte("module example.com\n\ngo 1.18\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	session := NewSession(ctx, New(nil, nil), nil)
+	defer session.Shutdown(context.Background())
+	options := source.DefaultOptions().Clone()
+	options.Env = map[string]string{"GOPACKAGESDRIVER": "off", "GOROOT": ""}
+	view, _, release, err := session.NewView(ctx, "test", span.URIFromPath(folder), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+
+	type change struct{ old, new *View }
+	var changes []change
+	session.SetViewChangeHandler(func(old, new *View) {
+		changes = append(changes, change{old, new})
+	})
+
+	_, release, err = session.Reload(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	newView := session.Views()[0]
+	if len(changes) != 1 || changes[0] != (change{view, newView}) {
+		t.Fatalf("after Reload, view changes = %v, want [{%p %p}]", changes, view, newView)
+	}
+
+	session.RemoveView(newView)
+	if len(changes) != 2 || changes[1] != (change{newView, nil}) {
+		t.Errorf("after RemoveView, view changes = %v, want a second change {%p <nil>}", changes, newView)
+	}
+}
+
+func TestReloadExcludedOpenFile(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	folder := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(folder, "excluded"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"go.mod":        "module example.com\n\ngo 1.18\n",
+		"a.go":          "package a\n",
+		"excluded/x.go": "package x\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(folder, filepath.FromSlash(name)), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	session := NewSession(ctx, New(nil, nil), nil)
+	defer session.Shutdown(context.Background())
+	options := source.DefaultOptions().Clone()
+	options.Env = map[string]string{"GOPACKAGESDRIVER": "off", "GOROOT": ""}
+	options.DirectoryFilters = []string{"-excluded"}
+	_, _, release, err := session.NewView(ctx, "test", span.URIFromPath(folder), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+
+	uri := span.URIFromPath(filepath.Join(folder, "excluded", "x.go"))
+	_, release, err = session.DidModifyFiles(ctx, []source.FileModification{{
+		URI:        uri,
+		Action:     source.Open,
+		Version:    1,
+		Text:       []byte("package x\n"),
+		LanguageID: "go",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+
+	snapshots, release, err := session.Reload(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	for snapshot := range snapshots {
+		critErr := snapshot.GetCriticalError(ctx)
+		if critErr == nil || !strings.Contains(critErr.MainError.Error(), `directory filter "-excluded"`) {
+			t.Errorf("after Reload, GetCriticalError() = %v, want an error for the excluded open file", critErr)
+		}
+	}
+}
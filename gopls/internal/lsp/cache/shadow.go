@@ -0,0 +1,162 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/lsp/source"
+	"golang.org/x/tools/gopls/internal/span"
+)
+
+// ShadowedImports reports, as hints, the local declarations of the given
+// file that shadow the name of a package imported by the file within a
+// function that uses, or attempts to use, the package.
+func (s *snapshot) ShadowedImports(ctx context.Context, uri span.URI) ([]*source.Diagnostic, error) {
+	pkg, pgf, err := source.PackageForFile(ctx, s, uri, source.TypecheckFull, source.NarrowestPackage)
+	if err != nil {
+		return nil, err
+	}
+	var diags []*source.Diagnostic
+	for _, shadow := range shadowedImports(pgf.File, pkg.GetTypesInfo()) {
+		rng, err := pgf.PosRange(shadow.decl.Pos(), shadow.decl.Pos()+token.Pos(len(shadow.decl.Name())))
+		if err != nil {
+			return nil, err
+		}
+		importRng, err := pgf.NodeRange(shadow.spec)
+		if err != nil {
+			return nil, err
+		}
+		diags = append(diags, &source.Diagnostic{
+			URI:      uri,
+			Range:    rng,
+			Severity: protocol.SeverityHint,
+			Source:   source.ShadowedImport,
+			Message:  fmt.Sprintf("%s shadows imported package %s", shadow.decl.Name(), shadow.pkgName.Imported().Path()),
+			Related: []source.RelatedInformation{{
+				URI:     uri,
+				Range:   importRng,
+				Message: "imported here",
+			}},
+		})
+	}
+	return diags, nil
+}
+
+// A shadowedImport is a local declaration that shadows an imported package.
+type shadowedImport struct {
+	decl    types.Object    // the shadowing declaration
+	pkgName *types.PkgName  // the shadowed package
+	spec    *ast.ImportSpec // the import of the shadowed package
+}
+
+// shadowedImports returns the local declarations of file that shadow the
+// name of one of its imports, sorted by position.
+//
+// A declaration is reported only if the enclosing function refers to the
+// imported package, or contains a selector expression on the declared object
+// that is valid only for the package, such as fmt.Println where fmt is a
+// local variable.
+func shadowedImports(file *ast.File, info *types.Info) []shadowedImport {
+	imports := make(map[string]shadowedImport) // by local package name
+	for _, spec := range file.Imports {
+		var obj types.Object
+		if spec.Name != nil {
+			obj = info.Defs[spec.Name]
+		} else {
+			obj = info.Implicits[spec]
+		}
+		if pkgName, ok := obj.(*types.PkgName); ok {
+			imports[pkgName.Name()] = shadowedImport{pkgName: pkgName, spec: spec}
+		}
+	}
+	if len(imports) == 0 {
+		return nil
+	}
+
+	// Record the positions of the uses of each package, and the
+	// selector expressions that fail to resolve.
+	uses := make(map[*types.PkgName][]token.Pos)
+	var badSelectors []*ast.SelectorExpr
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Ident:
+			if pkgName, ok := info.Uses[n].(*types.PkgName); ok {
+				uses[pkgName] = append(uses[pkgName], n.Pos())
+			}
+		case *ast.SelectorExpr:
+			if _, ok := info.Selections[n]; !ok {
+				badSelectors = append(badSelectors, n)
+			}
+		}
+		return true
+	})
+
+	var result []shadowedImport
+	for id, obj := range info.Defs {
+		if obj == nil || id.Pos() != obj.Pos() || obj.Parent() == nil {
+			continue // not a local declaration
+		}
+		imp, ok := imports[obj.Name()]
+		if !ok {
+			continue
+		}
+		if _, ok := obj.(*types.PkgName); ok {
+			continue
+		}
+		fn := funcScope(obj.Parent())
+		if fn == nil {
+			continue // a package-level declaration conflicts with the import
+		}
+		if usedIn(fn, uses[imp.pkgName]) || misusedAsPackage(obj, imp.pkgName, badSelectors, info) {
+			imp.decl = obj
+			result = append(result, imp)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].decl.Pos() < result[j].decl.Pos() })
+	return result
+}
+
+// funcScope returns the outermost function scope enclosing scope, or nil if
+// scope is not local.
+func funcScope(scope *types.Scope) *types.Scope {
+	for scope != nil && scope.Parent() != nil {
+		parent := scope.Parent()
+		if parent.Parent() != nil && parent.Parent().Parent() == types.Universe {
+			return scope // parent is a file scope
+		}
+		scope = parent
+	}
+	return nil
+}
+
+// usedIn reports whether any of the positions lies within scope.
+func usedIn(scope *types.Scope, positions []token.Pos) bool {
+	for _, pos := range positions {
+		if scope.Contains(pos) {
+			return true
+		}
+	}
+	return false
+}
+
+// misusedAsPackage reports whether any of the unresolved selector
+// expressions selects from obj a member of the package pkgName.
+func misusedAsPackage(obj types.Object, pkgName *types.PkgName, badSelectors []*ast.SelectorExpr, info *types.Info) bool {
+	for _, sel := range badSelectors {
+		if id, ok := sel.X.(*ast.Ident); ok && info.Uses[id] == obj {
+			if pkgName.Imported().Scope().Lookup(sel.Sel.Name) != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"testing"
+)
+
+func TestShadowedImports(t *testing.T) {
+	const src = `package p
+
+import (
+	"fmt"
+	str "strings"
+)
+
+func used() {
+	fmt.Println()
+	fmt := 1 // shadows fmt in a function that uses it
+	_ = fmt
+}
+
+func misused() {
+	str := "x"
+	_ = str.ToUpper(str) // an attempt to use the strings package
+}
+
+func unrelated() {
+	fmt := 2 // the function does not use fmt
+	_ = fmt
+}
+
+func nested() {
+	if true {
+		fmt.Println()
+	}
+	func() {
+		for fmt := 0; fmt < 1; fmt++ {
+		}
+	}()
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Implicits:  make(map[ast.Node]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+	}
+	conf := types.Config{
+		Importer: importer.Default(),
+		Error:    func(error) {}, // misused contains a type error
+	}
+	conf.Check("p", fset, []*ast.File{f}, info)
+
+	var got []string
+	for _, shadow := range shadowedImports(f, info) {
+		line := fset.Position(shadow.decl.Pos()).Line
+		got = append(got, fmt.Sprintf("%d: %s shadows %s", line, shadow.decl.Name(), shadow.pkgName.Imported().Path()))
+	}
+	want := []string{
+		"10: fmt shadows fmt",
+		"15: str shadows strings",
+		"29: fmt shadows fmt",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("shadowedImports() = %q, want %q", got, want)
+	}
+}
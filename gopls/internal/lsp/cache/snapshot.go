@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"go/ast"
+	"go/build/constraint"
 	"go/token"
 	"go/types"
 	"io"
@@ -24,13 +25,17 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 	"unsafe"
 
 	"golang.org/x/mod/modfile"
 	"golang.org/x/mod/module"
 	"golang.org/x/mod/semver"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/tools/go/ast/astutil"
 	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/types/objectpath"
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
 	"golang.org/x/tools/gopls/internal/lsp/source"
 	"golang.org/x/tools/gopls/internal/span"
 	"golang.org/x/tools/internal/bug"
@@ -128,6 +133,15 @@ type snapshot struct {
 	// unloadableFiles keeps track of files that we've failed to load.
 	unloadableFiles map[span.URI]struct{}
 
+	// staleMetadata holds the metadata of packages as it was before it was
+	// invalidated, for as long as the packages are marked shouldLoad. It is
+	// cleared on reinitialization. See StaleMetadataForFile.
+	staleMetadata map[PackageID]*source.Metadata
+
+	// excludedOpenFiles maps each open file that is excluded from the view
+	// by a directory filter to that filter.
+	excludedOpenFiles map[span.URI]string
+
 	// parseModHandles keeps track of any parseModHandles for the snapshot.
 	// The handles need not refer to only the view's go.mod file.
 	parseModHandles *persistent.Map // from span.URI to *memoize.Promise[parseModResult]
@@ -141,7 +155,7 @@ type snapshot struct {
 	// the view's go.mod file.
 	modTidyHandles *persistent.Map // from span.URI to *memoize.Promise[modTidyResult]
 	modWhyHandles  *persistent.Map // from span.URI to *memoize.Promise[modWhyResult]
-	modVulnHandles *persistent.Map // from span.URI to *memoize.Promise[modVulnResult]
+	modVulnHandles *persistent.Map // from span.URI to *modVulnHandle
 
 	workspace *workspace // (not guarded by mu)
 
@@ -274,6 +288,32 @@ func (s *snapshot) WorkFile() span.URI {
 	return s.workspace.workFile
 }
 
+func (s *snapshot) WorkspaceModFile(ctx context.Context) ([]byte, error) {
+	var uri span.URI
+	switch s.workspace.moduleSource {
+	case goplsModWorkspace, fileSystemWorkspace:
+		file, err := s.workspace.modFile(ctx, s)
+		if err != nil {
+			return nil, err
+		}
+		return file.Format()
+	case goWorkWorkspace:
+		uri = s.workspace.workFile
+	default:
+		for modURI := range s.workspace.ActiveModFiles() {
+			uri = modURI
+		}
+	}
+	if uri == "" {
+		return nil, fmt.Errorf("no module file for %s", s.view.folder)
+	}
+	fh, err := s.GetFile(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	return fh.Read()
+}
+
 func (s *snapshot) Templates() map[span.URI]source.VersionedFileHandle {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -388,7 +428,13 @@ func (s *snapshot) RunGoCommandDirect(ctx context.Context, mode source.Invocatio
 	}
 	defer cleanup()
 
-	return s.view.gocmdRunner.Run(ctx, *inv)
+	var stdout *bytes.Buffer
+	err = s.withGoCommandTimeout(ctx, mode, func(ctx context.Context) error {
+		var err error
+		stdout, err = s.view.gocmdRunner.Run(ctx, *inv)
+		return err
+	})
+	return stdout, err
 }
 
 func (s *snapshot) RunGoCommandPiped(ctx context.Context, mode source.InvocationFlags, inv *gocommand.Invocation, stdout, stderr io.Writer) error {
@@ -397,7 +443,33 @@ func (s *snapshot) RunGoCommandPiped(ctx context.Context, mode source.Invocation
 		return err
 	}
 	defer cleanup()
-	return s.view.gocmdRunner.RunPiped(ctx, *inv, stdout, stderr)
+	return s.withGoCommandTimeout(ctx, mode, func(ctx context.Context) error {
+		return s.view.gocmdRunner.RunPiped(ctx, *inv, stdout, stderr)
+	})
+}
+
+// tempModFileTimeout bounds go command invocations that write a temporary
+// go.mod file when no GoCommandTimeout is configured. Such invocations may
+// run with a detached context, and so would otherwise never be canceled.
+const tempModFileTimeout = 10 * time.Minute
+
+// withGoCommandTimeout calls run with a context bounded by the configured
+// GoCommandTimeout, and reports a timeout as a distinct error.
+func (s *snapshot) withGoCommandTimeout(ctx context.Context, flags source.InvocationFlags, run func(context.Context) error) error {
+	timeout := s.view.Options().GoCommandTimeout
+	if timeout <= 0 && flags.Mode() == source.WriteTemporaryModFile {
+		timeout = tempModFileTimeout
+	}
+	if timeout <= 0 {
+		return run(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := run(ctx)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("go command timed out after %v: %w", timeout, err)
+	}
+	return err
 }
 
 func (s *snapshot) RunGoCommands(ctx context.Context, allowNetwork bool, wd string, run func(invoke func(...string) (*bytes.Buffer, error)) error) (bool, []byte, []byte, error) {
@@ -418,7 +490,13 @@ func (s *snapshot) RunGoCommands(ctx context.Context, allowNetwork bool, wd stri
 	invoke := func(args ...string) (*bytes.Buffer, error) {
 		inv.Verb = args[0]
 		inv.Args = args[1:]
-		return s.view.gocmdRunner.Run(ctx, *inv)
+		var stdout *bytes.Buffer
+		err := s.withGoCommandTimeout(ctx, flags, func(ctx context.Context) error {
+			var err error
+			stdout, err = s.view.gocmdRunner.Run(ctx, *inv)
+			return err
+		})
+		return stdout, err
 	}
 	if err := run(invoke); err != nil {
 		return false, nil, nil, err
@@ -537,6 +615,11 @@ func (s *snapshot) goCommandInvocation(ctx context.Context, flags source.Invocat
 	if err != nil {
 		return "", nil, cleanup, err
 	}
+	// Loading dependencies from the module cache overrides even an explicit
+	// -mod=vendor in GOFLAGS, as the -mod flag below takes precedence.
+	if s.view.options.PreferModuleCacheOverVendor {
+		vendorEnabled = false
+	}
 
 	const mutableModFlag = "mod"
 	// If the mod flag isn't set, populate it based on the mode and workspace.
@@ -632,6 +715,9 @@ func (s *snapshot) buildOverlay() map[string][]byte {
 }
 
 // TypeCheck type-checks the specified packages in the given mode.
+//
+// If ctx bounds it (see source.WithTypeCheckConcurrency), at most that many
+// of the requested packages are awaited in parallel.
 func (s *snapshot) TypeCheck(ctx context.Context, mode source.TypecheckMode, ids ...PackageID) ([]source.Package, error) {
 	// Build all the handles...
 	var phs []*packageHandle
@@ -648,16 +734,491 @@ func (s *snapshot) TypeCheck(ctx context.Context, mode source.TypecheckMode, ids
 		phs = append(phs, ph)
 	}
 
-	// ...then await them all.
-	var pkgs []source.Package
-	for _, ph := range phs {
-		pkg, err := ph.await(ctx, s)
+	// ...then await them all, a bounded number at a time.
+	pkgs := make([]source.Package, len(phs))
+	var group errgroup.Group
+	if n := source.TypeCheckConcurrency(ctx); n > 0 {
+		group.SetLimit(n)
+	}
+	for i, ph := range phs {
+		i, ph := i, ph
+		group.Go(func() error {
+			pkg, err := ph.await(ctx, s)
+			if err != nil {
+				return err
+			}
+			pkgs[i] = pkg
+			return nil
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+	return pkgs, nil
+}
+
+// TypeCheckByPath type-checks the packages with the given package paths,
+// loading any that are not yet known to the snapshot.
+func (s *snapshot) TypeCheckByPath(ctx context.Context, mode source.TypecheckMode, paths ...PackagePath) ([]source.Package, error) {
+	if err := s.awaitLoaded(ctx); err != nil {
+		return nil, err
+	}
+
+	var scopes []loadScope
+	for _, path := range paths {
+		if len(s.packageIDsForPath(path)) == 0 {
+			scopes = append(scopes, packageLoadScope(path))
+		}
+	}
+	if len(scopes) > 0 {
+		if err := s.load(ctx, false, scopes...); err != nil {
+			return nil, err
+		}
+	}
+
+	ids := make([]PackageID, len(paths))
+	for i, path := range paths {
+		candidates := s.packageIDsForPath(path)
+		switch len(candidates) {
+		case 0:
+			return nil, fmt.Errorf("no package found for path %q", path)
+		case 1:
+			ids[i] = candidates[0]
+		default:
+			return nil, &source.AmbiguousPackagePathError{Path: path, IDs: candidates}
+		}
+	}
+	return s.TypeCheck(ctx, mode, ids...)
+}
+
+// IsGenerated reports whether the Go file denoted by uri is generated.
+// Only its header is parsed, using the cache.
+func (s *snapshot) IsGenerated(ctx context.Context, uri span.URI) (bool, error) {
+	fh, err := s.GetFile(ctx, uri)
+	if err != nil {
+		return false, err
+	}
+	pgf, err := s.ParseGo(ctx, fh, source.ParseHeader)
+	if err != nil {
+		return false, err
+	}
+	return pgf.IsGenerated(), nil
+}
+
+func (s *snapshot) GeneratedFiles(ctx context.Context) ([]span.URI, error) {
+	if err := s.awaitLoaded(ctx); err != nil {
+		return nil, err
+	}
+	uris := make(map[span.URI]bool)
+	for _, m := range s.workspaceMetadata() {
+		for _, uri := range m.GoFiles {
+			uris[uri] = true
+		}
+	}
+
+	var generated []span.URI
+	for uri := range uris {
+		if s.view.gomodcache != "" && source.InDir(s.view.gomodcache, uri.Filename()) {
+			continue
+		}
+		fh, err := s.GetFile(ctx, uri)
 		if err != nil {
 			return nil, err
 		}
-		pkgs = append(pkgs, pkg)
+		pgf, err := s.ParseGo(ctx, fh, source.ParseHeader)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue // e.g. the file was deleted
+		}
+		if pgf.IsGenerated() {
+			generated = append(generated, uri)
+		}
 	}
-	return pkgs, nil
+	sort.Slice(generated, func(i, j int) bool { return generated[i] < generated[j] })
+	return generated, nil
+}
+
+func (s *snapshot) FormatScope(ctx context.Context, uri span.URI) ([]span.URI, error) {
+	fh, err := s.GetFile(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	if s.view.FileKind(fh) != source.Go {
+		return []span.URI{uri}, nil
+	}
+	metas, err := s.MetadataForFile(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	uris := map[span.URI]bool{uri: true}
+	for _, m := range metas {
+		for _, uri := range m.GoFiles {
+			uris[uri] = true
+		}
+	}
+
+	var scope []span.URI
+	for uri := range uris {
+		generated, err := s.IsGenerated(ctx, uri)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue // e.g. the file was deleted
+		}
+		if !generated {
+			scope = append(scope, uri)
+		}
+	}
+	sort.Slice(scope, func(i, j int) bool { return scope[i] < scope[j] })
+	return scope, nil
+}
+
+// AllFilesForPackage returns the sorted Go files of the package with the
+// given package path and of all its variants: its test variant, its
+// intermediate test variants, and its external test package.
+func (s *snapshot) AllFilesForPackage(ctx context.Context, pkgPath PackagePath) ([]span.URI, error) {
+	if err := s.awaitLoaded(ctx); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	seen := make(map[span.URI]bool)
+	for _, m := range s.meta.metadata {
+		if m.PkgPath == pkgPath || m.PkgPath == pkgPath+"_test" && m.ForTest == pkgPath {
+			for _, uri := range m.GoFiles {
+				seen[uri] = true
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	if len(seen) == 0 {
+		return nil, fmt.Errorf("no package found for path %q", pkgPath)
+	}
+	uris := make([]span.URI, 0, len(seen))
+	for uri := range seen {
+		uris = append(uris, uri)
+	}
+	sort.Slice(uris, func(i, j int) bool { return uris[i] < uris[j] })
+	return uris, nil
+}
+
+// AllModules returns the modules providing the packages known to the
+// snapshot, including indirect dependencies, de-duplicated by path and
+// version and sorted by path.
+//
+// Modules that provide no package in the import graph of the workspace
+// are not included.
+func (s *snapshot) AllModules(ctx context.Context) ([]*packages.Module, error) {
+	if err := s.awaitLoaded(ctx); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	seen := make(map[string]*packages.Module) // key is path@version
+	for _, m := range s.meta.metadata {
+		if m.Module != nil {
+			seen[m.Module.Path+"@"+m.Module.Version] = m.Module
+		}
+	}
+	s.mu.Unlock()
+
+	modules := make([]*packages.Module, 0, len(seen))
+	for _, mod := range seen {
+		modules = append(modules, mod)
+	}
+	sort.Slice(modules, func(i, j int) bool {
+		if modules[i].Path != modules[j].Path {
+			return modules[i].Path < modules[j].Path
+		}
+		return modules[i].Version < modules[j].Version
+	})
+	return modules, nil
+}
+
+// MissingRequirements returns the workspace imports not provided by any required module.
+func (s *snapshot) MissingRequirements(ctx context.Context) (map[ImportPath]span.URI, error) {
+	if err := s.awaitLoaded(ctx); err != nil {
+		return nil, err
+	}
+	modURIs := s.ModFiles()
+	if len(modURIs) == 0 {
+		return nil, nil
+	}
+
+	// Collect the main modules and their requirements.
+	var modPaths []string
+	for _, modURI := range modURIs {
+		fh, err := s.GetFile(ctx, modURI)
+		if err != nil {
+			return nil, err
+		}
+		pm, err := s.ParseMod(ctx, fh)
+		if err != nil || pm.File == nil {
+			continue // parse errors are reported by go.mod diagnostics
+		}
+		if pm.File.Module != nil {
+			modPaths = append(modPaths, pm.File.Module.Mod.Path)
+		}
+		for _, req := range pm.File.Require {
+			modPaths = append(modPaths, req.Mod.Path)
+		}
+	}
+	provided := func(path string) bool {
+		for _, modPath := range modPaths {
+			if path == modPath || strings.HasPrefix(path, modPath+"/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	missing := make(map[ImportPath]span.URI)
+	for _, m := range s.workspaceMetadata() {
+		// Use GoFiles rather than DepsByImpPath: without -mod=readonly,
+		// imports of unrequired modules may have been resolved by the go
+		// command.
+		for _, uri := range m.GoFiles {
+			fh, err := s.GetFile(ctx, uri)
+			if err != nil {
+				return nil, err
+			}
+			pgf, err := s.ParseGo(ctx, fh, source.ParseHeader)
+			if err != nil {
+				continue
+			}
+			for _, spec := range pgf.File.Imports {
+				path, err := strconv.Unquote(spec.Path.Value)
+				if err != nil || path == "C" {
+					continue
+				}
+				if first := strings.SplitN(path, "/", 2)[0]; !strings.Contains(first, ".") {
+					continue // standard library
+				}
+				if provided(path) {
+					continue
+				}
+				// Choose the least file URI, for determinism.
+				if prev, ok := missing[ImportPath(path)]; !ok || uri < prev {
+					missing[ImportPath(path)] = uri
+				}
+			}
+		}
+	}
+	return missing, nil
+}
+
+func (s *snapshot) FilesImporting(ctx context.Context, target PackagePath) ([]span.URI, error) {
+	if err := s.awaitLoaded(ctx); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[span.URI]bool)
+	for _, m := range s.workspaceMetadata() {
+		if _, ok := m.DepsByPkgPath[target]; !ok {
+			continue
+		}
+		// Import paths may differ from the package path (for example, when
+		// vendoring), so resolve them using the package's metadata.
+		imports := func(importPath string) bool {
+			if importPath == string(target) {
+				return true
+			}
+			id, ok := m.DepsByImpPath[ImportPath(importPath)]
+			if !ok || id == "" {
+				return false
+			}
+			dep := s.Metadata(id)
+			return dep != nil && dep.PkgPath == target
+		}
+		for _, uri := range m.GoFiles {
+			if seen[uri] {
+				continue
+			}
+			fh, err := s.GetFile(ctx, uri)
+			if err != nil {
+				return nil, err
+			}
+			pgf, err := s.ParseGo(ctx, fh, source.ParseHeader)
+			if err != nil {
+				continue
+			}
+			for _, spec := range pgf.File.Imports {
+				if path, err := strconv.Unquote(spec.Path.Value); err == nil && imports(path) {
+					seen[uri] = true
+					break
+				}
+			}
+		}
+	}
+
+	uris := make([]span.URI, 0, len(seen))
+	for uri := range seen {
+		uris = append(uris, uri)
+	}
+	sort.Slice(uris, func(i, j int) bool { return uris[i] < uris[j] })
+	return uris, nil
+}
+
+func (s *snapshot) PackagesWithoutTests(ctx context.Context) ([]PackageID, error) {
+	if err := s.awaitLoaded(ctx); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Test variants and external test packages record the package under
+	// test in ForTest.
+	tested := make(map[PackagePath]bool)
+	for _, m := range s.meta.metadata {
+		if m.ForTest != "" {
+			tested[m.ForTest] = true
+		}
+	}
+	var ids []PackageID
+	for id := range s.workspacePackages {
+		m := s.meta.metadata[id]
+		if m == nil || m.ForTest != "" || source.IsCommandLineArguments(id) {
+			continue
+		}
+		if !tested[m.PkgPath] {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+func (s *snapshot) AffectedTests(ctx context.Context, changed PackageID) ([]PackageID, error) {
+	if err := s.awaitLoaded(ctx); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	meta := s.meta
+	s.mu.Unlock()
+	return meta.affectedTests(changed), nil
+}
+
+// PackageCompiles reports whether the package is free of list, parse, and type errors.
+func (s *snapshot) PackageCompiles(ctx context.Context, id PackageID) (bool, error) {
+	pkgs, err := s.TypeCheck(ctx, source.TypecheckFull, id)
+	if err != nil {
+		return false, err
+	}
+	pkg := pkgs[0]
+	return !pkg.HasListOrParseErrors() && !pkg.HasTypeErrors(), nil
+}
+
+func (s *snapshot) UnreachableFunctions(ctx context.Context, id PackageID) ([]*types.Func, error) {
+	pkgs, err := s.TypeCheck(ctx, source.TypecheckFull, id)
+	if err != nil {
+		return nil, err
+	}
+	return source.UnreachableFunctions(pkgs[0]), nil
+}
+
+func (s *snapshot) PackageCallGraph(ctx context.Context, id PackageID) (map[objectpath.Path][]objectpath.Path, error) {
+	pkgs, err := s.TypeCheck(ctx, source.TypecheckFull, id)
+	if err != nil {
+		return nil, err
+	}
+	return source.CallGraph(pkgs[0]), nil
+}
+
+// CallersMatching returns the call sites, within workspace packages, of
+// the functions and methods declared in workspace packages whose names
+// match re. See source.CallersMatching for details.
+func (s *snapshot) CallersMatching(ctx context.Context, re *regexp.Regexp) (map[PackagePath]map[objectpath.Path][]protocol.Location, error) {
+	if err := s.awaitLoaded(ctx); err != nil {
+		return nil, err
+	}
+	var ids []PackageID
+	for _, m := range s.workspaceMetadata() {
+		ids = append(ids, m.ID)
+	}
+	// Type-check all packages together in the same mode, so that they share
+	// the types of their common dependencies.
+	pkgs, err := s.TypeCheck(ctx, source.TypecheckFull, ids...)
+	if err != nil {
+		return nil, err
+	}
+	return source.CallersMatching(pkgs, re)
+}
+
+func (s *snapshot) TestsReaching(ctx context.Context, uri span.URI, pos protocol.Position) ([]source.TestFunc, error) {
+	pkg, pgf, err := source.PackageForFile(ctx, s, uri, source.TypecheckFull, source.NarrowestPackage)
+	if err != nil {
+		return nil, err
+	}
+	p, err := pgf.Pos(pos)
+	if err != nil {
+		return nil, err
+	}
+	path, _ := astutil.PathEnclosingInterval(pgf.File, p, p)
+	var fn *types.Func
+	for _, n := range path {
+		if decl, ok := n.(*ast.FuncDecl); ok {
+			fn, _ = pkg.GetTypesInfo().Defs[decl.Name].(*types.Func)
+			break
+		}
+	}
+	if fn == nil {
+		return nil, fmt.Errorf("no function or method encloses %v", pos)
+	}
+
+	s.mu.Lock()
+	closure := s.meta.testedReverseClosure(pkg.ID())
+	s.mu.Unlock()
+	ids := make([]PackageID, 0, len(closure))
+	for id := range closure {
+		ids = append(ids, id)
+	}
+	// Type-check all packages together in the same mode, so that they share
+	// the types of their common dependencies.
+	pkgs, err := s.TypeCheck(ctx, source.TypecheckFull, ids...)
+	if err != nil {
+		return nil, err
+	}
+	return source.TestsReaching(pkgs, fn)
+}
+
+// packageIDsForPath returns the sorted IDs of packages with the given
+// package path.
+func (s *snapshot) packageIDsForPath(path PackagePath) []PackageID {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ids []PackageID
+	for id, m := range s.meta.metadata {
+		if m.PkgPath == path {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func (s *snapshot) SamePackage(ctx context.Context, a, b span.URI) (bool, error) {
+	var narrowest [2]*source.Metadata
+	for i, uri := range []span.URI{a, b} {
+		metas, err := s.MetadataForFile(ctx, uri)
+		if err != nil {
+			return false, err
+		}
+		if len(metas) == 0 {
+			return false, fmt.Errorf("no package metadata for file %s", uri)
+		}
+		narrowest[i] = metas[0]
+	}
+	ma, mb := narrowest[0], narrowest[1]
+	// Ad-hoc packages share a package path, so compare their IDs.
+	if source.IsCommandLineArguments(ma.ID) || source.IsCommandLineArguments(mb.ID) {
+		return ma.ID == mb.ID, nil
+	}
+	return ma.PkgPath == mb.PkgPath, nil
 }
 
 func (s *snapshot) MetadataForFile(ctx context.Context, uri span.URI) ([]*source.Metadata, error) {
@@ -735,6 +1296,46 @@ func (s *snapshot) MetadataForFile(ctx context.Context, uri span.URI) ([]*source
 	return metas, nil
 }
 
+func (s *snapshot) StaleMetadataForFile(ctx context.Context, uri span.URI) ([]*source.Metadata, bool, error) {
+	s.mu.Lock()
+	stale := s.staleMetadataLocked(uri)
+	s.mu.Unlock()
+
+	if len(stale) == 0 {
+		metas, err := s.MetadataForFile(ctx, uri)
+		return metas, false, err
+	}
+	return stale, true, nil
+}
+
+// staleMetadataLocked returns the stale metadata of the packages containing
+// uri that are still awaiting a reload, sorted as by MetadataForFile. It
+// returns nil if uri is known to be unloadable.
+func (s *snapshot) staleMetadataLocked(uri span.URI) []*source.Metadata {
+	if _, unloadable := s.unloadableFiles[uri]; unloadable {
+		return nil
+	}
+	var stale []*source.Metadata
+	for id, m := range s.staleMetadata {
+		if len(s.shouldLoad[id]) == 0 {
+			continue
+		}
+		for _, f := range m.CompiledGoFiles {
+			if f == uri {
+				stale = append(stale, m)
+				break
+			}
+		}
+	}
+	sort.Slice(stale, func(i, j int) bool {
+		if ni, nj := len(stale[i].CompiledGoFiles), len(stale[j].CompiledGoFiles); ni != nj {
+			return ni < nj
+		}
+		return stale[i].ID < stale[j].ID
+	})
+	return stale
+}
+
 func (s *snapshot) ReverseDependencies(ctx context.Context, id PackageID, transitive bool) (map[PackageID]*source.Metadata, error) {
 	if err := s.awaitLoaded(ctx); err != nil {
 		return nil, err
@@ -765,6 +1366,60 @@ func (s *snapshot) ReverseDependencies(ctx context.Context, id PackageID, transi
 	return rdeps, nil
 }
 
+func (s *snapshot) DirectImporters(ctx context.Context, id PackageID) ([]PackageID, error) {
+	rdeps, err := s.ReverseDependencies(ctx, id, false)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]PackageID, 0, len(rdeps))
+	for rdepID := range rdeps {
+		ids = append(ids, rdepID)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+// AffectedFiles returns the files whose diagnostics may change as a result of changes.
+func (s *snapshot) AffectedFiles(ctx context.Context, changes []source.FileModification) ([]span.URI, error) {
+	if err := s.awaitLoaded(ctx); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	meta := s.meta
+	s.mu.Unlock()
+
+	affected := make(map[span.URI]bool)
+	var ids []PackageID
+	for _, c := range changes {
+		affected[c.URI] = true
+		ids = append(ids, meta.ids[c.URI]...)
+
+		// A change to a go.mod file affects every package of its module.
+		if isGoMod(c.URI) {
+			for id, m := range meta.metadata {
+				if m.Module != nil && moduleGoMod(m) == c.URI {
+					ids = append(ids, id)
+				}
+			}
+		}
+	}
+	for _, m := range meta.reverseReflexiveTransitiveClosure(ids...) {
+		for _, uri := range m.CompiledGoFiles {
+			affected[uri] = true
+		}
+		for _, uri := range m.GoFiles {
+			affected[uri] = true
+		}
+	}
+
+	uris := make([]span.URI, 0, len(affected))
+	for uri := range affected {
+		uris = append(uris, uri)
+	}
+	sort.Slice(uris, func(i, j int) bool { return uris[i] < uris[j] })
+	return uris, nil
+}
+
 func (s *snapshot) workspaceMetadata() (meta []*source.Metadata) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -984,6 +1639,73 @@ func (s *snapshot) knownFilesInDir(ctx context.Context, dir span.URI) []span.URI
 	return files
 }
 
+func (s *snapshot) PackagesWithErrors(ctx context.Context) (map[PackageID][]*source.Diagnostic, error) {
+	active, err := s.ActiveMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]PackageID, len(active))
+	for i, m := range active {
+		ids[i] = m.ID
+	}
+	pkgs, err := s.TypeCheck(ctx, source.TypecheckWorkspace, ids...)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[PackageID][]*source.Diagnostic)
+	for i, p := range pkgs {
+		if diags := p.(*pkg).diagnostics; len(diags) > 0 {
+			result[ids[i]] = append([]*source.Diagnostic(nil), diags...)
+		}
+	}
+	return result, nil
+}
+
+func (s *snapshot) MainFunctions(ctx context.Context) ([]protocol.Location, error) {
+	active, err := s.ActiveMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var locs []protocol.Location
+	for _, m := range active {
+		if m.Name != "main" || m.ForTest != "" {
+			continue
+		}
+		for _, uri := range m.CompiledGoFiles {
+			fh, err := s.GetFile(ctx, uri)
+			if err != nil {
+				return nil, err
+			}
+			pgf, err := s.ParseGo(ctx, fh, source.ParseFull)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				continue // e.g. the file was deleted
+			}
+			for _, decl := range pgf.File.Decls {
+				fn, ok := decl.(*ast.FuncDecl)
+				if !ok || fn.Recv != nil || fn.Name.Name != "main" {
+					continue
+				}
+				loc, err := pgf.Mapper.PosLocation(pgf.Tok, fn.Name.Pos(), fn.Name.End())
+				if err != nil {
+					return nil, err
+				}
+				locs = append(locs, loc)
+			}
+		}
+	}
+	sort.Slice(locs, func(i, j int) bool {
+		li, lj := locs[i], locs[j]
+		if li.URI == lj.URI {
+			return protocol.CompareRange(li.Range, lj.Range) < 0
+		}
+		return li.URI < lj.URI
+	})
+	return locs, nil
+}
+
 func (s *snapshot) ActiveMetadata(ctx context.Context) ([]*source.Metadata, error) {
 	if err := s.awaitLoaded(ctx); err != nil {
 		return nil, err
@@ -1005,6 +1727,37 @@ func (s *snapshot) ActiveMetadata(ctx context.Context) ([]*source.Metadata, erro
 	return active, nil
 }
 
+func (s *snapshot) WhyActive(id PackageID) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.meta.metadata[id] == nil {
+		return "", fmt.Errorf("no metadata for %s", id)
+	}
+	if _, ok := s.workspacePackages[id]; !ok {
+		return "", fmt.Errorf("%s is not active: it is not a workspace package", id)
+	}
+	if s.view.Options().MemoryMode == source.ModeNormal {
+		return "workspace package", nil
+	}
+
+	// ModeDegradeClosed: see isActiveLocked.
+	openID, uri := s.meta.nearestOpenPackage(id, s.isOpenLocked)
+	switch openID {
+	case "":
+		return "", fmt.Errorf("%s is not active: neither it nor its dependencies have open files", id)
+	case id:
+		return fmt.Sprintf("open file %s", uri.Filename()), nil
+	default:
+		return fmt.Sprintf("reverse dependency of open package %s (open file %s)", s.meta.metadata[openID].PkgPath, uri.Filename()), nil
+	}
+}
+
+// MatchSymbols returns at most limit symbols of the snapshot matching query.
+func (s *snapshot) MatchSymbols(ctx context.Context, query string, limit int) ([]source.SymbolMatch, error) {
+	return source.MatchSymbols(ctx, s, query, limit)
+}
+
 // Symbols extracts and returns the symbols for each file in all the snapshot's views.
 func (s *snapshot) Symbols(ctx context.Context) map[span.URI][]source.Symbol {
 	// Read the set of Go files out of the snapshot.
@@ -1032,18 +1785,103 @@ func (s *snapshot) Symbols(ctx context.Context) map[span.URI][]source.Symbol {
 			if err != nil {
 				return err
 			}
-			resultMu.Lock()
-			result[f.URI()] = symbols
-			resultMu.Unlock()
-			return nil
-		})
+			resultMu.Lock()
+			result[f.URI()] = symbols
+			resultMu.Unlock()
+			return nil
+		})
+	}
+	// Keep going on errors, but log the first failure.
+	// Partial results are better than no symbol results.
+	if err := group.Wait(); err != nil {
+		event.Error(ctx, "getting snapshot symbols", err)
+	}
+	return result
+}
+
+func (s *snapshot) StreamSymbols(ctx context.Context, onPackage func(PackageID, map[span.URI][]source.Symbol)) error {
+	var (
+		group    errgroup.Group
+		nprocs   = 2 * runtime.GOMAXPROCS(-1) // symbolize is a mix of I/O and CPU
+		reportMu sync.Mutex
+		reported = make(map[PackageID]bool)
+		seen     = make(map[span.URI]bool)
+	)
+	group.SetLimit(nprocs)
+
+	// stream symbolizes, in parallel, the packages of g not yet reported,
+	// reporting each package as soon as all of its files are symbolized.
+	// Packages are visited in order of ID, and each file is assigned to
+	// the first package containing it, so that files shared by test
+	// variants are reported once, with the narrowest package.
+	stream := func(g *metadataGraph) {
+		var ids []PackageID
+		for id := range g.metadata {
+			if !reported[id] {
+				ids = append(ids, id)
+			}
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		for _, id := range ids {
+			id := id
+			reported[id] = true
+			var uris []span.URI
+			for _, uri := range g.metadata[id].CompiledGoFiles {
+				if !seen[uri] {
+					seen[uri] = true
+					uris = append(uris, uri)
+				}
+			}
+			if len(uris) == 0 {
+				continue
+			}
+			group.Go(func() error {
+				symbols := make(map[span.URI][]source.Symbol, len(uris))
+				for _, uri := range uris {
+					if ctx.Err() != nil {
+						return ctx.Err()
+					}
+					fh, err := s.GetFile(ctx, uri)
+					if err != nil {
+						return err
+					}
+					syms, err := s.symbolize(ctx, fh)
+					if err != nil {
+						// Partial results are better than no symbol results.
+						event.Error(ctx, "symbolizing "+string(uri), err)
+						continue
+					}
+					symbols[uri] = syms
+				}
+				reportMu.Lock()
+				defer reportMu.Unlock()
+				onPackage(id, symbols)
+				return nil
+			})
+		}
 	}
-	// Keep going on errors, but log the first failure.
-	// Partial results are better than no symbol results.
+
+	// Start with the packages already loaded, then add those of the
+	// complete workspace load.
+	s.mu.Lock()
+	g := s.meta
+	s.mu.Unlock()
+	stream(g)
+	loadErr := s.awaitLoaded(ctx)
+	s.mu.Lock()
+	loaded := s.meta
+	s.mu.Unlock()
+	if loaded != g {
+		stream(loaded)
+	}
+
 	if err := group.Wait(); err != nil {
-		event.Error(ctx, "getting snapshot symbols", err)
+		return err
 	}
-	return result
+	if loadErr != nil {
+		return loadErr
+	}
+	return ctx.Err()
 }
 
 func (s *snapshot) AllMetadata(ctx context.Context) ([]*source.Metadata, error) {
@@ -1097,6 +1935,29 @@ func (s *snapshot) GoModForFile(uri span.URI) span.URI {
 	return moduleForURI(s.workspace.activeModFiles, uri)
 }
 
+func (s *snapshot) ModulePathForFile(ctx context.Context, uri span.URI) (string, span.URI, error) {
+	modURI := s.GoModForFile(uri)
+	if modURI == "" {
+		return "", "", source.ErrNoModule
+	}
+	fh, err := s.GetFile(ctx, modURI)
+	if err != nil {
+		return "", "", err
+	}
+	pm, err := s.ParseMod(ctx, fh)
+	if err != nil {
+		return "", "", err
+	}
+	if pm.File == nil || pm.File.Module == nil {
+		return "", "", fmt.Errorf("%s has no module directive", modURI)
+	}
+	return pm.File.Module.Mod.Path, modURI, nil
+}
+
+func (s *snapshot) EnclosingGoMod(dir span.URI) span.URI {
+	return moduleForURI(s.workspace.getKnownModFiles(), dir)
+}
+
 func moduleForURI(modFiles map[span.URI]struct{}, uri span.URI) span.URI {
 	var match span.URI
 	for modURI := range modFiles {
@@ -1116,6 +1977,15 @@ func (s *snapshot) Metadata(id PackageID) *source.Metadata {
 	return s.meta.metadata[id]
 }
 
+func (s *snapshot) LoadErrors(id PackageID) []packages.Error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if m := s.meta.metadata[id]; m != nil {
+		return m.Errors
+	}
+	return nil
+}
+
 // clearShouldLoad clears package IDs that no longer need to be reloaded after
 // scopes has been loaded.
 func (s *snapshot) clearShouldLoad(scopes ...loadScope) {
@@ -1136,12 +2006,24 @@ func (s *snapshot) clearShouldLoad(scopes ...loadScope) {
 			}
 			for _, id := range toDelete {
 				delete(s.shouldLoad, id)
+				delete(s.staleMetadata, id)
 			}
 		case fileLoadScope:
 			uri := span.URI(scope)
 			ids := s.meta.ids[uri]
 			for _, id := range ids {
 				delete(s.shouldLoad, id)
+				delete(s.staleMetadata, id)
+			}
+			// The file may no longer belong to the packages whose stale
+			// metadata contains it, for example if they were renamed.
+			for id, m := range s.staleMetadata {
+				for _, f := range m.CompiledGoFiles {
+					if f == uri {
+						delete(s.staleMetadata, id)
+						break
+					}
+				}
 			}
 		}
 	}
@@ -1205,6 +2087,30 @@ func (s *snapshot) GetFile(ctx context.Context, uri span.URI) (source.FileHandle
 	return s.GetVersionedFile(ctx, uri)
 }
 
+func (s *snapshot) OverlayDiff(ctx context.Context, uri span.URI) ([]protocol.TextEdit, error) {
+	o, ok := s.FindFile(uri).(*overlay)
+	if !ok {
+		return nil, fmt.Errorf("no overlay for %s", uri)
+	}
+	if o.Saved() {
+		return []protocol.TextEdit{}, nil
+	}
+	fh, err := s.view.cache.getFile(ctx, o.URI())
+	if err != nil {
+		return nil, err
+	}
+	disk, err := fh.Read()
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	edits := s.view.Options().ComputeEdits(string(disk), string(o.text))
+	return source.ToProtocolEdits(protocol.NewMapper(o.URI(), disk), edits)
+}
+
+func (s *snapshot) CanonicalURI(uri span.URI) span.URI {
+	return resolveSymlinks(uri)
+}
+
 func (s *snapshot) IsOpen(uri span.URI) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -1247,7 +2153,7 @@ func (s *snapshot) awaitLoaded(ctx context.Context) error {
 }
 
 func (s *snapshot) GetCriticalError(ctx context.Context) *source.CriticalError {
-	if wsErr := s.workspace.criticalError(ctx, s); wsErr != nil {
+	if wsErr := s.workspace.criticalError(ctx, s, s.view.goEnv["GOFLAGS"]); wsErr != nil {
 		return wsErr
 	}
 
@@ -1287,7 +2193,7 @@ func (s *snapshot) GetCriticalError(ctx context.Context) *source.CriticalError {
 				}
 			}
 		}
-		return nil
+		return s.excludedOpenFilesError()
 	}
 
 	if errMsg := loadErr.MainError.Error(); strings.Contains(errMsg, "cannot find main module") || strings.Contains(errMsg, "go.mod file not found") {
@@ -1305,6 +2211,42 @@ func (s *snapshot) GetCriticalError(ctx context.Context) *source.CriticalError {
 	return loadErr
 }
 
+// excludedOpenFilesError returns a critical error explaining that open files
+// are excluded from the workspace by directory filters, or nil if there are
+// no such files. Without it, gopls would silently provide no features for
+// them.
+func (s *snapshot) excludedOpenFilesError() *source.CriticalError {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.excludedOpenFiles) == 0 {
+		return nil
+	}
+	var uris []span.URI
+	for uri := range s.excludedOpenFiles {
+		uris = append(uris, uri)
+	}
+	sort.Slice(uris, func(i, j int) bool { return uris[i] < uris[j] })
+
+	var diags []*source.Diagnostic
+	for _, uri := range uris {
+		diags = append(diags, &source.Diagnostic{
+			URI:      uri,
+			Severity: protocol.SeverityWarning,
+			Source:   source.ListError,
+			Message:  fmt.Sprintf("This file is excluded from the workspace by the directory filter %q.", s.excludedOpenFiles[uri]),
+		})
+	}
+	msg := fmt.Sprintf("%s is excluded from the workspace by the directory filter %q", uris[0].Filename(), s.excludedOpenFiles[uris[0]])
+	if len(uris) > 1 {
+		msg += fmt.Sprintf(" (and %d other open files are excluded)", len(uris)-1)
+	}
+	return &source.CriticalError{
+		MainError:   errors.New(msg),
+		Diagnostics: diags,
+	}
+}
+
 // A portion of this text is expected by TestBrokenWorkspace_OutsideModule.
 const adHocPackagesWarning = `You are outside of a module and outside of $GOPATH/src.
 If you are using modules, please open your editor to a directory in your module.
@@ -1361,19 +2303,11 @@ func (s *snapshot) awaitLoadedAllErrors(ctx context.Context) *source.CriticalErr
 	// may cause critical errors to be suppressed.
 
 	if err := s.reloadWorkspace(ctx); err != nil {
-		diags := s.extractGoCommandErrors(ctx, err)
-		return &source.CriticalError{
-			MainError:   err,
-			Diagnostics: diags,
-		}
+		return s.loadCriticalError(ctx, err)
 	}
 
 	if err := s.reloadOrphanedOpenFiles(ctx); err != nil {
-		diags := s.extractGoCommandErrors(ctx, err)
-		return &source.CriticalError{
-			MainError:   err,
-			Diagnostics: diags,
-		}
+		return s.loadCriticalError(ctx, err)
 	}
 	return nil
 }
@@ -1518,6 +2452,316 @@ func (s *snapshot) orphanedOpenFiles() []source.VersionedFileHandle {
 	return files
 }
 
+// OrphanedTestFiles reports test files that cannot be loaded, either because
+// their directory has no non-test Go files, or because their package name
+// matches neither the package of the non-test files nor its external test
+// package.
+func (s *snapshot) OrphanedTestFiles(ctx context.Context) ([]source.OrphanedTestFile, error) {
+	if err := s.awaitLoaded(ctx); err != nil {
+		return nil, err
+	}
+
+	// Consider the directories of all workspace packages, as well as those of
+	// any test files known to the snapshot.
+	dirs := make(map[string]bool)
+	for _, m := range s.workspaceMetadata() {
+		for _, uri := range m.GoFiles {
+			dirs[filepath.Dir(uri.Filename())] = true
+		}
+	}
+	s.mu.Lock()
+	s.files.Range(func(uri span.URI, _ source.VersionedFileHandle) {
+		if strings.HasSuffix(uri.Filename(), "_test.go") && source.InDir(s.view.folder.Filename(), uri.Filename()) {
+			dirs[filepath.Dir(uri.Filename())] = true
+		}
+	})
+	s.mu.Unlock()
+
+	var orphaned []source.OrphanedTestFile
+	for dir := range dirs {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue // e.g. the directory was deleted
+		}
+		// Group the files of dir by package name.
+		var tests []span.URI
+		testNames := make(map[span.URI]string)
+		pkgNames := make(map[string]bool) // package names of non-test files
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || !strings.HasSuffix(name, ".go") || checkIgnored(name) {
+				continue
+			}
+			uri := span.URIFromPath(filepath.Join(dir, name))
+			fh, err := s.GetFile(ctx, uri)
+			if err != nil {
+				return nil, err
+			}
+			pgf, err := s.ParseGo(ctx, fh, source.ParseHeader)
+			if err != nil || !pgf.File.Package.IsValid() || pgf.File.Name == nil {
+				continue // unparseable files are reported elsewhere
+			}
+			if strings.HasSuffix(name, "_test.go") {
+				tests = append(tests, uri)
+				testNames[uri] = pgf.File.Name.Name
+			} else {
+				pkgNames[pgf.File.Name.Name] = true
+			}
+		}
+		for _, uri := range tests {
+			name := testNames[uri]
+			switch {
+			case len(pkgNames) == 0:
+				orphaned = append(orphaned, source.OrphanedTestFile{
+					URI:    uri,
+					Reason: fmt.Sprintf("no non-test Go files in %s", dir),
+				})
+			case !pkgNames[name] && !pkgNames[strings.TrimSuffix(name, "_test")]:
+				var names []string
+				for pkgName := range pkgNames {
+					names = append(names, pkgName)
+				}
+				sort.Strings(names)
+				orphaned = append(orphaned, source.OrphanedTestFile{
+					URI:    uri,
+					Reason: fmt.Sprintf("package %s does not match package %s of non-test files", name, strings.Join(names, ", ")),
+				})
+			}
+		}
+	}
+	sort.Slice(orphaned, func(i, j int) bool { return orphaned[i].URI < orphaned[j].URI })
+	return orphaned, nil
+}
+
+func (s *snapshot) OrphanedFiles(ctx context.Context) ([]source.OrphanedFile, error) {
+	if err := s.awaitLoaded(ctx); err != nil {
+		return nil, err
+	}
+
+	// Record the files of all loaded packages, and the package names
+	// declared in each directory.
+	known := make(map[span.URI]bool)
+	dirNames := make(map[string]map[string]bool)
+	addName := func(dir, name string) {
+		if dirNames[dir] == nil {
+			dirNames[dir] = make(map[string]bool)
+		}
+		dirNames[dir][name] = true
+	}
+	s.mu.Lock()
+	for _, m := range s.meta.metadata {
+		for _, uri := range m.GoFiles {
+			known[uri] = true
+			addName(filepath.Dir(uri.Filename()), strings.TrimSuffix(string(m.Name), "_test"))
+		}
+		for _, uri := range m.CompiledGoFiles {
+			known[uri] = true
+		}
+	}
+	s.mu.Unlock()
+
+	// As in OrphanedTestFiles, consider the directories of all workspace
+	// packages, as well as those of any Go files known to the snapshot.
+	dirs := make(map[string]bool)
+	for _, m := range s.workspaceMetadata() {
+		for _, uri := range m.GoFiles {
+			dirs[filepath.Dir(uri.Filename())] = true
+		}
+	}
+	s.mu.Lock()
+	s.files.Range(func(uri span.URI, fh source.VersionedFileHandle) {
+		if s.view.FileKind(fh) == source.Go && source.InDir(s.view.folder.Filename(), uri.Filename()) {
+			dirs[filepath.Dir(uri.Filename())] = true
+		}
+	})
+	s.mu.Unlock()
+
+	var orphaned []source.OrphanedFile
+	for dir := range dirs {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue // e.g. the directory was deleted
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || !strings.HasSuffix(name, ".go") || checkIgnored(name) {
+				continue
+			}
+			uri := span.URIFromPath(filepath.Join(dir, name))
+			if known[uri] {
+				continue
+			}
+			fh, err := s.GetFile(ctx, uri)
+			if err != nil {
+				return nil, err
+			}
+			pgf, err := s.ParseGo(ctx, fh, source.ParseHeader)
+			if err != nil {
+				return nil, err
+			}
+			orphaned = append(orphaned, source.OrphanedFile{
+				URI:    uri,
+				Reason: orphanReason(pgf, dirNames[dir]),
+			})
+		}
+	}
+	sort.Slice(orphaned, func(i, j int) bool { return orphaned[i].URI < orphaned[j].URI })
+	return orphaned, nil
+}
+
+// orphanReason returns the probable reason why the file pgf does not belong
+// to any loaded package, given the package names of the loaded files of
+// its directory.
+func orphanReason(pgf *source.ParsedGoFile, pkgNames map[string]bool) string {
+	if len(pgf.ParseErr) > 0 {
+		return fmt.Sprintf("syntax error: %v", pgf.ParseErr[0])
+	}
+	if !pgf.File.Package.IsValid() || pgf.File.Name == nil {
+		return "missing package clause"
+	}
+	if x, err := pgf.BuildConstraints(); err != nil {
+		return fmt.Sprintf("invalid build constraint: %v", err)
+	} else if x != nil {
+		return fmt.Sprintf("excluded by build constraint %s", x)
+	}
+	name := strings.TrimSuffix(pgf.File.Name.Name, "_test")
+	if len(pkgNames) > 0 && !pkgNames[name] {
+		var names []string
+		for pkgName := range pkgNames {
+			names = append(names, pkgName)
+		}
+		sort.Strings(names)
+		return fmt.Sprintf("package %s does not match package %s of other files", pgf.File.Name.Name, strings.Join(names, ", "))
+	}
+	return "not included in any loaded package"
+}
+
+// PackageNameConflicts returns the package names declared by the Go files
+// of dir, sorted by name, if they declare more than one.
+//
+// Files with an "ignore" build constraint, conventionally used for
+// generator programs in the directory of another package, are ignored,
+// as are unparseable files.
+func (s *snapshot) PackageNameConflicts(ctx context.Context, dir span.URI) ([]source.PackageNameConflict, error) {
+	entries, err := ioutil.ReadDir(dir.Filename())
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[source.PackageName][]protocol.Location)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || checkIgnored(name) {
+			continue
+		}
+		fh, err := s.GetFile(ctx, span.URIFromPath(filepath.Join(dir.Filename(), name)))
+		if err != nil {
+			return nil, err
+		}
+		pgf, err := s.ParseGo(ctx, fh, source.ParseHeader)
+		if err != nil || pgf.File.Name == nil {
+			continue
+		}
+		if x, err := pgf.BuildConstraints(); err == nil {
+			if tag, ok := x.(*constraint.TagExpr); ok && tag.Tag == "ignore" {
+				continue
+			}
+		}
+		pkgName := source.PackageName(pgf.File.Name.Name)
+		if strings.HasSuffix(name, "_test.go") {
+			pkgName = source.PackageName(strings.TrimSuffix(string(pkgName), "_test"))
+		}
+		loc, err := pgf.Mapper.PosLocation(pgf.Tok, pgf.File.Name.Pos(), pgf.File.Name.End())
+		if err != nil {
+			return nil, err
+		}
+		byName[pkgName] = append(byName[pkgName], loc)
+	}
+	if len(byName) < 2 {
+		return nil, nil
+	}
+	var conflicts []source.PackageNameConflict
+	for name, locs := range byName {
+		sort.Slice(locs, func(i, j int) bool { return locs[i].URI < locs[j].URI })
+		conflicts = append(conflicts, source.PackageNameConflict{Name: name, Files: locs})
+	}
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].Name < conflicts[j].Name })
+	return conflicts, nil
+}
+
+// BuildTags returns the files of workspace directories referencing each build tag.
+func (s *snapshot) BuildTags(ctx context.Context) (map[string][]span.URI, error) {
+	if err := s.awaitLoaded(ctx); err != nil {
+		return nil, err
+	}
+	// Files excluded by build constraints are not among the files of any
+	// package, so read the directories of workspace packages.
+	dirs := make(map[string]bool)
+	for _, m := range s.workspaceMetadata() {
+		for _, uri := range m.GoFiles {
+			dirs[filepath.Dir(uri.Filename())] = true
+		}
+	}
+
+	files := make(map[string]map[span.URI]bool)
+	for dir := range dirs {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || !strings.HasSuffix(name, ".go") || checkIgnored(name) {
+				continue
+			}
+			uri := span.URIFromPath(filepath.Join(dir, name))
+			fh, err := s.GetFile(ctx, uri)
+			if err != nil {
+				return nil, err
+			}
+			pgf, err := s.ParseGo(ctx, fh, source.ParseHeader)
+			if err != nil {
+				continue
+			}
+			x, err := pgf.BuildConstraints()
+			if err != nil || x == nil {
+				continue
+			}
+			walkConstraintTags(x, func(tag string) {
+				if files[tag] == nil {
+					files[tag] = make(map[span.URI]bool)
+				}
+				files[tag][uri] = true
+			})
+		}
+	}
+
+	tags := make(map[string][]span.URI, len(files))
+	for tag, uris := range files {
+		for uri := range uris {
+			tags[tag] = append(tags[tag], uri)
+		}
+		sort.Slice(tags[tag], func(i, j int) bool { return tags[tag][i] < tags[tag][j] })
+	}
+	return tags, nil
+}
+
+// walkConstraintTags calls f for each tag referenced by the build
+// constraint x.
+func walkConstraintTags(x constraint.Expr, f func(tag string)) {
+	switch x := x.(type) {
+	case *constraint.TagExpr:
+		f(x.Tag)
+	case *constraint.NotExpr:
+		walkConstraintTags(x.X, f)
+	case *constraint.AndExpr:
+		walkConstraintTags(x.X, f)
+		walkConstraintTags(x.Y, f)
+	case *constraint.OrExpr:
+		walkConstraintTags(x.X, f)
+		walkConstraintTags(x.Y, f)
+	}
+}
+
 // TODO(golang/go#53756): this function needs to consider more than just the
 // absolute URI, for example:
 //   - the position of /vendor/ with respect to the relevant module root
@@ -1598,6 +2842,8 @@ func (s *snapshot) clone(ctx, bgCtx context.Context, changes map[span.URI]*fileC
 		symbolizeHandles:     s.symbolizeHandles.Clone(),
 		workspacePackages:    make(map[PackageID]PackagePath, len(s.workspacePackages)),
 		unloadableFiles:      make(map[span.URI]struct{}, len(s.unloadableFiles)),
+		staleMetadata:        make(map[PackageID]*source.Metadata, len(s.staleMetadata)),
+		excludedOpenFiles:    make(map[span.URI]string, len(s.excludedOpenFiles)),
 		parseModHandles:      s.parseModHandles.Clone(),
 		parseWorkHandles:     s.parseWorkHandles.Clone(),
 		modTidyHandles:       s.modTidyHandles.Clone(),
@@ -1628,6 +2874,16 @@ func (s *snapshot) clone(ctx, bgCtx context.Context, changes map[span.URI]*fileC
 	for k, v := range s.unloadableFiles {
 		result.unloadableFiles[k] = v
 	}
+	for k, v := range s.excludedOpenFiles {
+		result.excludedOpenFiles[k] = v
+	}
+	for uri, change := range changes {
+		if change.excludedBy != "" {
+			result.excludedOpenFiles[uri] = change.excludedBy
+		} else {
+			delete(result.excludedOpenFiles, uri)
+		}
+	}
 
 	// TODO(adonovan): merge loops over "changes".
 	for uri, change := range changes {
@@ -1680,6 +2936,28 @@ func (s *snapshot) clone(ctx, bgCtx context.Context, changes map[span.URI]*fileC
 		}
 	}
 
+	// If only the go directive of an active go.mod file has changed, update
+	// the go version of the packages of its module rather than reloading
+	// them, and re-check them (and their analyses).
+	goVersions := make(map[PackageID]string)
+	if !reinit {
+		for uri, sum := range newWorkspace.goMods {
+			prev, ok := s.workspace.goMods[uri]
+			if !ok || prev.goVersion == sum.goVersion {
+				continue
+			}
+			dir := filepath.Dir(uri.Filename())
+			for id, m := range s.meta.metadata {
+				if m.Module != nil && m.Module.Main && m.Module.Dir == dir {
+					goVersions[id] = sum.goVersion
+					if _, ok := directIDs[id]; !ok {
+						directIDs[id] = false
+					}
+				}
+			}
+		}
+	}
+
 	// Compute invalidations based on file changes.
 	anyImportDeleted := false      // import deletions can resolve cycles
 	anyFileOpenedOrClosed := false // opened files affect workspace packages
@@ -1845,6 +3123,16 @@ func (s *snapshot) clone(ctx, bgCtx context.Context, changes map[span.URI]*fileC
 		result.shouldLoad[k] = v
 	}
 
+	// Stale metadata is kept only while its package awaits a reload, and is
+	// meaningless after reinitialization.
+	if !reinit {
+		for k, v := range s.staleMetadata {
+			if len(result.shouldLoad[k]) > 0 {
+				result.staleMetadata[k] = v
+			}
+		}
+	}
+
 	// Compute which metadata updates are required. We only need to invalidate
 	// packages directly containing the affected file, and only if it changed in
 	// a relevant way.
@@ -1869,6 +3157,10 @@ func (s *snapshot) clone(ctx, bgCtx context.Context, changes map[span.URI]*fileC
 				needsReload = append(needsReload, v.ForTest)
 			}
 			result.shouldLoad[k] = needsReload
+			if !skipID[k] && !reinit {
+				// Keep it for StaleMetadataForFile until it is reloaded.
+				result.staleMetadata[k] = v
+			}
 		}
 
 		// Check whether the metadata should be deleted.
@@ -1876,6 +3168,14 @@ func (s *snapshot) clone(ctx, bgCtx context.Context, changes map[span.URI]*fileC
 			metadataUpdates[k] = nil
 			continue
 		}
+
+		if goVersion, ok := goVersions[k]; ok {
+			m := *v
+			mod := *v.Module
+			mod.GoVersion = goVersion
+			m.Module = &mod
+			metadataUpdates[k] = &m
+		}
 	}
 
 	// Update metadata, if necessary.
@@ -1900,11 +3200,45 @@ func (s *snapshot) clone(ctx, bgCtx context.Context, changes map[span.URI]*fileC
 	// the previous mode are no longer relevant, so clear them out.
 	if workspaceModeChanged {
 		result.workspacePackages = map[PackageID]PackagePath{}
+		result.staleMetadata = map[PackageID]*source.Metadata{}
 	}
 	result.dumpWorkspace("clone")
 	return result, release
 }
 
+func (s *snapshot) InvalidateAnalyses(names []string) (source.Snapshot, func()) {
+	return s.view.invalidateAnalyses(context.Background(), names)
+}
+
+// cloneWithoutAnalyses returns a copy of s, with no file changes, from which
+// the cached analysis results to which any of the named analyzers
+// contributed have been discarded.
+func (s *snapshot) cloneWithoutAnalyses(ctx context.Context, names []string) (*snapshot, func()) {
+	result, release := s.clone(ctx, s.view.baseCtx, nil, false)
+
+	invalid := make(map[string]bool, len(names))
+	for _, name := range names {
+		invalid[name] = true
+	}
+
+	// Each analysis result is keyed by the full list of analyzers that
+	// produced it, so discard every result to which one of them contributed.
+	var actionsToDelete []analysisKey
+	result.analyses.Range(func(k, _ interface{}) {
+		key := k.(analysisKey)
+		for _, name := range strings.Split(key.analyzerNames, ",") {
+			if invalid[name] {
+				actionsToDelete = append(actionsToDelete, key)
+				break
+			}
+		}
+	})
+	for _, key := range actionsToDelete {
+		result.analyses.Delete(key)
+	}
+	return result, release
+}
+
 // invalidatedPackageIDs returns all packages invalidated by a change to uri.
 // If we haven't seen this URI before, we guess based on files in the same
 // directory. This is of course incorrect in build systems where packages are
@@ -2177,6 +3511,8 @@ func (s *snapshot) setBuiltin(path string) {
 
 // BuildGoplsMod generates a go.mod file for all modules in the workspace. It
 // bypasses any existing gopls.mod.
+//
+// It has no side effects: the result is not written to disk.
 func (s *snapshot) BuildGoplsMod(ctx context.Context) (*modfile.File, error) {
 	allModules, err := findModules(s.view.folder, pathExcludedByFilterFunc(s.view.rootURI.Filename(), s.view.gomodcache, s.View().Options()), 0)
 	if err != nil {
@@ -2185,6 +3521,60 @@ func (s *snapshot) BuildGoplsMod(ctx context.Context) (*modfile.File, error) {
 	return buildWorkspaceModFile(ctx, allModules, s)
 }
 
+// DiffGoplsMod compares the replace directives of the go.mod file that
+// BuildGoplsMod would generate with those of the existing gopls.mod file in
+// the view's folder, if any. It returns the directives present only in the
+// generated file, and those present only in the existing file. Relative
+// paths in the existing file are made absolute, as they are when it is used.
+func (s *snapshot) DiffGoplsMod(ctx context.Context) (added, removed []modfile.Replace, err error) {
+	generated, err := s.BuildGoplsMod(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var existing []*modfile.Replace
+	goplsModURI := span.URIFromPath(filepath.Join(s.view.folder.Filename(), "gopls.mod"))
+	// As in goSum, avoid adding a nonexistent file to the snapshot.
+	var fh source.FileHandle = s.FindFile(goplsModURI)
+	if fh == nil {
+		fh, err = s.view.cache.getFile(ctx, goplsModURI)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if content, err := fh.Read(); err == nil { // otherwise, there is no gopls.mod file
+		file, err := modfile.Parse(goplsModURI.Filename(), content, nil)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parsing gopls.mod: %w", err)
+		}
+		for _, rep := range file.Replace {
+			if rep.New.Version == "" {
+				rep.New.Path = absolutePath(s.view.folder, rep.New.Path)
+			}
+			existing = append(existing, rep)
+		}
+	}
+
+	key := func(rep *modfile.Replace) [2]module.Version { return [2]module.Version{rep.Old, rep.New} }
+	inGenerated := make(map[[2]module.Version]bool)
+	for _, rep := range generated.Replace {
+		inGenerated[key(rep)] = true
+	}
+	inExisting := make(map[[2]module.Version]bool)
+	for _, rep := range existing {
+		inExisting[key(rep)] = true
+		if !inGenerated[key(rep)] {
+			removed = append(removed, *rep)
+		}
+	}
+	for _, rep := range generated.Replace {
+		if !inExisting[key(rep)] {
+			added = append(added, *rep)
+		}
+	}
+	return added, removed, nil
+}
+
 // TODO(rfindley): move this to workspace.go
 func buildWorkspaceModFile(ctx context.Context, modFiles map[span.URI]struct{}, fs source.FileSource) (*modfile.File, error) {
 	file := &modfile.File{}
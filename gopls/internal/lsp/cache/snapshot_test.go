@@ -0,0 +1,344 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/gopls/internal/lsp/source"
+	"golang.org/x/tools/gopls/internal/span"
+	"golang.org/x/tools/internal/testenv"
+)
+
+func TestStaleMetadata(t *testing.T) {
+	a := span.URIFromPath("/src/a/a.go")
+	b := span.URIFromPath("/src/b/b.go")
+	stale := func(id PackageID, uris ...span.URI) *source.Metadata {
+		return &source.Metadata{ID: id, PkgPath: PackagePath(id), CompiledGoFiles: uris}
+	}
+	s := &snapshot{
+		meta: &metadataGraph{ids: make(map[span.URI][]PackageID)},
+		shouldLoad: map[PackageID][]PackagePath{
+			"a": {"a"},
+			"b": {"b"},
+		},
+		unloadableFiles: make(map[span.URI]struct{}),
+		staleMetadata: map[PackageID]*source.Metadata{
+			"a":          stale("a", a),
+			"b":          stale("b", b),
+			"c [c.test]": stale("c [c.test]", a), // no longer awaiting a reload
+		},
+	}
+	ids := func(metas []*source.Metadata) []PackageID {
+		var ids []PackageID
+		for _, m := range metas {
+			ids = append(ids, m.ID)
+		}
+		return ids
+	}
+
+	if got, want := ids(s.staleMetadataLocked(a)), []PackageID{"a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("staleMetadataLocked(a) = %v, want %v", got, want)
+	}
+
+	// Stale metadata is not reported for unloadable files.
+	s.unloadableFiles[b] = struct{}{}
+	if got := s.staleMetadataLocked(b); got != nil {
+		t.Errorf("staleMetadataLocked(unloadable b) = %v, want none", ids(got))
+	}
+
+	// A reload of a file evicts the stale metadata containing it, even if
+	// the file now belongs to differently named packages.
+	s.meta.ids[a] = []PackageID{"a2"}
+	s.clearShouldLoad(fileLoadScope(a))
+	if got := s.staleMetadataLocked(a); got != nil {
+		t.Errorf("after reload, staleMetadataLocked(a) = %v, want none", ids(got))
+	}
+	if _, ok := s.staleMetadata["c [c.test]"]; ok {
+		t.Errorf("after reload, stale metadata of c [c.test] was retained")
+	}
+
+	// A reload of a package path evicts the stale metadata of its IDs.
+	s.clearShouldLoad(packageLoadScope("b"))
+	if len(s.staleMetadata) != 0 {
+		t.Errorf("after reload, stale metadata %v was retained", s.staleMetadata)
+	}
+}
+
+func TestAffectedFiles(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	files := map[string]string{
+		"go.mod":      "module example.com\n\ngo 1.18\n",
+		"a/a.go":      "package a\n\nimport \"example.com/b\"\n\nvar A = b.B\n",
+		"b/b.go":      "package b\n\nconst B = 1\n",
+		"b/b_test.go": "package b\n",
+		"c/c.go":      "package c\n",
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	view, snapshot := newTestSnapshot(ctx, t, files, nil)
+	uri := func(name string) span.URI {
+		return span.URIFromPath(filepath.Join(view.Folder().Filename(), filepath.FromSlash(name)))
+	}
+
+	for _, test := range []struct {
+		changed []string
+		want    []string
+	}{
+		{[]string{"c/c.go"}, []string{"c/c.go"}},
+		{[]string{"a/a.go"}, []string{"a/a.go"}},
+		{[]string{"b/b.go"}, []string{"a/a.go", "b/b.go", "b/b_test.go"}},
+		{[]string{"a/new.go"}, []string{"a/new.go"}},
+		{[]string{"go.mod"}, []string{"a/a.go", "b/b.go", "b/b_test.go", "c/c.go", "go.mod"}},
+	} {
+		var changes []source.FileModification
+		for _, name := range test.changed {
+			changes = append(changes, source.FileModification{URI: uri(name), Action: source.Change})
+		}
+		got, err := snapshot.AffectedFiles(ctx, changes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want []span.URI
+		for _, name := range test.want {
+			want = append(want, uri(name))
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("AffectedFiles(%v) = %v, want %v", test.changed, got, want)
+		}
+	}
+}
+
+func TestTypeCheckByPath(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	files := map[string]string{
+		"go.mod":      "module example.com\n\ngo 1.18\n",
+		"a/a.go":      "package a\n",
+		"b/b.go":      "package b\n",
+		"b/b_test.go": "package b\n",
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	_, snapshot := newTestSnapshot(ctx, t, files, nil)
+
+	pkgs, err := snapshot.TypeCheckByPath(ctx, source.TypecheckFull, "example.com/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != 1 || pkgs[0].ID() != "example.com/a" {
+		t.Errorf("TypeCheckByPath(example.com/a) = %v, want example.com/a", pkgs)
+	}
+
+	// A package and its test variant.
+	_, err = snapshot.TypeCheckByPath(ctx, source.TypecheckFull, "example.com/b")
+	var ambiguous *source.AmbiguousPackagePathError
+	if !errors.As(err, &ambiguous) {
+		t.Fatalf("TypeCheckByPath(example.com/b) returned error %v, want an AmbiguousPackagePathError", err)
+	}
+	if want := []PackageID{"example.com/b", "example.com/b [example.com/b.test]"}; !reflect.DeepEqual(ambiguous.IDs, want) {
+		t.Errorf("AmbiguousPackagePathError.IDs = %v, want %v", ambiguous.IDs, want)
+	}
+	for sel, want := range map[source.PackageSelector]PackageID{
+		source.NarrowestPackage: "example.com/b",
+		source.WidestPackage:    "example.com/b [example.com/b.test]",
+	} {
+		pkg, err := source.PackageForPath(ctx, snapshot, "example.com/b", source.TypecheckFull, sel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pkg.ID() != want {
+			t.Errorf("PackageForPath(example.com/b, %v) = %s, want %s", sel, pkg.ID(), want)
+		}
+	}
+
+	if _, err := snapshot.TypeCheckByPath(ctx, source.TypecheckFull, "example.com/missing"); err == nil {
+		t.Errorf("TypeCheckByPath(example.com/missing) succeeded, want error")
+	}
+}
+
+func TestAllFilesForPackage(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	files := map[string]string{
+		"go.mod":        "module example.com\n\ngo 1.18\n",
+		"a/a.go":        "package a\n",
+		"b/b.go":        "package b\n",
+		"b/b_test.go":   "package b\n",
+		"b/b_x_test.go": "package b_test\n",
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	view, snapshot := newTestSnapshot(ctx, t, files, nil)
+	uri := func(name string) span.URI {
+		return span.URIFromPath(filepath.Join(view.Folder().Filename(), filepath.FromSlash(name)))
+	}
+
+	for _, test := range []struct {
+		pkgPath PackagePath
+		want    []string
+	}{
+		{"example.com/a", []string{"a/a.go"}},
+		{"example.com/b", []string{"b/b.go", "b/b_test.go", "b/b_x_test.go"}},
+	} {
+		got, err := snapshot.AllFilesForPackage(ctx, test.pkgPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want []span.URI
+		for _, name := range test.want {
+			want = append(want, uri(name))
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("AllFilesForPackage(%s) = %v, want %v", test.pkgPath, got, want)
+		}
+	}
+
+	if _, err := snapshot.AllFilesForPackage(ctx, "example.com/missing"); err == nil {
+		t.Errorf("AllFilesForPackage(example.com/missing) succeeded, want error")
+	}
+}
+
+func TestAllModules(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	files := map[string]string{
+		"go.mod":     "module example.com\n\ngo 1.18\n\nrequire example.org/dep v0.0.0\n\nreplace example.org/dep => ./dep\n",
+		"a/a.go":     "package a\n\nimport \"example.org/dep\"\n\nvar A = dep.D\n",
+		"dep/go.mod": "module example.org/dep\n\ngo 1.18\n",
+		"dep/dep.go": "package dep\n\nconst D = 1\n",
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	_, snapshot := newTestSnapshot(ctx, t, files, nil)
+
+	modules, err := snapshot.AllModules(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range modules {
+		got = append(got, m.Path+"@"+m.Version)
+	}
+	if want := []string{"example.com@", "example.org/dep@v0.0.0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("AllModules() = %v, want %v", got, want)
+	}
+}
+
+func TestPackagesWithoutTests(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	files := map[string]string{
+		"go.mod":        "module example.com\n\ngo 1.18\n",
+		"a/a.go":        "package a\n",
+		"b/b.go":        "package b\n",
+		"b/b_test.go":   "package b\n",
+		"c/c.go":        "package c\n",
+		"c/c_x_test.go": "package c_test\n",
+		"d/d.go":        "package d\n",
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	_, snapshot := newTestSnapshot(ctx, t, files, nil)
+
+	got, err := snapshot.PackagesWithoutTests(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []PackageID{"example.com/a", "example.com/d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("PackagesWithoutTests() = %v, want %v", got, want)
+	}
+}
+
+func TestStreamSymbols(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	files := map[string]string{
+		"go.mod":        "module example.com\n\ngo 1.18\n",
+		"a/a.go":        "package a\n\nfunc A() {}\n",
+		"b/b.go":        "package b\n\nfunc B() {}\n",
+		"b/b_test.go":   "package b\n\nfunc helper() {}\n",
+		"b/b_x_test.go": "package b_test\n\nfunc xhelper() {}\n",
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	view, snapshot := newTestSnapshot(ctx, t, files, nil)
+	uri := func(name string) span.URI {
+		return span.URIFromPath(filepath.Join(view.Folder().Filename(), filepath.FromSlash(name)))
+	}
+
+	got := make(map[span.URI]PackageID)
+	if err := snapshot.StreamSymbols(ctx, func(id PackageID, symbols map[span.URI][]source.Symbol) {
+		for uri, syms := range symbols {
+			if prev, ok := got[uri]; ok {
+				t.Errorf("%s reported with both %s and %s", uri, prev, id)
+			}
+			if len(syms) != 1 {
+				t.Errorf("%s has symbols %v, want 1", uri, syms)
+			}
+			got[uri] = id
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := map[span.URI]PackageID{
+		uri("a/a.go"):        "example.com/a",
+		uri("b/b.go"):        "example.com/b",
+		uri("b/b_test.go"):   "example.com/b [example.com/b.test]",
+		uri("b/b_x_test.go"): "example.com/b_test [example.com/b.test]",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("StreamSymbols reported files with packages %v, want %v", got, want)
+	}
+}
+
+func TestPreferModuleCacheOverVendor(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	// The vendored copy of the dependency carries a local patch.
+	files := map[string]string{
+		"go.mod":                        "module example.com\n\ngo 1.18\n\nrequire example.org/dep v0.0.0\n\nreplace example.org/dep => ./dep\n",
+		"a/a.go":                        "package a\n\nimport \"example.org/dep\"\n\nvar _ = dep.X\n",
+		"dep/go.mod":                    "module example.org/dep\n\ngo 1.18\n",
+		"dep/dep.go":                    "package dep\n\nconst X = 1\n",
+		"vendor/modules.txt":            "# example.org/dep v0.0.0 => ./dep\n## explicit; go 1.18\nexample.org/dep\n# example.org/dep => ./dep\n",
+		"vendor/example.org/dep/dep.go": "package dep\n\nconst X = 2 // patched\n",
+	}
+	for _, test := range []struct {
+		prefer bool
+		want   string // directory of the dependency, relative to the folder
+	}{
+		{false, "vendor/example.org/dep"},
+		{true, "dep"},
+	} {
+		t.Run(fmt.Sprint(test.prefer), func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+			view, snapshot := newTestSnapshot(ctx, t, files, func(o *source.Options) {
+				o.Env["GOFLAGS"] = "" // vendoring is the default for go 1.14+
+				o.PreferModuleCacheOverVendor = test.prefer
+			})
+			pkgs, err := snapshot.TypeCheckByPath(ctx, source.TypecheckFull, "example.com/a")
+			if err != nil {
+				t.Fatal(err)
+			}
+			dep, err := pkgs[0].ResolveImportPath("example.org/dep")
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := filepath.Join(view.Folder().Filename(), filepath.FromSlash(test.want), "dep.go")
+			if got := dep.CompiledGoFiles()[0].URI.Filename(); got != want {
+				t.Errorf("dependency loaded from %s, want %s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"strconv"
+
+	"golang.org/x/tools/gopls/internal/lsp/source"
+	"golang.org/x/tools/gopls/internal/span"
+)
+
+// SpecialImports reports the blank and dot imports of the workspace. Only
+// file headers are parsed, using the cache.
+func (s *snapshot) SpecialImports(ctx context.Context) (map[span.URI][]source.SpecialImport, error) {
+	if err := s.awaitLoaded(ctx); err != nil {
+		return nil, err
+	}
+	uris := make(map[span.URI]bool)
+	for _, m := range s.workspaceMetadata() {
+		for _, uri := range m.GoFiles {
+			uris[uri] = true
+		}
+	}
+
+	result := make(map[span.URI][]source.SpecialImport)
+	for uri := range uris {
+		if s.view.gomodcache != "" && source.InDir(s.view.gomodcache, uri.Filename()) {
+			continue
+		}
+		fh, err := s.GetFile(ctx, uri)
+		if err != nil {
+			return nil, err
+		}
+		pgf, err := s.ParseGo(ctx, fh, source.ParseHeader)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue // e.g. the file was deleted
+		}
+		imports, err := specialImports(pgf)
+		if err != nil {
+			return nil, err
+		}
+		if len(imports) > 0 {
+			result[uri] = imports
+		}
+	}
+	return result, nil
+}
+
+// specialImports returns the blank and dot imports of the file, in order.
+func specialImports(pgf *source.ParsedGoFile) ([]source.SpecialImport, error) {
+	var imports []source.SpecialImport
+	for _, spec := range pgf.File.Imports {
+		if spec.Name == nil || (spec.Name.Name != "_" && spec.Name.Name != ".") {
+			continue
+		}
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue // a syntax error is reported by the parser
+		}
+		rng, err := pgf.NodeRange(spec)
+		if err != nil {
+			return nil, err
+		}
+		imports = append(imports, source.SpecialImport{
+			Dot:   spec.Name.Name == ".",
+			Path:  source.ImportPath(path),
+			Range: rng,
+		})
+	}
+	return imports, nil
+}
@@ -0,0 +1,61 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"go/parser"
+	"go/token"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/lsp/source"
+	"golang.org/x/tools/gopls/internal/span"
+)
+
+func TestSpecialImports(t *testing.T) {
+	const src = `package p
+
+import (
+	"fmt"
+	_ "embed"
+	. "strings"
+	s "sort"
+)
+
+import _ "net/http/pprof"
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, parser.ImportsOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uri := span.URIFromPath("/p.go")
+	pgf := &source.ParsedGoFile{
+		URI:    uri,
+		File:   f,
+		Tok:    fset.File(f.Pos()),
+		Mapper: protocol.NewMapper(uri, []byte(src)),
+	}
+
+	got, err := specialImports(pgf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rng := func(line, start, end uint32) protocol.Range {
+		return protocol.Range{
+			Start: protocol.Position{Line: line, Character: start},
+			End:   protocol.Position{Line: line, Character: end},
+		}
+	}
+	want := []source.SpecialImport{
+		{Path: "embed", Range: rng(4, 1, 10)},
+		{Dot: true, Path: "strings", Range: rng(5, 1, 12)},
+		{Path: "net/http/pprof", Range: rng(9, 7, 25)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("specialImports() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"sync/atomic"
+
+	"golang.org/x/tools/gopls/internal/lsp/source"
+)
+
+// cacheCounters counts the hits and misses of a view's caches of parsed
+// files, package handles, and analysis results. Its fields are accessed
+// atomically, so it must be allocated separately to ensure their 64-bit
+// alignment.
+type cacheCounters struct {
+	parseHits, parseMisses         int64
+	typeCheckHits, typeCheckMisses int64
+	analysisHits, analysisMisses   int64
+}
+
+// record increments hits if hit, and misses otherwise.
+func (c *cacheCounters) record(hits, misses *int64, hit bool) {
+	if hit {
+		atomic.AddInt64(hits, 1)
+	} else {
+		atomic.AddInt64(misses, 1)
+	}
+}
+
+// CacheStats returns the hit and miss counts of the caches of the view's
+// snapshots, and the number of entries in the caches of this snapshot.
+func (s *snapshot) CacheStats() source.CacheStats {
+	c := s.view.stats
+	stats := source.CacheStats{
+		ParseHits:       atomic.LoadInt64(&c.parseHits),
+		ParseMisses:     atomic.LoadInt64(&c.parseMisses),
+		TypeCheckHits:   atomic.LoadInt64(&c.typeCheckHits),
+		TypeCheckMisses: atomic.LoadInt64(&c.typeCheckMisses),
+		AnalysisHits:    atomic.LoadInt64(&c.analysisHits),
+		AnalysisMisses:  atomic.LoadInt64(&c.analysisMisses),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.parsedGoFiles.Range(func(_, _ interface{}) { stats.ParseEntries++ })
+	s.packages.Range(func(_, _ interface{}) { stats.TypeCheckEntries++ })
+	s.analyses.Range(func(_, _ interface{}) { stats.AnalysisEntries++ })
+	return stats
+}
@@ -0,0 +1,152 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/gopls/internal/lsp/source"
+	"golang.org/x/tools/gopls/internal/span"
+	"golang.org/x/tools/internal/imports"
+)
+
+// SuggestImports searches the packages known to the imports ProcessEnv,
+// once for each distinct package name of identifiers.
+func (s *snapshot) SuggestImports(ctx context.Context, uri span.URI, identifiers []string) (map[string]source.ImportSuggestion, error) {
+	fh, err := s.GetFile(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	pgf, err := s.ParseGo(ctx, fh, source.ParseHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	// Names already imported by the file cannot be resolved by another import.
+	imported := make(map[string]bool)
+	for _, spec := range pgf.File.Imports {
+		if spec.Name != nil {
+			imported[spec.Name.Name] = true
+		} else if path, err := strconv.Unquote(spec.Path.Value); err == nil {
+			imported[imports.ImportPathToAssumedName(path)] = true
+		}
+	}
+
+	candidates := make(map[string][]importCandidate) // by package name
+	for _, id := range identifiers {
+		name, _ := splitQualifiedIdent(id)
+		if name == "" || imported[name] {
+			continue
+		}
+		if _, ok := candidates[name]; ok {
+			continue
+		}
+		var cands []importCandidate
+		err := s.RunProcessEnvFunc(ctx, func(opts *imports.Options) error {
+			var err error
+			cands, err = importCandidates(ctx, name, uri.Filename(), pgf.File.Name.Name, opts.Env)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		candidates[name] = cands
+	}
+
+	result := make(map[string]source.ImportSuggestion)
+	for _, id := range identifiers {
+		name, sel := splitQualifiedIdent(id)
+		if suggestion, ok := suggestImport(candidates[name], sel); ok {
+			result[id] = suggestion
+		}
+	}
+	return result, nil
+}
+
+// An importCandidate is a package that may be imported to resolve a package
+// name.
+type importCandidate struct {
+	path      source.ImportPath
+	name      string   // explicit import name required, if any
+	relevance float64  // higher is better
+	exports   []string // sorted
+}
+
+// importCandidates returns the packages named name that may be imported by
+// the file filename of package filePkg.
+func importCandidates(ctx context.Context, name, filename, filePkg string, env *imports.ProcessEnv) ([]importCandidate, error) {
+	var (
+		mu    sync.Mutex // guards cands; add is called concurrently
+		cands []importCandidate
+	)
+	add := func(export imports.PackageExport) {
+		mu.Lock()
+		defer mu.Unlock()
+		cands = append(cands, importCandidate{
+			path:      source.ImportPath(export.Fix.StmtInfo.ImportPath),
+			name:      export.Fix.StmtInfo.Name,
+			relevance: export.Fix.Relevance,
+			exports:   export.Exports,
+		})
+	}
+	if err := imports.GetPackageExports(ctx, add, name, filename, filePkg, env); err != nil {
+		return nil, err
+	}
+	return cands, nil
+}
+
+// splitQualifiedIdent splits an identifier such as "rand.Int" into its
+// package name and selected name. The selected name of an unqualified
+// identifier is empty.
+func splitQualifiedIdent(id string) (name, sel string) {
+	if i := strings.IndexByte(id, '.'); i >= 0 {
+		return id[:i], id[i+1:]
+	}
+	return id, ""
+}
+
+// suggestImport chooses among the candidates the one that best resolves an
+// identifier whose selected name, if non-empty, is sel. Candidates that do
+// not export sel are discarded. The remaining ones are ordered by
+// decreasing relevance, then by increasing path length and path.
+func suggestImport(candidates []importCandidate, sel string) (source.ImportSuggestion, bool) {
+	var matches []importCandidate
+	for _, c := range candidates {
+		if sel != "" {
+			i := sort.SearchStrings(c.exports, sel)
+			if i == len(c.exports) || c.exports[i] != sel {
+				continue
+			}
+		}
+		matches = append(matches, c)
+	}
+	if len(matches) == 0 {
+		return source.ImportSuggestion{}, false
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		mi, mj := matches[i], matches[j]
+		if mi.relevance != mj.relevance {
+			return mi.relevance > mj.relevance
+		}
+		if len(mi.path) != len(mj.path) {
+			return len(mi.path) < len(mj.path)
+		}
+		return mi.path < mj.path
+	})
+	suggestion := source.ImportSuggestion{
+		Path: matches[0].path,
+		Name: matches[0].name,
+	}
+	for _, m := range matches[1:] {
+		if m.path != suggestion.Path { // the same package may be found in several roots
+			suggestion.Alternatives = append(suggestion.Alternatives, m.path)
+		}
+	}
+	return suggestion, true
+}
@@ -0,0 +1,98 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/gopls/internal/lsp/source"
+	"golang.org/x/tools/internal/gocommand"
+	"golang.org/x/tools/internal/imports"
+)
+
+func TestSuggestImport(t *testing.T) {
+	candidates := []importCandidate{
+		{path: "example.com/rand", relevance: 1, exports: []string{"Int", "Shuffle"}},
+		{path: "crypto/rand", relevance: 7, exports: []string{"Int", "Read"}},
+		{path: "math/rand", relevance: 7, exports: []string{"Int", "Intn", "Shuffle"}},
+	}
+	tests := []struct {
+		sel  string
+		want source.ImportSuggestion
+		ok   bool
+	}{
+		{"", source.ImportSuggestion{Path: "math/rand", Alternatives: []source.ImportPath{"crypto/rand", "example.com/rand"}}, true},
+		{"Int", source.ImportSuggestion{Path: "math/rand", Alternatives: []source.ImportPath{"crypto/rand", "example.com/rand"}}, true},
+		{"Read", source.ImportSuggestion{Path: "crypto/rand"}, true},
+		{"Shuffle", source.ImportSuggestion{Path: "math/rand", Alternatives: []source.ImportPath{"example.com/rand"}}, true},
+		{"Missing", source.ImportSuggestion{}, false},
+	}
+	for _, test := range tests {
+		got, ok := suggestImport(candidates, test.sel)
+		if ok != test.ok || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("suggestImport(%q) = %+v, %t, want %+v, %t", test.sel, got, ok, test.want, test.ok)
+		}
+	}
+
+	for _, test := range []struct{ id, name, sel string }{
+		{"rand", "rand", ""},
+		{"rand.Int", "rand", "Int"},
+	} {
+		if name, sel := splitQualifiedIdent(test.id); name != test.name || sel != test.sel {
+			t.Errorf("splitQualifiedIdent(%q) = %q, %q, want %q, %q", test.id, name, sel, test.name, test.sel)
+		}
+	}
+}
+
+func TestImportCandidates(t *testing.T) {
+	// Many packages of the same name, so that their exports are loaded
+	// concurrently.
+	gopath := t.TempDir()
+	var want []source.ImportPath
+	for i := 0; i < 20; i++ {
+		path := fmt.Sprintf("example.com/p%d/rand", i)
+		dir := filepath.Join(gopath, "src", filepath.FromSlash(path))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "rand.go"), []byte("package rand\n\nfunc Int() int { return 0 }\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, source.ImportPath(path))
+	}
+	env := &imports.ProcessEnv{
+		Env: map[string]string{
+			"GOPATH":      gopath,
+			"GO111MODULE": "off",
+			"GOFLAGS":     "",
+		},
+		WorkingDir:  gopath,
+		GocmdRunner: &gocommand.Runner{},
+	}
+	filename := filepath.Join(gopath, "src", "example.com", "main", "main.go")
+	cands, err := importCandidates(context.Background(), "rand", filename, "main", env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []source.ImportPath
+	for _, c := range cands {
+		if strings.HasPrefix(string(c.path), "example.com/") {
+			got = append(got, c.path)
+		}
+	}
+	sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+	sort.Slice(want, func(i, j int) bool { return want[i] < want[j] })
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("importCandidates() = %v, want %v", got, want)
+	}
+}
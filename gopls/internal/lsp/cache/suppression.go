@@ -0,0 +1,126 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/tools/gopls/internal/lsp/safetoken"
+	"golang.org/x/tools/gopls/internal/lsp/source"
+	"golang.org/x/tools/gopls/internal/span"
+)
+
+// SuppressedDiagnostics returns the diagnostics of the given file that lie
+// on lines suppressed by a comment with one of the prefixes configured by
+// the SuppressionComments option. Each returned diagnostic is a copy tagged
+// with source.SuppressedTag, whose related information includes the
+// suppressing comment.
+func (s *snapshot) SuppressedDiagnostics(ctx context.Context, uri span.URI) ([]*source.Diagnostic, error) {
+	prefixes := s.view.Options().SuppressionComments
+	if len(prefixes) == 0 {
+		return nil, nil
+	}
+	fh, err := s.GetFile(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	pgf, err := s.ParseGo(ctx, fh, source.ParseFull)
+	if err != nil {
+		return nil, err
+	}
+	suppressed := suppressedLines(pgf, prefixes)
+	if len(suppressed) == 0 {
+		return nil, nil
+	}
+	_, diags, err := source.FileDiagnostics(ctx, s, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	return suppressDiagnostics(pgf, suppressed, diags)
+}
+
+// suppressDiagnostics returns a tagged copy of each diagnostic of the file
+// pgf that lies on a line suppressed by one of the comments in suppressed,
+// as returned by suppressedLines.
+func suppressDiagnostics(pgf *source.ParsedGoFile, suppressed map[int]*ast.Comment, diags []*source.Diagnostic) ([]*source.Diagnostic, error) {
+	var result []*source.Diagnostic
+	for _, diag := range diags {
+		comment, ok := suppressed[int(diag.Range.Start.Line)+1]
+		if !ok {
+			continue
+		}
+		rng, err := pgf.NodeRange(comment)
+		if err != nil {
+			return nil, err
+		}
+		clone := *diag
+		clone.Tags = append(clone.Tags[:len(clone.Tags):len(clone.Tags)], source.SuppressedTag)
+		clone.Related = append(clone.Related[:len(clone.Related):len(clone.Related)], source.RelatedInformation{
+			URI:     pgf.URI,
+			Range:   rng,
+			Message: fmt.Sprintf("suppressed by %s", comment.Text),
+		})
+		result = append(result, &clone)
+	}
+	return result, nil
+}
+
+// suppressedLines returns the suppression comments of the file, keyed by
+// the (1-based) line whose diagnostics they suppress.
+//
+// A line comment whose text begins with one of the prefixes, followed by
+// the end of the comment or a non-alphanumeric character, suppresses
+// diagnostics on its own line, or on the following line if nothing precedes
+// it on its line.
+func suppressedLines(pgf *source.ParsedGoFile, prefixes []string) map[int]*ast.Comment {
+	lines := make(map[int]*ast.Comment)
+	for _, cg := range pgf.File.Comments {
+		for _, c := range cg.List {
+			if !isSuppressionComment(c.Text, prefixes) {
+				continue
+			}
+			offset, err := safetoken.Offset(pgf.Tok, c.Slash)
+			if err != nil {
+				continue
+			}
+			line := pgf.Tok.Line(c.Slash)
+			lineStart, err := safetoken.Offset(pgf.Tok, pgf.Tok.LineStart(line))
+			if err != nil {
+				continue
+			}
+			if len(bytes.TrimSpace(pgf.Src[lineStart:offset])) == 0 {
+				line++ // the comment is alone on its line
+			}
+			lines[line] = c
+		}
+	}
+	return lines
+}
+
+// isSuppressionComment reports whether the text of a comment is a line
+// comment beginning with one of the prefixes, as a whole word.
+func isSuppressionComment(text string, prefixes []string) bool {
+	if !strings.HasPrefix(text, "//") {
+		return false // a general comment
+	}
+	text = strings.TrimLeftFunc(text[len("//"):], unicode.IsSpace)
+	for _, prefix := range prefixes {
+		rest := strings.TrimPrefix(text, prefix)
+		if prefix == "" || rest == text {
+			continue
+		}
+		if r, _ := utf8.DecodeRuneInString(rest); rest == "" || !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"go/parser"
+	"go/token"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/lsp/source"
+	"golang.org/x/tools/gopls/internal/span"
+)
+
+func TestSuppressedLines(t *testing.T) {
+	const src = `package p
+
+var a = 1 //nolint
+var b = 2 // nolint:errcheck
+
+//nolint
+var c = 3
+
+var d = 4 // nolintx
+var e = 5 /* nolint */
+// lint:ignore
+var f = 6
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pgf := &source.ParsedGoFile{File: f, Tok: fset.File(f.Pos()), Src: []byte(src)}
+
+	got := make(map[int]string)
+	for line, c := range suppressedLines(pgf, []string{"nolint", "lint:ignore"}) {
+		got[line] = c.Text
+	}
+	want := map[int]string{
+		3:  "//nolint",
+		4:  "// nolint:errcheck",
+		7:  "//nolint",
+		12: "// lint:ignore",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("suppressedLines() = %v, want %v", got, want)
+	}
+}
+
+func TestSuppressDiagnostics(t *testing.T) {
+	const src = `package p
+
+var a = 1 //nolint
+var b = 2
+`
+	uri := span.URIFromPath("/p.go")
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, uri.Filename(), src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pgf := &source.ParsedGoFile{URI: uri, File: f, Tok: fset.File(f.Pos()), Src: []byte(src), Mapper: protocol.NewMapper(uri, []byte(src))}
+	line := func(n uint32) protocol.Range {
+		return protocol.Range{Start: protocol.Position{Line: n - 1}, End: protocol.Position{Line: n - 1, Character: 3}}
+	}
+	unnecessary := &source.Diagnostic{URI: uri, Range: line(3), Message: "a", Tags: []protocol.DiagnosticTag{protocol.Unnecessary}}
+	diags := []*source.Diagnostic{
+		unnecessary,
+		{URI: uri, Range: line(4), Message: "b"},
+	}
+
+	got, err := suppressDiagnostics(pgf, suppressedLines(pgf, []string{"nolint"}), diags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("suppressDiagnostics returned %d diagnostics, want 1: %v", len(got), got)
+	}
+	d := got[0]
+	if d == unnecessary || d.Message != "a" {
+		t.Errorf("suppressDiagnostics()[0] = %v, want a copy of the diagnostic on line 3", d)
+	}
+	if want := []protocol.DiagnosticTag{protocol.Unnecessary, source.SuppressedTag}; !reflect.DeepEqual(d.Tags, want) {
+		t.Errorf("suppressDiagnostics()[0].Tags = %v, want %v", d.Tags, want)
+	}
+	if len(unnecessary.Tags) != 1 {
+		t.Errorf("suppressDiagnostics modified the tags of its input: %v", unnecessary.Tags)
+	}
+	want := source.RelatedInformation{
+		URI:     uri,
+		Range:   protocol.Range{Start: protocol.Position{Line: 2, Character: 10}, End: protocol.Position{Line: 2, Character: 18}},
+		Message: "suppressed by //nolint",
+	}
+	if len(d.Related) != 1 || d.Related[0] != want {
+		t.Errorf("suppressDiagnostics()[0].Related = %v, want [%v]", d.Related, want)
+	}
+}
@@ -6,10 +6,12 @@ package cache
 
 import (
 	"context"
+	"fmt"
 	"go/ast"
 	"go/parser"
 	"go/token"
 	"go/types"
+	"path/filepath"
 	"strings"
 
 	"golang.org/x/tools/gopls/internal/lsp/protocol"
@@ -241,3 +243,85 @@ func (w *symbolWalker) walkField(field *ast.Field, unnamedKind, namedKind protoc
 		w.walkType(field.Type, append(path, name)...)
 	}
 }
+
+// DeclarationAt returns the symbol of the top-level declaration enclosing
+// the start of loc, or a symbol for the file itself if loc falls between
+// declarations.
+func (s *snapshot) DeclarationAt(ctx context.Context, loc protocol.Location) (*protocol.DocumentSymbol, error) {
+	fh, err := s.GetFile(ctx, loc.URI.SpanURI())
+	if err != nil {
+		return nil, err
+	}
+	symbols, err := source.DocumentSymbols(ctx, s, fh)
+	if err != nil {
+		return nil, err
+	}
+	for i, sym := range symbols {
+		if protocol.ComparePosition(sym.Range.Start, loc.Range.Start) <= 0 && protocol.ComparePosition(loc.Range.Start, sym.Range.End) < 0 {
+			return &symbols[i], nil
+		}
+	}
+
+	pgf, err := s.ParseGo(ctx, fh, source.ParseHeader)
+	if err != nil {
+		return nil, err
+	}
+	rng, err := pgf.Mapper.OffsetRange(0, len(pgf.Mapper.Content))
+	if err != nil {
+		return nil, err
+	}
+	sym := &protocol.DocumentSymbol{
+		Name:           filepath.Base(fh.URI().Filename()),
+		Kind:           protocol.File,
+		Range:          rng,
+		SelectionRange: rng,
+	}
+	if pgf.File.Name != nil {
+		sym.Detail = "package " + pgf.File.Name.Name
+		if nameRng, err := pgf.NodeRange(pgf.File.Name); err == nil {
+			sym.SelectionRange = nameRng
+		}
+	}
+	return sym, nil
+}
+
+// DefinitionOf returns the location of the declaration of pkgPath.name.
+func (s *snapshot) DefinitionOf(ctx context.Context, pkgPath PackagePath, name string) (protocol.Location, error) {
+	pkg, err := source.PackageForPath(ctx, s, pkgPath, source.TypecheckFull, source.NarrowestPackage)
+	if err != nil {
+		return protocol.Location{}, err
+	}
+	obj, err := lookupQualified(pkg.GetTypes(), name)
+	if err != nil {
+		return protocol.Location{}, err
+	}
+	return objLocation(pkg, obj)
+}
+
+// lookupQualified returns the package-level object of pkg with the given
+// name, or, if the name has the form "T.M", the field or method M of the
+// package-level type T.
+func lookupQualified(pkg *types.Package, name string) (types.Object, error) {
+	typeName, member := name, ""
+	if i := strings.Index(name, "."); i >= 0 {
+		typeName, member = name[:i], name[i+1:]
+	}
+	obj := pkg.Scope().Lookup(typeName)
+	if obj == nil {
+		return nil, fmt.Errorf("no object %s in package %s", typeName, pkg.Path())
+	}
+	if member == "" {
+		return obj, nil
+	}
+	if _, ok := obj.(*types.TypeName); !ok {
+		return nil, fmt.Errorf("%s is not a type", obj)
+	}
+	sel, _, _ := types.LookupFieldOrMethod(obj.Type(), true, pkg, member)
+	if sel == nil {
+		return nil, fmt.Errorf("type %s has no field or method %s", typeName, member)
+	}
+	if sel.Pkg() != pkg {
+		return nil, fmt.Errorf("%s.%s is declared in package %s", typeName, member, sel.Pkg().Path())
+	}
+	return sel, nil
+}
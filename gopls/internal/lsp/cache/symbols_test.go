@@ -0,0 +1,70 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+)
+
+func TestLookupQualified(t *testing.T) {
+	const src = `package p
+
+import "strings"
+
+type T struct {
+	strings.Builder
+	F int
+}
+
+func (T) M() {}
+
+var V T
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := types.Config{Importer: importer.Default()}
+	pkg, err := conf.Check("p", fset, []*ast.File{file}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		want string // empty => error
+	}{
+		{"T", "type p.T struct{strings.Builder; F int}"},
+		{"V", "var p.V p.T"},
+		{"T.M", "func (p.T).M()"},
+		{"T.F", "field F int"},
+		{"T.String", ""}, // promoted from another package
+		{"V.F", ""},
+		{"U", ""},
+		{"T.G", ""},
+	}
+	for _, test := range tests {
+		obj, err := lookupQualified(pkg, test.name)
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("lookupQualified(%q) = %v, want error", test.name, obj)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("lookupQualified(%q) failed: %v", test.name, err)
+			continue
+		}
+		if got := obj.String(); got != test.want {
+			t.Errorf("lookupQualified(%q) = %s, want %s", test.name, got, test.want)
+		}
+	}
+}
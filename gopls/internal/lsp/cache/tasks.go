@@ -0,0 +1,126 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"go/ast"
+	"go/token"
+	"strings"
+
+	"golang.org/x/tools/gopls/internal/lsp/source"
+	"golang.org/x/tools/gopls/internal/span"
+)
+
+func (s *snapshot) TaskComments(ctx context.Context, markers []string) (map[span.URI][]source.TaskComment, error) {
+	if err := s.awaitLoaded(ctx); err != nil {
+		return nil, err
+	}
+	if len(markers) == 0 {
+		markers = source.DefaultTaskMarkers
+	}
+
+	// Scan the files of workspace packages and open Go files, other than
+	// those in the module cache.
+	uris := make(map[span.URI]bool)
+	for _, m := range s.workspaceMetadata() {
+		for _, uri := range m.CompiledGoFiles {
+			uris[uri] = true
+		}
+	}
+	for _, fh := range s.openFiles() {
+		if s.view.FileKind(fh) == source.Go {
+			uris[fh.URI()] = true
+		}
+	}
+
+	result := make(map[span.URI][]source.TaskComment)
+	for uri := range uris {
+		if s.view.gomodcache != "" && source.InDir(s.view.gomodcache, uri.Filename()) {
+			continue
+		}
+		fh, err := s.GetFile(ctx, uri)
+		if err != nil {
+			return nil, err
+		}
+		pgf, err := s.ParseGo(ctx, fh, source.ParseFull)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue // e.g. the file was deleted
+		}
+		tasks, err := taskComments(pgf, markers)
+		if err != nil {
+			return nil, err
+		}
+		if len(tasks) > 0 {
+			result[uri] = tasks
+		}
+	}
+	return result, nil
+}
+
+// taskComments returns the task comments of the file, in order: the lines
+// of its comments that begin with one of the markers, followed by a
+// character other than a letter, digit, or underscore.
+func taskComments(pgf *source.ParsedGoFile, markers []string) ([]source.TaskComment, error) {
+	var tasks []source.TaskComment
+	for _, cg := range pgf.File.Comments {
+		for _, c := range cg.List {
+			found, err := commentTasks(pgf, c, markers)
+			if err != nil {
+				return nil, err
+			}
+			tasks = append(tasks, found...)
+		}
+	}
+	return tasks, nil
+}
+
+// commentTasks returns the task comments within the comment c of pgf.
+func commentTasks(pgf *source.ParsedGoFile, c *ast.Comment, markers []string) ([]source.TaskComment, error) {
+	text := c.Text[2:] // strip the "//" or "/*"
+	offset := 2
+	if strings.HasPrefix(c.Text, "/*") {
+		text = strings.TrimSuffix(text, "*/")
+	}
+	var tasks []source.TaskComment
+	for _, line := range strings.SplitAfter(text, "\n") {
+		lineOffset := offset
+		offset += len(line)
+
+		line = strings.TrimRight(line, "\r\n")
+		trimmed := strings.TrimLeft(line, " \t*")
+		for _, marker := range markers {
+			if !strings.HasPrefix(trimmed, marker) || !endsWord(trimmed[len(marker):]) {
+				continue
+			}
+			start := c.Pos() + token.Pos(lineOffset+len(line)-len(trimmed))
+			end := c.Pos() + token.Pos(lineOffset+len(strings.TrimRight(line, " \t")))
+			rng, err := pgf.PosRange(start, end)
+			if err != nil {
+				return nil, err
+			}
+			tasks = append(tasks, source.TaskComment{
+				Marker: marker,
+				Text:   strings.TrimSpace(trimmed),
+				Range:  rng,
+			})
+			break
+		}
+	}
+	return tasks, nil
+}
+
+// endsWord reports whether rest, the text following a marker, does not
+// continue the marker's word.
+func endsWord(rest string) bool {
+	if rest == "" {
+		return true
+	}
+	c := rest[0]
+	return !(c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z')
+}
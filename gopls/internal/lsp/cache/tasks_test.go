@@ -0,0 +1,66 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"go/parser"
+	"go/token"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/lsp/source"
+	"golang.org/x/tools/gopls/internal/span"
+)
+
+func TestTaskComments(t *testing.T) {
+	const src = `package p
+
+// TODO(someone): first
+func f() {} // FIXME trailing
+
+/*
+ * BUG: in a block
+ * TODOS are not tasks
+ */
+
+// TODONT is not a task either.
+// NOTE: not a default marker
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uri := span.URIFromPath("/p.go")
+	pgf := &source.ParsedGoFile{URI: uri, File: f, Tok: fset.File(f.Pos()), Mapper: protocol.NewMapper(uri, []byte(src))}
+
+	tasks, err := taskComments(pgf, source.DefaultTaskMarkers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rng := func(line, start, end uint32) protocol.Range {
+		return protocol.Range{
+			Start: protocol.Position{Line: line, Character: start},
+			End:   protocol.Position{Line: line, Character: end},
+		}
+	}
+	want := []source.TaskComment{
+		{Marker: "TODO", Text: "TODO(someone): first", Range: rng(2, 3, 23)},
+		{Marker: "FIXME", Text: "FIXME trailing", Range: rng(3, 15, 29)},
+		{Marker: "BUG", Text: "BUG: in a block", Range: rng(6, 3, 18)},
+	}
+	if !reflect.DeepEqual(tasks, want) {
+		t.Errorf("taskComments() = %+v, want %+v", tasks, want)
+	}
+
+	tasks, err = taskComments(pgf, []string{"NOTE"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].Text != "NOTE: not a default marker" {
+		t.Errorf("taskComments(NOTE) = %+v, want the NOTE comment", tasks)
+	}
+}
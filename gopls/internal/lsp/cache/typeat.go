@@ -0,0 +1,111 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/lsp/source"
+	"golang.org/x/tools/gopls/internal/span"
+)
+
+func (s *snapshot) TypeAt(ctx context.Context, uri span.URI, pp protocol.Position) (types.Type, error) {
+	pkg, pgf, err := source.PackageForFile(ctx, s, uri, source.TypecheckFull, source.NarrowestPackage)
+	if err != nil {
+		return nil, err
+	}
+	pos, err := pgf.Pos(pp)
+	if err != nil {
+		return nil, err
+	}
+	return typeAt(pkg.GetTypesInfo(), pgf.File, pos)
+}
+
+// typeAt returns the type recorded in info for the innermost node of file
+// enclosing pos, or ErrNoExpression if that node is not an expression
+// with a type, such as a keyword or a package name.
+func typeAt(info *types.Info, file *ast.File, pos token.Pos) (types.Type, error) {
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+	if len(path) == 0 {
+		return nil, source.ErrNoExpression
+	}
+	expr, ok := path[0].(ast.Expr)
+	if !ok {
+		return nil, source.ErrNoExpression
+	}
+	if id, ok := expr.(*ast.Ident); ok {
+		if _, ok := info.Uses[id].(*types.PkgName); ok {
+			return nil, source.ErrNoExpression
+		}
+	}
+	t := info.TypeOf(expr)
+	if t == nil {
+		return nil, source.ErrNoExpression
+	}
+	return t, nil
+}
+
+func (s *snapshot) ReceiverTypeAt(ctx context.Context, uri span.URI, pp protocol.Position) (*types.Named, error) {
+	pkg, pgf, err := source.PackageForFile(ctx, s, uri, source.TypecheckFull, source.NarrowestPackage)
+	if err != nil {
+		return nil, err
+	}
+	pos, err := pgf.Pos(pp)
+	if err != nil {
+		return nil, err
+	}
+	return receiverTypeAt(pkg.GetTypesInfo(), pgf.File, pos)
+}
+
+// receiverTypeAt returns the named receiver type of the method selected by
+// the innermost method call or method value of file enclosing pos, or else
+// of the method declaration enclosing pos. It returns ErrNoMethod if there
+// is no such method, or its receiver type is not named.
+func receiverTypeAt(info *types.Info, file *ast.File, pos token.Pos) (*types.Named, error) {
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+	for _, n := range path {
+		var method types.Object
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			if sel, ok := astutil.Unparen(n.Fun).(*ast.SelectorExpr); ok {
+				if selection := info.Selections[sel]; selection != nil && selection.Kind() != types.FieldVal {
+					method = selection.Obj()
+				}
+			}
+		case *ast.SelectorExpr:
+			if selection := info.Selections[n]; selection != nil && selection.Kind() != types.FieldVal {
+				method = selection.Obj()
+			}
+		case *ast.FuncDecl:
+			if n.Recv == nil {
+				return nil, source.ErrNoMethod
+			}
+			method = info.Defs[n.Name]
+		}
+		if method == nil {
+			continue
+		}
+		// Report the type declaring the method, which for a promoted
+		// method is the embedded type.
+		fn, ok := method.(*types.Func)
+		if !ok {
+			return nil, source.ErrNoMethod
+		}
+		recv := fn.Type().(*types.Signature).Recv()
+		if recv == nil {
+			return nil, source.ErrNoMethod
+		}
+		if named, ok := source.Deref(recv.Type()).(*types.Named); ok {
+			return named, nil
+		}
+		return nil, source.ErrNoMethod
+	}
+	return nil, source.ErrNoMethod
+}
@@ -0,0 +1,144 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/gopls/internal/lsp/source"
+)
+
+func TestTypeAt(t *testing.T) {
+	const src = `package p
+
+import "strings"
+
+func f(s string) int {
+	n := len(s)
+	return n + strings.Count(s, "x")
+}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Defs:  make(map[*ast.Ident]types.Object),
+		Uses:  make(map[*ast.Ident]types.Object),
+	}
+	conf := types.Config{Importer: importer.Default()}
+	if _, err := conf.Check("p", fset, []*ast.File{file}, info); err != nil {
+		t.Fatal(err)
+	}
+	pos := func(substr string) token.Pos {
+		return file.Pos() + token.Pos(strings.Index(src, substr))
+	}
+
+	tests := []struct {
+		pos  token.Pos
+		want string // empty => ErrNoExpression
+	}{
+		{pos("n :="), "int"},
+		{pos("s)"), "string"},
+		{pos("Count"), "func(s string, substr string) int"},
+		{pos("\"x\""), "string"},
+		{pos("return"), ""},
+		{pos("strings.Count"), ""}, // package name
+	}
+	for _, test := range tests {
+		got, err := typeAt(info, file, test.pos)
+		if test.want == "" {
+			if err != source.ErrNoExpression {
+				t.Errorf("typeAt(%v) = %v, %v, want ErrNoExpression", fset.Position(test.pos), got, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("typeAt(%v) failed: %v", fset.Position(test.pos), err)
+			continue
+		}
+		if got.String() != test.want {
+			t.Errorf("typeAt(%v) = %s, want %s", fset.Position(test.pos), got, test.want)
+		}
+	}
+}
+
+func TestReceiverTypeAt(t *testing.T) {
+	const src = `package p
+
+type Base struct{}
+
+func (Base) Hello() {}
+
+type T struct{ Base }
+
+func (t *T) Run(x int) int {
+	t.Hello()
+	return x + 1
+}
+
+type I interface{ Do() }
+
+func f(t T, i I) {
+	t.Run(2)
+	h := t.Hello
+	i.Do()
+	_ = h
+}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+	}
+	if _, err := new(types.Config).Check("p", fset, []*ast.File{file}, info); err != nil {
+		t.Fatal(err)
+	}
+	pos := func(substr string) token.Pos {
+		return file.Pos() + token.Pos(strings.Index(src, substr))
+	}
+
+	tests := []struct {
+		pos  token.Pos
+		want string // empty => ErrNoMethod
+	}{
+		{pos("x + 1"), "p.T"},                // within a method declaration
+		{pos("Hello()\n\treturn"), "p.Base"}, // promoted method call
+		{pos("2)"), "p.T"},                   // argument of a method call
+		{pos("Hello\n"), "p.Base"},           // method value
+		{pos("Do()\n\t_"), "p.I"},            // interface method call
+		{pos("_ = h"), ""},                   // in a function
+		{pos("struct{ Base }"), ""},          // at top level
+	}
+	for _, test := range tests {
+		got, err := receiverTypeAt(info, file, test.pos)
+		if test.want == "" {
+			if err != source.ErrNoMethod {
+				t.Errorf("receiverTypeAt(%v) = %v, %v, want ErrNoMethod", fset.Position(test.pos), got, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("receiverTypeAt(%v) failed: %v", fset.Position(test.pos), err)
+			continue
+		}
+		if got.String() != test.want {
+			t.Errorf("receiverTypeAt(%v) = %s, want %s", fset.Position(test.pos), got, test.want)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/lsp/source"
+	"golang.org/x/tools/gopls/internal/span"
+)
+
+// UnusedLocals reports, as warnings, the local variables of the given file
+// that are updated but never read.
+func (s *snapshot) UnusedLocals(ctx context.Context, uri span.URI) ([]*source.Diagnostic, error) {
+	pkg, pgf, err := source.PackageForFile(ctx, s, uri, source.TypecheckFull, source.NarrowestPackage)
+	if err != nil {
+		return nil, err
+	}
+	var diags []*source.Diagnostic
+	for _, v := range unusedLocals(pgf.File, pkg.GetTypesInfo()) {
+		rng, err := pgf.PosRange(v.Pos(), v.Pos()+token.Pos(len(v.Name())))
+		if err != nil {
+			return nil, err
+		}
+		diags = append(diags, &source.Diagnostic{
+			URI:      uri,
+			Range:    rng,
+			Severity: protocol.SeverityWarning,
+			Source:   source.UnusedLocal,
+			Message:  fmt.Sprintf("%s is updated but never read", v.Name()),
+			Tags:     []protocol.DiagnosticTag{protocol.Unnecessary},
+		})
+	}
+	return diags, nil
+}
+
+// unusedLocals returns the local variables declared in the function bodies
+// of file that are updated, by an assignment operation or an
+// increment/decrement statement, but are otherwise only assigned, sorted by
+// position.
+//
+// Variables that are only assigned are not reported: the type checker
+// considers them unused, and reports them itself.
+func unusedLocals(file *ast.File, info *types.Info) []*types.Var {
+	const (
+		assigned = iota + 1 // the identifier is the operand of a plain assignment
+		updated             // the identifier is the operand of an update
+	)
+	writes := make(map[*ast.Ident]int)
+	locals := make(map[*types.Var]bool)
+	declare := func(id *ast.Ident) {
+		if v, ok := info.Defs[id].(*types.Var); ok && v.Name() != "_" {
+			locals[v] = true
+		}
+	}
+
+	inBody := 0 // nesting depth of function bodies
+	var visit func(n ast.Node) bool
+	visit = func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			if n.Body != nil {
+				inBody++
+				ast.Inspect(n.Body, visit)
+				inBody--
+			}
+			return false
+
+		case *ast.FuncLit:
+			inBody++
+			ast.Inspect(n.Body, visit)
+			inBody--
+			return false
+
+		case *ast.AssignStmt:
+			for _, lhs := range n.Lhs {
+				id, ok := astutil.Unparen(lhs).(*ast.Ident)
+				if !ok {
+					continue
+				}
+				switch n.Tok {
+				case token.DEFINE:
+					declare(id)
+					writes[id] = assigned
+				case token.ASSIGN:
+					writes[id] = assigned
+				default:
+					writes[id] = updated
+				}
+			}
+
+		case *ast.IncDecStmt:
+			if id, ok := astutil.Unparen(n.X).(*ast.Ident); ok {
+				writes[id] = updated
+			}
+
+		case *ast.ValueSpec:
+			if inBody > 0 {
+				for _, id := range n.Names {
+					declare(id)
+				}
+			}
+
+		case *ast.RangeStmt:
+			if n.Tok == token.DEFINE {
+				for _, x := range []ast.Expr{n.Key, n.Value} {
+					if id, ok := x.(*ast.Ident); ok {
+						declare(id)
+					}
+				}
+			}
+		}
+		return true
+	}
+	ast.Inspect(file, visit)
+
+	read := make(map[*types.Var]bool)
+	isUpdated := make(map[*types.Var]bool)
+	for id, obj := range info.Uses {
+		v, ok := obj.(*types.Var)
+		if !ok || !locals[v] {
+			continue
+		}
+		switch writes[id] {
+		case assigned:
+		case updated:
+			isUpdated[v] = true
+		default:
+			read[v] = true
+		}
+	}
+
+	var unused []*types.Var
+	for v := range isUpdated {
+		if !read[v] {
+			unused = append(unused, v)
+		}
+	}
+	sort.Slice(unused, func(i, j int) bool { return unused[i].Pos() < unused[j].Pos() })
+	return unused
+}
+
+func (s *snapshot) UnusedFields(ctx context.Context, id PackageID) ([]*source.Diagnostic, error) {
+	rdeps, err := s.ReverseDependencies(ctx, id, true)
+	if err != nil {
+		return nil, err
+	}
+	ids := []PackageID{id}
+	for rdepID := range rdeps {
+		if s.isWorkspacePackage(rdepID) {
+			ids = append(ids, rdepID)
+		}
+	}
+	pkgs, err := s.TypeCheck(ctx, source.TypecheckFull, ids...)
+	if err != nil {
+		return nil, err
+	}
+	pkg := pkgs[0]
+
+	// Fields are identified by position, as the importing packages' test
+	// variants have their own objects for the same declarations.
+	fields := make(map[token.Pos]*types.Var)
+	for _, pgf := range pkg.CompiledGoFiles() {
+		for _, v := range declaredFields(pgf.File, pkg.GetTypesInfo()) {
+			fields[v.Pos()] = v
+		}
+	}
+	read := make(map[token.Pos]bool)
+	written := make(map[token.Pos]bool)
+	for _, p := range pkgs {
+		for _, pgf := range p.CompiledGoFiles() {
+			fieldAccesses(pgf.File, p.GetTypesInfo(), read, written)
+		}
+	}
+
+	var diags []*source.Diagnostic
+	for pos, v := range fields {
+		if read[pos] {
+			continue
+		}
+		msg := fmt.Sprintf("field %s is never used", v.Name())
+		if written[pos] {
+			msg = fmt.Sprintf("field %s is written but never read", v.Name())
+		}
+		pgf, err := pkg.File(span.URIFromPath(pkg.FileSet().File(pos).Name()))
+		if err != nil {
+			return nil, err
+		}
+		rng, err := pgf.PosRange(pos, pos+token.Pos(len(v.Name())))
+		if err != nil {
+			return nil, err
+		}
+		diags = append(diags, &source.Diagnostic{
+			URI:      pgf.URI,
+			Range:    rng,
+			Severity: protocol.SeverityHint,
+			Source:   source.UnusedField,
+			Message:  msg,
+			Tags:     []protocol.DiagnosticTag{protocol.Unnecessary},
+		})
+	}
+	sort.Slice(diags, func(i, j int) bool {
+		di, dj := diags[i], diags[j]
+		if di.URI == dj.URI {
+			return protocol.CompareRange(di.Range, dj.Range) < 0
+		}
+		return di.URI < dj.URI
+	})
+	return diags, nil
+}
+
+// declaredFields returns the named struct fields declared in file that are
+// candidates for UnusedFields: embedded fields, blank fields and fields
+// with struct tags are excluded.
+func declaredFields(file *ast.File, info *types.Info) []*types.Var {
+	var fields []*types.Var
+	ast.Inspect(file, func(n ast.Node) bool {
+		st, ok := n.(*ast.StructType)
+		if !ok {
+			return true
+		}
+		for _, field := range st.Fields.List {
+			if field.Tag != nil {
+				continue
+			}
+			for _, id := range field.Names {
+				if v, ok := info.Defs[id].(*types.Var); ok && v.IsField() && v.Name() != "_" {
+					fields = append(fields, v)
+				}
+			}
+		}
+		return true
+	})
+	return fields
+}
+
+// fieldAccesses records, by declaring position, the struct fields read and
+// written in file.
+//
+// A field is written if it is selected by the left operand of an
+// assignment or increment/decrement statement, or initialized by a
+// composite literal; any other reference to it is a read.
+func fieldAccesses(file *ast.File, info *types.Info, read, written map[token.Pos]bool) {
+	writes := make(map[*ast.Ident]bool)
+	markWrite := func(x ast.Expr) {
+		if sel, ok := astutil.Unparen(x).(*ast.SelectorExpr); ok {
+			writes[sel.Sel] = true
+		}
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			if n.Tok != token.DEFINE {
+				for _, lhs := range n.Lhs {
+					markWrite(lhs)
+				}
+			}
+
+		case *ast.IncDecStmt:
+			markWrite(n.X)
+
+		case *ast.CompositeLit:
+			tv, ok := info.Types[n]
+			if !ok {
+				break
+			}
+			st, ok := source.Deref(tv.Type).Underlying().(*types.Struct)
+			if !ok {
+				break
+			}
+			for i, elt := range n.Elts {
+				if kv, ok := elt.(*ast.KeyValueExpr); ok {
+					if id, ok := kv.Key.(*ast.Ident); ok {
+						writes[id] = true
+					}
+				} else if i < st.NumFields() {
+					written[st.Field(i).Pos()] = true
+				}
+			}
+		}
+		return true
+	})
+
+	for id, obj := range info.Uses {
+		v, ok := obj.(*types.Var)
+		if !ok || !v.IsField() {
+			continue
+		}
+		if writes[id] {
+			written[v.Pos()] = true
+		} else {
+			read[v.Pos()] = true
+		}
+	}
+}
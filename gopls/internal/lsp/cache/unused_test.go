@@ -0,0 +1,129 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"testing"
+)
+
+func TestUnusedLocals(t *testing.T) {
+	const src = `package p
+
+var global int
+
+func f(param int) int {
+	count := 0
+	for range []int{1, 2} {
+		count++ // updated but never read
+	}
+
+	var total int
+	total += 2 // updated but never read
+
+	sum := 0
+	sum += param
+	global++
+	param++
+	return sum
+}
+
+func g() func() {
+	n := 0
+	return func() {
+		n++ // updated but never read, in a closure
+	}
+}
+
+func h() {
+	ptr := 0
+	ptr++
+	_ = &ptr
+}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{
+		Defs: make(map[*ast.Ident]types.Object),
+		Uses: make(map[*ast.Ident]types.Object),
+	}
+	if _, err := new(types.Config).Check("p", fset, []*ast.File{file}, info); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, v := range unusedLocals(file, info) {
+		got = append(got, v.Name())
+	}
+	if want := []string{"count", "total", "n"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unusedLocals() = %v, want %v", got, want)
+	}
+}
+
+func TestFieldAccesses(t *testing.T) {
+	const src = `package p
+
+type T struct {
+	read    int
+	written int
+	updated int
+	unused  int
+	keyed   int
+	tagged  int ` + "`json:\"tagged\"`" + `
+	_       int
+	embedded
+}
+
+type embedded struct{}
+
+type pair struct{ a, b int }
+
+func f(t *T) int {
+	t.written = 1
+	(t.updated)++
+	_ = T{keyed: 1}
+	_ = pair{1, 2}
+	return t.read
+}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Defs:  make(map[*ast.Ident]types.Object),
+		Uses:  make(map[*ast.Ident]types.Object),
+	}
+	if _, err := new(types.Config).Check("p", fset, []*ast.File{file}, info); err != nil {
+		t.Fatal(err)
+	}
+
+	read := make(map[token.Pos]bool)
+	written := make(map[token.Pos]bool)
+	fieldAccesses(file, info, read, written)
+
+	var got []string
+	for _, v := range declaredFields(file, info) {
+		state := "unused"
+		if read[v.Pos()] {
+			state = "read"
+		} else if written[v.Pos()] {
+			state = "written"
+		}
+		got = append(got, v.Name()+":"+state)
+	}
+	want := []string{"read:read", "written:written", "updated:written", "unused:unused", "keyed:written", "a:written", "b:written"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("field accesses = %v, want %v", got, want)
+	}
+}
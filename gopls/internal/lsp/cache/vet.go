@@ -0,0 +1,248 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/tools/gopls/internal/lsp/filecache"
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/lsp/source"
+	"golang.org/x/tools/gopls/internal/span"
+	"golang.org/x/tools/internal/bug"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/event/tag"
+	"golang.org/x/tools/internal/gocommand"
+)
+
+// RunVet runs "go vet" on the package with the given id, and returns its
+// findings as diagnostics.
+//
+// The output of the go command is cached in the shared file cache, keyed by
+// the package's type-checking key, which accounts for the content of the
+// package and of its transitive dependencies.
+func (s *snapshot) RunVet(ctx context.Context, id PackageID) ([]*source.Diagnostic, error) {
+	ctx, done := event.Start(ctx, "cache.RunVet", tag.Package.Of(string(id)))
+	defer done()
+
+	ph, err := s.buildPackageHandle(ctx, id, source.ParseFull)
+	if err != nil {
+		return nil, err
+	}
+	key := vetCacheKey(ph.key, s.view.Options().BuildFlags)
+
+	const cacheKind = "vet"
+	data, err := filecache.Get(cacheKind, key)
+	switch err {
+	case nil:
+		// cache hit
+	case filecache.ErrNotFound:
+		data, err = vetImpl(ctx, s, ph.m)
+		if err != nil {
+			return nil, err
+		}
+		if err := filecache.Set(cacheKind, key, data); err != nil {
+			return nil, fmt.Errorf("internal error updating shared cache: %v", err)
+		}
+	default:
+		return nil, bug.Errorf("internal error reading shared cache: %v", err)
+	}
+	return vetDiagnostics(ctx, s, ph.m, data)
+}
+
+// vetCacheKey returns the shared cache key for the vet results of the package
+// with the given key.
+func vetCacheKey(key packageHandleKey, buildFlags []string) [sha256.Size]byte {
+	hasher := sha256.New()
+	fmt.Fprintf(hasher, "package: %s\n", source.Hash(key))
+	for _, flag := range buildFlags {
+		fmt.Fprintf(hasher, "flag: %s\n", flag)
+	}
+	var hash [sha256.Size]byte
+	hasher.Sum(hash[:0])
+	return hash
+}
+
+// vetImpl runs "go vet -json" on the package described by m, returning the
+// JSON output of the command.
+func vetImpl(ctx context.Context, s *snapshot, m *source.Metadata) ([]byte, error) {
+	inv := &gocommand.Invocation{
+		Verb:       "vet",
+		Args:       []string{"-json", string(m.PkgPath)},
+		WorkingDir: s.view.rootURI.Filename(),
+	}
+	if m.Config != nil && m.Config.Dir != "" {
+		inv.WorkingDir = m.Config.Dir
+	}
+
+	// Present unsaved edits to the go command.
+	if overlay := s.buildOverlay(); len(overlay) > 0 {
+		file, cleanup, err := writeOverlayFile(overlay)
+		if err != nil {
+			return nil, err
+		}
+		defer cleanup()
+		inv.Overlay = file
+	}
+
+	_, inv, cleanup, err := s.goCommandInvocation(ctx, source.Normal, inv)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	// go vet writes its JSON output to stderr, and exits with a non-zero
+	// status only if the package could not be built.
+	var stderr *bytes.Buffer
+	err = s.withGoCommandTimeout(ctx, source.Normal, func(ctx context.Context) error {
+		_, errBuf, friendlyErr, err := s.view.gocmdRunner.RunRaw(ctx, *inv)
+		if err != nil {
+			return err
+		}
+		stderr = errBuf
+		return friendlyErr
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stderr.Bytes(), nil
+}
+
+// writeOverlayFile writes the given overlay contents to a temporary
+// directory, along with an overlay file suitable for the -overlay flag of the
+// go command. It returns the name of the overlay file, and a function that
+// removes the temporary directory.
+func writeOverlayFile(overlay map[string][]byte) (string, func(), error) {
+	dir, err := ioutil.TempDir("", "gopls-overlay-")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	replace := make(map[string]string, len(overlay))
+	i := 0
+	for path, content := range overlay {
+		// Preserve the base name, as the go command relies on it to
+		// identify test files.
+		contentFile := filepath.Join(dir, fmt.Sprintf("%d-%s", i, filepath.Base(path)))
+		i++
+		if err := ioutil.WriteFile(contentFile, content, 0644); err != nil {
+			cleanup()
+			return "", nil, err
+		}
+		replace[path] = contentFile
+	}
+	data, err := json.Marshal(struct{ Replace map[string]string }{replace})
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	file := filepath.Join(dir, "overlay.json")
+	if err := ioutil.WriteFile(file, data, 0644); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return file, cleanup, nil
+}
+
+// A vetFinding is a single finding in the JSON output of go vet.
+type vetFinding struct {
+	Posn    string `json:"posn"`
+	End     string `json:"end"` // absent in older versions of go vet
+	Message string `json:"message"`
+}
+
+// vetDiagnostics converts the JSON output of go vet for the package described
+// by m into diagnostics.
+//
+// The output consists of a JSON object per package, each preceded by a
+// "# package" comment line. Each object maps package IDs to analyzer names
+// to findings.
+func vetDiagnostics(ctx context.Context, s *snapshot, m *source.Metadata, data []byte) ([]*source.Diagnostic, error) {
+	var dir string // findings are usually absolute, but resolve them just in case
+	if m.Config != nil {
+		dir = m.Config.Dir
+	}
+	var diags []*source.Diagnostic
+	mappers := make(map[span.URI]*protocol.Mapper)
+	dec := json.NewDecoder(bytes.NewReader(stripVetComments(data)))
+	for dec.More() {
+		var tree map[string]map[string]json.RawMessage
+		if err := dec.Decode(&tree); err != nil {
+			return nil, fmt.Errorf("decoding go vet output: %v", err)
+		}
+		for _, analyzers := range tree {
+			names := make([]string, 0, len(analyzers))
+			for name := range analyzers {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				// An analyzer reports either a list of findings,
+				// or an object describing its failure.
+				var findings []vetFinding
+				if err := json.Unmarshal(analyzers[name], &findings); err != nil {
+					continue
+				}
+				for _, f := range findings {
+					spn := span.ParseInDir(f.Posn, dir)
+					uri := spn.URI()
+					mapper, ok := mappers[uri]
+					if !ok {
+						fh, err := s.GetFile(ctx, uri)
+						if err != nil {
+							return nil, err
+						}
+						content, err := fh.Read()
+						if err != nil {
+							continue // the file no longer exists
+						}
+						mapper = protocol.NewMapper(uri, content)
+						mappers[uri] = mapper
+					}
+					if f.End != "" {
+						if end := span.ParseInDir(f.End, dir); end.URI() == uri {
+							spn = span.New(uri, spn.Start(), end.Start())
+						}
+					}
+					rng, err := mapper.SpanRange(spn)
+					if err != nil {
+						continue // the file has changed since go vet ran
+					}
+					diags = append(diags, &source.Diagnostic{
+						URI:      uri,
+						Range:    rng,
+						Severity: protocol.SeverityWarning,
+						Source:   source.VetError,
+						Code:     name,
+						Message:  f.Message,
+					})
+				}
+			}
+		}
+	}
+	return diags, nil
+}
+
+// stripVetComments removes the "# package" lines that go vet emits before
+// the JSON output for each package.
+func stripVetComments(data []byte) []byte {
+	var buf bytes.Buffer
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		if bytes.HasPrefix(line, []byte("#")) {
+			continue
+		}
+		buf.Write(line)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,119 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/internal/testenv"
+)
+
+func TestStripVetComments(t *testing.T) {
+	const data = `# example.com/a
+{
+	"example.com/a": {}
+}
+# example.com/a [example.com/a.test]
+{
+	"example.com/a [example.com/a.test]": {}
+}
+`
+	const want = `{
+	"example.com/a": {}
+}
+{
+	"example.com/a [example.com/a.test]": {}
+}
+`
+	if got := string(stripVetComments([]byte(data))); got != want {
+		t.Errorf("stripVetComments() = %q, want %q", got, want)
+	}
+}
+
+func TestVetDiagnostics(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	const src = `package a
+
+import "fmt"
+
+func f() {
+	fmt.Printf("%d", "x")
+	fmt.Printf("%s")
+}
+`
+	files := map[string]string{
+		"go.mod": "module example.com\n\ngo 1.18\n",
+		"a/a.go": src,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	view, s := newTestSnapshot(ctx, t, files, nil)
+	active, err := s.ActiveMetadata(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(active) != 1 {
+		t.Fatalf("got %d active packages, want 1", len(active))
+	}
+	filename := filepath.Join(view.Folder().Filename(), "a", "a.go")
+
+	// Output for a package and its test variant, with multi-line objects,
+	// a finding with an end position, one relative to the working
+	// directory, and an analyzer that failed.
+	data := fmt.Sprintf(`# example.com/a
+{
+	"example.com/a": {
+		"printf": [
+			{
+				"posn": %[1]q,
+				"end": %[2]q,
+				"message": "Printf format %%d has arg \"x\" of wrong type string"
+			}
+		],
+		"asmdecl": {
+			"error": "analysis failed"
+		}
+	}
+}
+# example.com/a [example.com/a.test]
+{
+	"example.com/a [example.com/a.test]": {
+		"printf": [
+			{
+				"posn": "a/a.go:7:2",
+				"message": "Printf format %%s reads arg #1, but call has 0 args"
+			}
+		]
+	}
+}
+`, filename+":6:2", filename+":6:23")
+
+	diags, err := vetDiagnostics(ctx, s.(*snapshot), active[0], []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		rng     protocol.Range
+		message string
+	}{
+		{protocol.Range{Start: protocol.Position{Line: 5, Character: 1}, End: protocol.Position{Line: 5, Character: 22}}, `Printf format %d has arg "x" of wrong type string`},
+		{protocol.Range{Start: protocol.Position{Line: 6, Character: 1}, End: protocol.Position{Line: 6, Character: 1}}, `Printf format %s reads arg #1, but call has 0 args`},
+	}
+	if len(diags) != len(want) {
+		t.Fatalf("vetDiagnostics returned %d diagnostics, want %d: %v", len(diags), len(want), diags)
+	}
+	for i, w := range want {
+		d := diags[i]
+		if d.URI.Filename() != filename || d.Range != w.rng || d.Message != w.message || d.Code != "printf" {
+			t.Errorf("diagnostic %d = %s:%v %s: %q, want %s:%v printf: %q", i, d.URI.Filename(), d.Range, d.Code, d.Message, filename, w.rng, w.message)
+		}
+	}
+}
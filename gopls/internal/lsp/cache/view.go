@@ -64,6 +64,15 @@ type View struct {
 	explicitGowork       span.URI // explicitGowork: if non-empty, a user-specified go.work location (TODO: deprecate)
 	workspaceInformation          // grab-bag of Go environment information (TODO: cleanup)
 
+	// goos and goarch, if non-empty, override the GOOS and GOARCH of the
+	// view's environment. They are set only for views created by
+	// Session.NewCrossView, and are preserved when the view is recreated.
+	goos, goarch string
+
+	// stats counts the hits and misses of the caches of the view's
+	// snapshots, over the lifetime of the view.
+	stats *cacheCounters
+
 	importsState *importsState
 
 	// moduleUpgrades tracks known upgrades for module paths in each modfile.
@@ -114,6 +123,12 @@ type View struct {
 	// initialization of snapshots. Do not change it without adjusting snapshot
 	// accordingly.
 	initializationSema chan struct{}
+
+	// typeCheckSema, if non-nil, bounds the number of packages of the view
+	// that are type-checked in parallel, as configured by the
+	// TypeCheckConcurrency option. Since its size is fixed, a change to the
+	// option results in a new View.
+	typeCheckSema chan struct{}
 }
 
 type workspaceInformation struct {
@@ -315,6 +330,12 @@ func minorOptionsChange(a, b *source.Options) bool {
 	if a.MemoryMode != b.MemoryMode {
 		return false
 	}
+	if a.TypeCheckConcurrency != b.TypeCheckConcurrency {
+		return false
+	}
+	if a.PreferModuleCacheOverVendor != b.PreferModuleCacheOverVendor {
+		return false
+	}
 	aBuildFlags := make([]string, len(a.BuildFlags))
 	bBuildFlags := make([]string, len(b.BuildFlags))
 	copy(aBuildFlags, a.BuildFlags)
@@ -330,11 +351,18 @@ func minorOptionsChange(a, b *source.Options) bool {
 // the session. If so the new view will be returned, otherwise the original one
 // will be returned.
 func (s *Session) SetViewOptions(ctx context.Context, v *View, options *source.Options) (*View, error) {
+	options = v.crossOptions(options)
+
 	// no need to rebuild the view if the options were not materially changed
 	v.optionsMu.Lock()
 	if minorOptionsChange(v.options, options) {
+		changed := changedAnalyzers(v.options, options)
 		v.options = options
 		v.optionsMu.Unlock()
+		if len(changed) > 0 {
+			_, release := v.invalidateAnalyses(ctx, changed)
+			release()
+		}
 		return v, nil
 	}
 	v.optionsMu.Unlock()
@@ -342,6 +370,22 @@ func (s *Session) SetViewOptions(ctx context.Context, v *View, options *source.O
 	return newView, err
 }
 
+// crossOptions returns options adjusted to use the GOOS and GOARCH overrides
+// of v, if any. The given options are not modified.
+func (v *View) crossOptions(options *source.Options) *source.Options {
+	if v.goos == "" && v.goarch == "" {
+		return options
+	}
+	options = options.Clone()
+	if v.goos != "" {
+		options.Env["GOOS"] = v.goos
+	}
+	if v.goarch != "" {
+		options.Env["GOARCH"] = v.goarch
+	}
+	return options
+}
+
 // viewEnv returns a string describing the environment of a newly created view.
 func viewEnv(v *View) string {
 	v.optionsMu.Lock()
@@ -456,6 +500,17 @@ func (v *View) contains(uri span.URI) bool {
 	return !v.filterFunc()(uri)
 }
 
+// resolveSymlinks returns the URI of the file denoted by uri with all
+// symbolic links in its path resolved, or uri itself if they cannot be
+// resolved, for example because the file does not exist.
+func resolveSymlinks(uri span.URI) span.URI {
+	path, err := filepath.EvalSymlinks(uri.Filename())
+	if err != nil {
+		return uri
+	}
+	return span.URIFromPath(path)
+}
+
 // filterFunc returns a func that reports whether uri is filtered by the currently configured
 // directoryFilters.
 func (v *View) filterFunc() func(span.URI) bool {
@@ -469,6 +524,22 @@ func (v *View) filterFunc() func(span.URI) bool {
 	}
 }
 
+// excludingFilter returns the configured directory filter that excludes
+// uri from the view, or "" if uri is not excluded by any directory filter.
+// Unlike filterFunc, it does not consider the implicit exclusion of the
+// module cache, since dependencies are often opened.
+func (v *View) excludingFilter(uri span.URI) string {
+	if !source.InDir(v.folder.Filename(), uri.Filename()) {
+		return ""
+	}
+	rel := strings.TrimPrefix(uri.Filename(), v.folder.Filename())
+	filterer := source.NewFilterer(v.Options().DirectoryFilters)
+	if filter, excluded := filterer.Match(strings.TrimPrefix(filepath.ToSlash(rel), "/")); excluded {
+		return filter
+	}
+	return ""
+}
+
 func (v *View) relevantChange(c source.FileModification) bool {
 	// If the file is known to the view, the change is relevant.
 	if v.knownFile(c.URI) {
@@ -736,11 +807,7 @@ func (s *snapshot) loadWorkspace(ctx context.Context, firstAttempt bool) {
 		}
 	case err != nil:
 		event.Error(ctx, "initial workspace load failed", err)
-		extractedDiags := s.extractGoCommandErrors(ctx, err)
-		criticalErr = &source.CriticalError{
-			MainError:   err,
-			Diagnostics: append(modDiagnostics, extractedDiags...),
-		}
+		criticalErr = s.loadCriticalError(ctx, err, modDiagnostics...)
 	case len(modDiagnostics) == 1:
 		criticalErr = &source.CriticalError{
 			MainError:   fmt.Errorf(modDiagnostics[0].Message),
@@ -795,6 +862,58 @@ func (v *View) invalidateContent(ctx context.Context, changes map[span.URI]*file
 	return v.snapshot, v.snapshot.Acquire()
 }
 
+// invalidateAnalyses replaces the view's snapshot with a copy from which
+// the cached results of the named analyzers have been discarded. Parsed
+// files and type-checked packages are retained.
+//
+// invalidateAnalyses returns the new snapshot, along with a callback which
+// the caller must invoke to release it.
+func (v *View) invalidateAnalyses(ctx context.Context, names []string) (*snapshot, func()) {
+	ctx = xcontext.Detach(ctx)
+
+	v.snapshotMu.Lock()
+	defer v.snapshotMu.Unlock()
+
+	prevSnapshot, prevReleaseSnapshot := v.snapshot, v.releaseSnapshot
+
+	if prevSnapshot == nil {
+		panic("invalidateAnalyses called after shutdown")
+	}
+
+	// Analyses in progress may report the results of disabled analyzers.
+	prevSnapshot.cancel()
+
+	// Do not clone a snapshot until its view has finished initializing.
+	prevSnapshot.AwaitInitialized(ctx)
+
+	v.snapshot, v.releaseSnapshot = prevSnapshot.cloneWithoutAnalyses(ctx, names)
+
+	prevReleaseSnapshot()
+	v.destroy(prevSnapshot, "View.invalidateAnalyses")
+
+	return v.snapshot, v.snapshot.Acquire()
+}
+
+// changedAnalyzers returns the sorted names of the analyzers that are
+// enabled by exactly one of the options a and b.
+func changedAnalyzers(a, b *source.Options) []string {
+	var names []string
+	for _, m := range []map[string]*source.Analyzer{
+		b.DefaultAnalyzers,
+		b.TypeErrorAnalyzers,
+		b.ConvenienceAnalyzers,
+		b.StaticcheckAnalyzers,
+	} {
+		for name, analyzer := range m {
+			if analyzer.IsEnabled(a) != analyzer.IsEnabled(b) {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 func (s *Session) getWorkspaceInformation(ctx context.Context, folder span.URI, options *source.Options) (*workspaceInformation, error) {
 	if err := checkPathCase(folder.Filename()); err != nil {
 		return nil, fmt.Errorf("invalid workspace folder path: %w; check that the casing of the configured workspace folder path agrees with the casing reported by the operating system", err)
@@ -1067,6 +1186,23 @@ func (v *View) GoVersionString() string {
 	return gocommand.ParseGoVersionOutput(v.workspaceInformation.goversionOutput)
 }
 
+func (v *View) GOPATH() []string {
+	var entries []string
+	for _, entry := range filepath.SplitList(v.gopath) {
+		if entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// WatchPatterns returns the glob patterns to watch for the view's current snapshot.
+func (v *View) WatchPatterns(ctx context.Context) map[string]struct{} {
+	snapshot, release := v.getSnapshot()
+	defer release()
+	return snapshot.fileWatchingGlobPatterns(ctx)
+}
+
 // Copied from
 // https://cs.opensource.google/go/go/+/master:src/cmd/go/internal/str/path.go;l=58;drc=2910c5b4a01a573ebc97744890a07c1a3122c67a
 func globsMatchPath(globs, target string) bool {
@@ -1111,6 +1247,15 @@ func globsMatchPath(globs, target string) bool {
 
 var modFlagRegexp = regexp.MustCompile(`-mod[ =](\w+)`)
 
+// explicitModFlag returns the value of the -mod flag in goflags, or "" if
+// there is none.
+func explicitModFlag(goflags string) string {
+	if matches := modFlagRegexp.FindStringSubmatch(goflags); len(matches) != 0 {
+		return matches[1]
+	}
+	return ""
+}
+
 // TODO(rstambler): Consolidate modURI and modContent back into a FileHandle
 // after we have a version of the workspace go.mod file on disk. Getting a
 // FileHandle from the cache for temporary files is problematic, since we
@@ -1122,15 +1267,11 @@ func (s *snapshot) vendorEnabled(ctx context.Context, modURI span.URI, modConten
 	}
 
 	// Explicit -mod flag?
-	matches := modFlagRegexp.FindStringSubmatch(s.view.goEnv["GOFLAGS"])
-	if len(matches) != 0 {
-		modFlag := matches[1]
-		if modFlag != "" {
-			// Don't override an explicit '-mod=vendor' argument.
-			// We do want to override '-mod=readonly': it would break various module code lenses,
-			// and on 1.16 we know -modfile is available, so we won't mess with go.mod anyway.
-			return modFlag == "vendor", nil
-		}
+	if modFlag := explicitModFlag(s.view.goEnv["GOFLAGS"]); modFlag != "" {
+		// Don't override an explicit '-mod=vendor' argument.
+		// We do want to override '-mod=readonly': it would break various module code lenses,
+		// and on 1.16 we know -modfile is available, so we won't mess with go.mod anyway.
+		return modFlag == "vendor", nil
 	}
 
 	modFile, err := modfile.Parse(modURI.Filename(), modContent, nil)
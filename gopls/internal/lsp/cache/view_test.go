@@ -286,3 +286,61 @@ func toJSON(x interface{}) string {
 	b, _ := json.MarshalIndent(x, "", " ")
 	return string(b)
 }
+
+func TestResolveSymlinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gopls-symlinks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dir, err = filepath.EvalSymlinks(dir) // the temp dir may itself be a link
+	if err != nil {
+		t.Fatal(err)
+	}
+	real := filepath.Join(dir, "real")
+	if err := os.Mkdir(real, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(real, "a.go"), []byte("package a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(real, filepath.Join(dir, "link")); err != nil {
+		t.Skipf("cannot create symlink: %v", err)
+	}
+
+	want := span.URIFromPath(filepath.Join(real, "a.go"))
+	if got := resolveSymlinks(span.URIFromPath(filepath.Join(dir, "link", "a.go"))); got != want {
+		t.Errorf("resolveSymlinks(link/a.go) = %s, want %s", got, want)
+	}
+	missing := span.URIFromPath(filepath.Join(dir, "link", "missing.go"))
+	if got := resolveSymlinks(missing); got != missing {
+		t.Errorf("resolveSymlinks(link/missing.go) = %s, want it unchanged", got)
+	}
+}
+
+func TestModuleForURI(t *testing.T) {
+	root := t.TempDir()
+	modURI := func(dir string) span.URI {
+		return span.URIFromPath(filepath.Join(root, dir, "go.mod"))
+	}
+	modFiles := map[span.URI]struct{}{
+		modURI("a"):   {},
+		modURI("a/b"): {},
+	}
+	for _, tt := range []struct {
+		dir  string
+		want span.URI
+	}{
+		{"a", modURI("a")},
+		{"a/new", modURI("a")},
+		{"a/b", modURI("a/b")},
+		{"a/b/c/d", modURI("a/b")},
+		{"ab", ""},
+		{"", ""},
+	} {
+		dir := span.URIFromPath(filepath.Join(root, tt.dir))
+		if got := moduleForURI(modFiles, dir); got != tt.want {
+			t.Errorf("moduleForURI(%q) = %q, want %q", tt.dir, got, tt.want)
+		}
+	}
+}
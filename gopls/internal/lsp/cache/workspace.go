@@ -15,6 +15,7 @@ import (
 	"sync"
 
 	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
 	"golang.org/x/tools/gopls/internal/lsp/source"
 	"golang.org/x/tools/gopls/internal/span"
 	"golang.org/x/tools/internal/event"
@@ -57,6 +58,9 @@ type workspaceCommon struct {
 	// explicitGowork is, if non-empty, the URI for the explicit go.work file
 	// provided via the user's environment.
 	explicitGowork span.URI
+
+	// goversion is the minor version of the go command, or 0 if unknown.
+	goversion int
 }
 
 // workspace tracks go.mod files in the workspace, along with the
@@ -86,6 +90,11 @@ type workspace struct {
 	// workFile, if nonEmpty, is the go.work file for the workspace.
 	workFile span.URI
 
+	// goMods summarizes the active go.mod files as they were when the
+	// workspace was last (re)initialized, so that changes to their go
+	// directive alone may be handled without reloading the workspace.
+	goMods map[span.URI]goModSummary
+
 	// The workspace module is lazily re-built once after being invalidated.
 	// buildMu+built guards this reconstruction.
 	//
@@ -127,6 +136,7 @@ func newWorkspace(ctx context.Context, root, explicitGowork span.URI, fs source.
 	// TODO(rfindley): if GO111MODULE=off, this looks wrong, though there are
 	// probably other problems.
 	if err := ws.loadExplicitWorkspaceFile(ctx, fs); err == nil {
+		ws.goMods = summarizeGoMods(ctx, ws.activeModFiles, fs)
 		return ws, nil
 	}
 
@@ -152,6 +162,7 @@ func newWorkspace(ctx context.Context, root, explicitGowork span.URI, fs source.
 		}
 		ws.activeModFiles = activeModFiles
 	}
+	ws.goMods = summarizeGoMods(ctx, ws.activeModFiles, fs)
 	return ws, nil
 }
 
@@ -172,7 +183,21 @@ func (ws *workspace) loadExplicitWorkspaceFile(ctx context.Context, fs source.Fi
 		var activeModFiles map[span.URI]struct{}
 		switch src {
 		case goWorkWorkspace:
-			file, activeModFiles, err = parseGoWork(ctx, ws.root, fh.URI(), contents, fs)
+			var goVersion string
+			activeModFiles, goVersion, err = parseGoWork(ws.root, fh.URI(), contents)
+			if err == nil {
+				// Keep the active modules even if the modfile can't be built;
+				// the error is reported by criticalError. The workspace is
+				// not marked built, so that build still computes its
+				// directories and sum file from the active modules.
+				var buildErr error
+				file, buildErr = buildGoWorkModFile(ctx, goVersion, activeModFiles, fs)
+				if buildErr != nil {
+					ws.buildMu.Lock()
+					ws.buildErr = buildErr
+					ws.buildMu.Unlock()
+				}
+			}
 			ws.workFile = fh.URI()
 		case goplsModWorkspace:
 			file, activeModFiles, err = parseGoplsMod(ws.root, fh.URI(), contents)
@@ -204,12 +229,21 @@ func (w *workspace) ActiveModFiles() map[span.URI]struct{} {
 }
 
 // criticalError returns a critical error related to the workspace setup.
-func (w *workspace) criticalError(ctx context.Context, fs source.FileSource) (res *source.CriticalError) {
+//
+// goflags is the value of GOFLAGS in the view's environment.
+func (w *workspace) criticalError(ctx context.Context, fs source.FileSource, goflags string) (res *source.CriticalError) {
 	// For now, we narrowly report errors related to `go.work` files.
 	//
 	// TODO(rfindley): investigate whether other workspace validation errors
 	// can be consolidated here.
 	if w.moduleSource == goWorkWorkspace {
+		// The go command rejects -mod=vendor in workspace mode, with an error
+		// that does not mention GOFLAGS.
+		if explicitModFlag(goflags) == "vendor" {
+			return &source.CriticalError{
+				MainError: fmt.Errorf("go.work is incompatible with -mod=vendor (set by GOFLAGS=%q): remove the flag, or set GOWORK=off", goflags),
+			}
+		}
 		// We should have already built the modfile, but build here to be
 		// consistent about accessing w.mod after w.build.
 		//
@@ -292,6 +326,19 @@ func (w *workspace) build(ctx context.Context, fs source.FileSource) {
 		}
 	}
 
+	// If the modfile of a go.work file could not be built, the previous one
+	// is kept, but the modules now in use belong to the workspace.
+	if w.moduleSource == goWorkWorkspace && w.buildErr != nil {
+		dirs := make(map[span.URI]struct{}, len(w.wsDirs)) // wsDirs may be shared
+		for dir := range w.wsDirs {
+			dirs[dir] = struct{}{}
+		}
+		for modURI := range w.activeModFiles {
+			dirs[span.URIFromPath(filepath.Dir(modURI.Filename()))] = struct{}{}
+		}
+		w.wsDirs = dirs
+	}
+
 	sum, err := buildWorkspaceSumFile(ctx, w.activeModFiles, fs)
 	if err == nil {
 		w.sum = sum
@@ -336,6 +383,7 @@ func (w *workspace) Clone(ctx context.Context, changes map[span.URI]*fileChange,
 		knownModFiles:   make(map[span.URI]struct{}),
 		activeModFiles:  make(map[span.URI]struct{}),
 		workFile:        w.workFile,
+		goMods:          make(map[span.URI]goModSummary),
 		mod:             w.mod,
 		sum:             w.sum,
 		wsDirs:          w.wsDirs,
@@ -346,6 +394,9 @@ func (w *workspace) Clone(ctx context.Context, changes map[span.URI]*fileChange,
 	for k, v := range w.activeModFiles {
 		result.activeModFiles[k] = v
 	}
+	for k, v := range w.goMods {
+		result.goMods[k] = v
+	}
 
 	equalURI := func(a, b span.URI) (r bool) {
 		// This query is a strange mix of syntax and file system state:
@@ -368,7 +419,24 @@ func (w *workspace) Clone(ctx context.Context, changes map[span.URI]*fileChange,
 		}
 		changed = true
 		active := result.moduleSource != legacyWorkspace || equalURI(modURI(w.root), uri)
-		needReinit = needReinit || (active && change.fileHandle.Saved())
+		if active && change.fileHandle.Saved() {
+			prev, hadPrev := w.goMods[uri]
+			cur, err := summarizeGoMod(uri, change.content)
+			if !change.exists || err != nil {
+				delete(result.goMods, uri)
+			} else {
+				result.goMods[uri] = cur
+			}
+			// A change to the go directive alone does not affect the package
+			// graph: the snapshot updates the go version of the module's
+			// packages and re-checks them instead. In a go.work workspace,
+			// however, the go command rejects a module whose go version
+			// exceeds that of go.work, and from Go 1.21 on it may switch
+			// toolchains, so such errors are surfaced by reloading.
+			goVersionOnly := change.exists && err == nil && hadPrev && prev.sameModuleGraph(cur) &&
+				result.moduleSource != goWorkWorkspace && !w.mayRequireToolchain(cur.goVersion)
+			needReinit = needReinit || !goVersionOnly
+		}
 		// Don't mess with the list of mod files if using go.work or gopls.mod.
 		if result.moduleSource == goplsModWorkspace || result.moduleSource == goWorkWorkspace {
 			continue
@@ -426,10 +494,14 @@ func handleWorkspaceFileChanges(ctx context.Context, ws *workspace, changes map[
 		// Otherwise, stick with the current file.
 		var parsedFile *modfile.File
 		var parsedModules map[span.URI]struct{}
-		var err error
+		var err, buildErr error
 		switch ws.moduleSource {
 		case goWorkWorkspace:
-			parsedFile, parsedModules, err = parseGoWork(ctx, ws.root, uri, change.content, fs)
+			var goVersion string
+			parsedModules, goVersion, err = parseGoWork(ws.root, uri, change.content)
+			if err == nil {
+				parsedFile, buildErr = buildGoWorkModFile(ctx, goVersion, parsedModules, fs)
+			}
 		case goplsModWorkspace:
 			parsedFile, parsedModules, err = parseGoplsMod(ws.root, uri, change.content)
 		}
@@ -447,7 +519,17 @@ func handleWorkspaceFileChanges(ctx context.Context, ws *workspace, changes map[
 			// only update the modfile if it parsed.
 			changed = true
 			reload = change.fileHandle.Saved()
-			ws.mod = parsedFile
+			if buildErr != nil {
+				// The used modules are still active, but keep the previous
+				// modfile, and report the error via criticalError. The
+				// workspace is not marked built, so that its sum file and
+				// directories are recomputed for the new modules.
+				ws.buildMu.Lock()
+				ws.buildErr = buildErr
+				ws.buildMu.Unlock()
+			} else {
+				ws.mod = parsedFile
+			}
 			ws.knownModFiles = parsedModules
 			ws.activeModFiles = make(map[span.URI]struct{})
 			for k, v := range parsedModules {
@@ -477,6 +559,88 @@ func uriForSource(root, explicitGowork span.URI, src workspaceSource) span.URI {
 	return span.URIFromPath(filepath.Join(root.Filename(), basename))
 }
 
+// goModSummary records the go directive of a go.mod file separately from
+// the rest of its content.
+type goModSummary struct {
+	goVersion string
+	rest      string // the formatted file, without its go directive
+}
+
+// summarizeGoMod parses and summarizes the go.mod file content.
+func summarizeGoMod(uri span.URI, content []byte) (goModSummary, error) {
+	f, err := modfile.Parse(uri.Filename(), content, nil)
+	if err != nil {
+		return goModSummary{}, err
+	}
+	var sum goModSummary
+	if f.Go != nil {
+		sum.goVersion = f.Go.Version
+	}
+	stmts := f.Syntax.Stmt[:0]
+	for _, stmt := range f.Syntax.Stmt {
+		if line, ok := stmt.(*modfile.Line); ok && len(line.Token) > 0 && line.Token[0] == "go" {
+			continue
+		}
+		stmts = append(stmts, stmt)
+	}
+	f.Syntax.Stmt = stmts
+	sum.rest = string(modfile.Format(f.Syntax))
+	return sum, nil
+}
+
+// summarizeGoMods summarizes the given go.mod files, skipping those that
+// cannot be read or parsed.
+func summarizeGoMods(ctx context.Context, modFiles map[span.URI]struct{}, fs source.FileSource) map[span.URI]goModSummary {
+	sums := make(map[span.URI]goModSummary)
+	for uri := range modFiles {
+		fh, err := fs.GetFile(ctx, uri)
+		if err != nil {
+			continue
+		}
+		content, err := fh.Read()
+		if err != nil {
+			continue
+		}
+		if sum, err := summarizeGoMod(uri, content); err == nil {
+			sums[uri] = sum
+		}
+	}
+	return sums
+}
+
+// sameModuleGraph reports whether the go.mod files summarized by s and t
+// differ at most in their go directive, in a way that cannot change how the
+// go command loads the module graph.
+//
+// Go versions before 1.17 do not prune the module graph, and before 1.14
+// do not enable vendoring by default, so changes involving such versions
+// are conservatively treated as affecting the module graph.
+func (s goModSummary) sameModuleGraph(t goModSummary) bool {
+	if s.rest != t.rest {
+		return false
+	}
+	if s.goVersion == t.goVersion {
+		return true
+	}
+	atLeast117 := func(v string) bool {
+		return v != "" && semver.Compare("v"+v, "v1.17") >= 0
+	}
+	return atLeast117(s.goVersion) && atLeast117(t.goVersion)
+}
+
+// mayRequireToolchain reports whether the go version of a go directive may
+// cause the go command to fail or to switch toolchains: that is, whether it
+// is at least 1.21, and not known to be older than the go command. Since
+// only the minor version of the go command is known, a go directive with
+// the same minor version is assumed to require a newer release.
+func (w *workspace) mayRequireToolchain(goVersion string) bool {
+	minor, ok := goMinorVersion(goVersion)
+	if !ok {
+		return true
+	}
+	return minor >= 21 && (w.goversion == 0 || minor >= w.goversion)
+}
+
 // modURI returns the URI for the go.mod file contained in root.
 func modURI(root span.URI) span.URI {
 	return span.URIFromPath(filepath.Join(root.Filename(), "go.mod"))
@@ -532,37 +696,40 @@ func getLegacyModules(ctx context.Context, root span.URI, fs source.FileSource)
 	return modules, nil
 }
 
-func parseGoWork(ctx context.Context, root, uri span.URI, contents []byte, fs source.FileSource) (*modfile.File, map[span.URI]struct{}, error) {
+// parseGoWork parses the go.work file with the given contents, returning
+// the go.mod files of the modules it uses and its go version.
+//
+// The workspace modfile is built separately, by buildGoWorkModFile, so that
+// a failure to build it (for example, because one of the used modules has
+// a broken go.mod file) does not discard the active modules.
+func parseGoWork(root, uri span.URI, contents []byte) (map[span.URI]struct{}, string, error) {
 	workFile, err := modfile.ParseWork(uri.Filename(), contents, nil)
 	if err != nil {
-		return nil, nil, fmt.Errorf("parsing go.work: %w", err)
+		return nil, "", fmt.Errorf("parsing go.work: %w", err)
+	}
+	// Require a go directive, per the spec.
+	if workFile.Go == nil || workFile.Go.Version == "" {
+		return nil, "", fmt.Errorf("go.work has missing or incomplete go directive")
 	}
 	modFiles := make(map[span.URI]struct{})
 	for _, dir := range workFile.Use {
-		// The resulting modfile must use absolute paths, so that it can be
-		// written to a temp directory.
-		dir.Path = absolutePath(root, dir.Path)
-		modURI := span.URIFromPath(filepath.Join(dir.Path, "go.mod"))
+		modURI := span.URIFromPath(filepath.Join(absolutePath(root, dir.Path), "go.mod"))
 		modFiles[modURI] = struct{}{}
 	}
+	return modFiles, workFile.Go.Version, nil
+}
 
-	// TODO(rfindley): we should either not build the workspace modfile here, or
-	// not fail so hard. A failure in building the workspace modfile should not
-	// invalidate the active module paths extracted above.
+// buildGoWorkModFile builds the workspace modfile for the given go.mod
+// files used by a go.work file with the given go version.
+func buildGoWorkModFile(ctx context.Context, goVersion string, modFiles map[span.URI]struct{}, fs source.FileSource) (*modfile.File, error) {
 	modFile, err := buildWorkspaceModFile(ctx, modFiles, fs)
 	if err != nil {
-		return nil, nil, err
-	}
-
-	// Require a go directive, per the spec.
-	if workFile.Go == nil || workFile.Go.Version == "" {
-		return nil, nil, fmt.Errorf("go.work has missing or incomplete go directive")
+		return nil, err
 	}
-	if err := modFile.AddGoStmt(workFile.Go.Version); err != nil {
-		return nil, nil, err
+	if err := modFile.AddGoStmt(goVersion); err != nil {
+		return nil, err
 	}
-
-	return modFile, modFiles, nil
+	return modFile, nil
 }
 
 func parseGoplsMod(root, uri span.URI, contents []byte) (*modfile.File, map[span.URI]struct{}, error) {
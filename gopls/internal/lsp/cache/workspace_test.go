@@ -8,6 +8,8 @@ import (
 	"context"
 	"errors"
 	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -175,6 +177,137 @@ replace modb.com => $SANDBOX_WORKDIR/b`, true},
 				dirs:    []string{".", "a", "b"},
 			},
 		},
+		{
+			desc: "go directive change",
+			initial: `
+-- a/go.mod --
+module moda.com
+
+go 1.18`,
+			initialState: wsState{
+				modules: []string{"a/go.mod"},
+				source:  fileSystemWorkspace,
+				dirs:    []string{".", "a"},
+			},
+			updates: map[string]wsChange{
+				"a/go.mod": {`module moda.com
+
+go 1.19`, true},
+			},
+			wantChanged: true,
+			wantReload:  false,
+			finalState: wsState{
+				modules: []string{"a/go.mod"},
+				source:  fileSystemWorkspace,
+				dirs:    []string{".", "a"},
+			},
+		},
+		{
+			desc: "go directive and require change",
+			initial: `
+-- a/go.mod --
+module moda.com
+
+go 1.18`,
+			initialState: wsState{
+				modules: []string{"a/go.mod"},
+				source:  fileSystemWorkspace,
+				dirs:    []string{".", "a"},
+			},
+			updates: map[string]wsChange{
+				"a/go.mod": {`module moda.com
+
+go 1.19
+
+require golang.org/x/mod v0.3.0`, true},
+			},
+			wantChanged: true,
+			wantReload:  true,
+			finalState: wsState{
+				modules: []string{"a/go.mod"},
+				source:  fileSystemWorkspace,
+				dirs:    []string{".", "a"},
+			},
+		},
+		{
+			desc: "go directive change from go1.16",
+			initial: `
+-- a/go.mod --
+module moda.com
+
+go 1.16`,
+			initialState: wsState{
+				modules: []string{"a/go.mod"},
+				source:  fileSystemWorkspace,
+				dirs:    []string{".", "a"},
+			},
+			updates: map[string]wsChange{
+				"a/go.mod": {`module moda.com
+
+go 1.18`, true},
+			},
+			wantChanged: true,
+			wantReload:  true, // module graph pruning changes
+			finalState: wsState{
+				modules: []string{"a/go.mod"},
+				source:  fileSystemWorkspace,
+				dirs:    []string{".", "a"},
+			},
+		},
+		{
+			desc: "go directive change to go1.21",
+			initial: `
+-- a/go.mod --
+module moda.com
+
+go 1.20`,
+			initialState: wsState{
+				modules: []string{"a/go.mod"},
+				source:  fileSystemWorkspace,
+				dirs:    []string{".", "a"},
+			},
+			updates: map[string]wsChange{
+				"a/go.mod": {`module moda.com
+
+go 1.21`, true},
+			},
+			wantChanged: true,
+			wantReload:  true, // may require a newer toolchain
+			finalState: wsState{
+				modules: []string{"a/go.mod"},
+				source:  fileSystemWorkspace,
+				dirs:    []string{".", "a"},
+			},
+		},
+		{
+			desc: "go directive change in go.work workspace",
+			initial: `
+-- go.work --
+go 1.18
+
+use ./a
+-- a/go.mod --
+module moda.com
+
+go 1.18`,
+			initialState: wsState{
+				modules: []string{"a/go.mod"},
+				source:  goWorkWorkspace,
+				dirs:    []string{".", "a"},
+			},
+			updates: map[string]wsChange{
+				"a/go.mod": {`module moda.com
+
+go 1.19`, true},
+			},
+			wantChanged: true,
+			wantReload:  true, // go.work may now be older than the module
+			finalState: wsState{
+				modules: []string{"a/go.mod"},
+				source:  goWorkWorkspace,
+				dirs:    []string{".", "a"},
+			},
+		},
 		{
 			desc: "broken module parsing",
 			initial: `
@@ -312,11 +445,141 @@ use ./missing
 	if err != nil {
 		t.Fatalf("error creating workspace: %v; want no error", err)
 	}
+	// The error is recorded, but the workspace is left to be built lazily,
+	// so that its directories are computed from the active modules.
 	w.buildMu.Lock()
 	built, buildErr := w.built, w.buildErr
 	w.buildMu.Unlock()
-	if !built || buildErr == nil {
-		t.Fatalf("built, buildErr: got %v, %v; want true, non-nil", built, buildErr)
+	if built || buildErr == nil {
+		t.Fatalf("built, buildErr: got %v, %v; want false, non-nil", built, buildErr)
+	}
+	if _, err := w.modFile(context.Background(), &osFileSource{}); err == nil {
+		t.Errorf("modFile: got nil error, want the error building the workspace modfile")
+	}
+}
+
+func TestWorkspaceBrokenMemberKeepsModules(t *testing.T) {
+	w, cleanup, err := workspaceFromTxtar(t, `
+-- go.work --
+go 1.18
+
+use (
+	./a
+	./b
+)
+-- a/go.mod --
+module a
+-- a/go.sum --
+example.com/x v1.0.0 h1:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=
+-- b/go.mod --
+modul b
+-- b/go.sum --
+example.com/y v1.0.0 h1:BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBB=
+`)
+	defer cleanup()
+	if err != nil {
+		t.Fatalf("error creating workspace: %v; want no error", err)
+	}
+	ctx := context.Background()
+	fs := &osFileSource{}
+	if got := len(w.ActiveModFiles()); got != 2 {
+		t.Errorf("got %d active modules, want 2", got)
+	}
+	if critErr := w.criticalError(ctx, fs, ""); critErr == nil {
+		t.Errorf("criticalError: got nil, want the error building the workspace modfile")
+	}
+	want := []span.URI{
+		w.root,
+		span.URIFromPath(filepath.Join(w.root.Filename(), "a")),
+		span.URIFromPath(filepath.Join(w.root.Filename(), "b")),
+	}
+	if got := w.dirs(ctx, fs); !reflect.DeepEqual(got, want) {
+		t.Errorf("dirs() = %v, want %v", got, want)
+	}
+	sum, _ := w.sumFile(ctx, fs)
+	for _, mod := range []string{"example.com/x", "example.com/y"} {
+		if !strings.Contains(string(sum), mod) {
+			t.Errorf("sumFile() = %q, want it to include the sums of %s", sum, mod)
+		}
+	}
+}
+
+func TestWorkspaceBrokenMemberOnChange(t *testing.T) {
+	ctx := context.Background()
+	w, cleanup, err := workspaceFromTxtar(t, `
+-- go.work --
+go 1.18
+
+use ./a
+-- a/go.mod --
+module a
+-- a/go.sum --
+example.com/x v1.0.0 h1:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=
+-- b/go.mod --
+modul b
+-- b/go.sum --
+example.com/y v1.0.0 h1:BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBB=
+`)
+	defer cleanup()
+	if err != nil {
+		t.Fatalf("error creating workspace: %v; want no error", err)
+	}
+	fs := &osFileSource{}
+	if critErr := w.criticalError(ctx, fs, ""); critErr != nil {
+		t.Fatalf("initial criticalError: got %v, want nil", critErr)
+	}
+
+	// Use the broken module b too.
+	workURI := span.URIFromPath(filepath.Join(w.root.Filename(), "go.work"))
+	change, err := fs.change(ctx, workURI, "go 1.18\n\nuse (\n\t./a\n\t./b\n)\n", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := w.Clone(ctx, map[span.URI]*fileChange{workURI: change}, fs)
+	if got == w {
+		t.Fatal("Clone: workspace unchanged, want a new workspace")
+	}
+	if n := len(got.ActiveModFiles()); n != 2 {
+		t.Errorf("got %d active modules, want 2", n)
+	}
+	if critErr := got.criticalError(ctx, fs, ""); critErr == nil {
+		t.Errorf("criticalError: got nil, want the error building the workspace modfile")
+	}
+	bDir := span.URIFromPath(filepath.Join(w.root.Filename(), "b"))
+	var found bool
+	for _, dir := range got.dirs(ctx, fs) {
+		found = found || dir == bDir
+	}
+	if !found {
+		t.Errorf("dirs() = %v, want it to include %s", got.dirs(ctx, fs), bDir)
+	}
+	sum, _ := got.sumFile(ctx, fs)
+	if !strings.Contains(string(sum), "example.com/y") {
+		t.Errorf("sumFile() = %q, want it to include the sums of b", sum)
+	}
+}
+
+func TestWorkspaceVendorFlagError(t *testing.T) {
+	w, cleanup, err := workspaceFromTxtar(t, `
+-- go.work --
+go 1.18
+
+use ./a
+-- a/go.mod --
+module a
+`)
+	defer cleanup()
+	if err != nil {
+		t.Fatalf("error creating workspace: %v; want no error", err)
+	}
+	ctx := context.Background()
+	fs := &osFileSource{}
+	if critErr := w.criticalError(ctx, fs, "-mod=readonly"); critErr != nil {
+		t.Errorf("criticalError with -mod=readonly: got %v, want nil", critErr.MainError)
+	}
+	critErr := w.criticalError(ctx, fs, "-tags=x -mod=vendor")
+	if critErr == nil || !strings.Contains(critErr.MainError.Error(), "go.work is incompatible with -mod=vendor") {
+		t.Errorf("criticalError with -mod=vendor: got %v, want incompatibility error", critErr)
 	}
 }
 
@@ -364,3 +627,24 @@ func checkState(ctx context.Context, t *testing.T, fs source.FileSource, rel fak
 		t.Errorf("got final sum %q, want %q", gotSum, want.sum)
 	}
 }
+
+func TestMayRequireToolchain(t *testing.T) {
+	for _, test := range []struct {
+		goversion int // of the go command
+		goVersion string
+		want      bool
+	}{
+		{0, "1.19", false},
+		{0, "1.21", true},
+		{22, "1.20", false},
+		{22, "1.21.5", false},
+		{21, "1.21.5", true}, // go1.21.0 switches to go1.21.5
+		{21, "1.22", true},
+		{22, "", true},
+	} {
+		w := &workspace{workspaceCommon: workspaceCommon{goversion: test.goversion}}
+		if got := w.mayRequireToolchain(test.goVersion); got != test.want {
+			t.Errorf("mayRequireToolchain(%q) with go1.%d = %t, want %t", test.goVersion, test.goversion, got, test.want)
+		}
+	}
+}
@@ -8,8 +8,11 @@ package mod
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 
 	"golang.org/x/mod/modfile"
@@ -20,6 +23,7 @@ import (
 	"golang.org/x/tools/gopls/internal/lsp/source"
 	"golang.org/x/tools/gopls/internal/span"
 	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/gocommand"
 	"golang.org/x/vuln/osv"
 )
 
@@ -83,7 +87,13 @@ func ModDiagnostics(ctx context.Context, snapshot source.Snapshot, fh source.Fil
 		if pm == nil || len(pm.ParseErrors) == 0 {
 			return nil, err
 		}
-		return pm.ParseErrors, nil
+		// The go.mod file may fail to parse only because it contains tool
+		// directives, which are still worth checking.
+		toolDiags, err := toolDiagnostics(ctx, snapshot, pm)
+		if err != nil {
+			return nil, err
+		}
+		return append(pm.ParseErrors, toolDiags...), nil
 	}
 
 	// Packages in the workspace can contribute diagnostics to go.mod files.
@@ -111,6 +121,12 @@ func ModDiagnostics(ctx context.Context, snapshot source.Snapshot, fh source.Fil
 		}
 	}
 
+	toolDiags, err := toolDiagnostics(ctx, snapshot, pm)
+	if err != nil {
+		return nil, err
+	}
+	diagnostics = append(diagnostics, toolDiags...)
+
 	tidied, err := snapshot.ModTidy(ctx, pm)
 	if err != nil && !source.IsNonFatalGoModError(err) {
 		event.Error(ctx, fmt.Sprintf("tidy: diagnosing %s", pm.URI), err)
@@ -126,6 +142,163 @@ func ModDiagnostics(ctx context.Context, snapshot source.Snapshot, fh source.Fil
 	return diagnostics, nil
 }
 
+// toolDiagnostics reports the tool directives of the go.mod file whose
+// package paths do not resolve to a package in the build: either because
+// they do not belong to the main module or to any module it requires, or
+// because no such package exists in the providing module.
+//
+// Tool directives are not recognized by the modfile package, so the strict
+// parse of ParseMod rejects go.mod files that contain them. Instead, the
+// contents are parsed again leniently, and the directives are read from the
+// syntax tree.
+func toolDiagnostics(ctx context.Context, snapshot source.Snapshot, pm *source.ParsedModule) ([]*source.Diagnostic, error) {
+	file, err := modfile.ParseLax(pm.URI.Filename(), pm.Mapper.Content, nil)
+	if err != nil {
+		return nil, nil // the parse errors of ParseMod are reported instead
+	}
+	var modPaths []string
+	if file.Module != nil {
+		modPaths = append(modPaths, file.Module.Mod.Path)
+	}
+	for _, req := range file.Require {
+		modPaths = append(modPaths, req.Mod.Path)
+	}
+	provided := func(path string) bool {
+		for _, modPath := range modPaths {
+			if path == modPath || strings.HasPrefix(path, modPath+"/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	var diagnostics []*source.Diagnostic
+	report := func(line *modfile.Line, format string, args ...interface{}) error {
+		rng, err := pm.Mapper.OffsetRange(line.Start.Byte, line.End.Byte)
+		if err != nil {
+			return err
+		}
+		diagnostics = append(diagnostics, &source.Diagnostic{
+			URI:      pm.URI,
+			Range:    rng,
+			Severity: protocol.SeverityError,
+			Source:   source.ModTidyError,
+			Message:  fmt.Sprintf(format, args...),
+		})
+		return nil
+	}
+
+	// Report the tools of unknown modules; check the others below.
+	unchecked := make(map[string]*modfile.Line)
+	for _, tool := range toolDirectives(file) {
+		if !provided(tool.path) {
+			if err := report(tool.line, "tool %s is not provided by the main module or any required module", tool.path); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		unchecked[tool.path] = tool.line
+	}
+	if len(unchecked) == 0 {
+		return diagnostics, nil
+	}
+
+	// Tools that are loaded packages resolve.
+	if metas, err := snapshot.AllMetadata(ctx); err == nil {
+		for _, m := range metas {
+			delete(unchecked, string(m.PkgPath))
+		}
+	}
+	if len(unchecked) == 0 {
+		return diagnostics, nil
+	}
+
+	// Ask the go command about the others, which the workspace may not import.
+	paths := make([]string, 0, len(unchecked))
+	for path := range unchecked {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	pkgErrors, err := listPackageErrors(ctx, snapshot, pm.URI, paths)
+	if err != nil {
+		// The go command may be too old to understand tool directives.
+		event.Error(ctx, fmt.Sprintf("listing tools of %s", pm.URI), err)
+		return diagnostics, nil
+	}
+	for _, path := range paths {
+		if msg, ok := pkgErrors[path]; ok {
+			if err := report(unchecked[path], "tool %s does not resolve to a package: %s", path, msg); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return diagnostics, nil
+}
+
+// A toolDirective is a tool directive of a go.mod file.
+type toolDirective struct {
+	path string // package path
+	line *modfile.Line
+}
+
+// toolDirectives returns the tool directives of the leniently parsed
+// go.mod file, in order.
+func toolDirectives(file *modfile.File) []toolDirective {
+	var tools []toolDirective
+	add := func(line *modfile.Line, path string) {
+		if unquoted, err := strconv.Unquote(path); err == nil {
+			path = unquoted
+		}
+		tools = append(tools, toolDirective{path, line})
+	}
+	for _, stmt := range file.Syntax.Stmt {
+		switch stmt := stmt.(type) {
+		case *modfile.Line:
+			if len(stmt.Token) == 2 && stmt.Token[0] == "tool" {
+				add(stmt, stmt.Token[1])
+			}
+		case *modfile.LineBlock:
+			if len(stmt.Token) == 1 && stmt.Token[0] == "tool" {
+				for _, line := range stmt.Line {
+					if len(line.Token) == 1 {
+						add(line, line.Token[0])
+					}
+				}
+			}
+		}
+	}
+	return tools
+}
+
+// listPackageErrors runs "go list -e -find" on the given package paths in
+// the directory of the go.mod file, and returns the error message of each
+// package that could not be found.
+func listPackageErrors(ctx context.Context, snapshot source.Snapshot, modURI span.URI, paths []string) (map[string]string, error) {
+	inv := &gocommand.Invocation{
+		Verb:       "list",
+		Args:       append([]string{"-e", "-find", "-json"}, paths...),
+		WorkingDir: filepath.Dir(modURI.Filename()),
+	}
+	stdout, err := snapshot.RunGoCommandDirect(ctx, source.Normal, inv)
+	if err != nil {
+		return nil, err
+	}
+	pkgErrors := make(map[string]string)
+	for dec := json.NewDecoder(stdout); dec.More(); {
+		var pkg struct {
+			ImportPath string
+			Error      *struct{ Err string }
+		}
+		if err := dec.Decode(&pkg); err != nil {
+			return nil, err
+		}
+		if pkg.Error != nil {
+			pkgErrors[pkg.ImportPath] = pkg.Error.Err
+		}
+	}
+	return pkgErrors, nil
+}
+
 // ModUpgradeDiagnostics adds upgrade quick fixes for individual modules if the upgrades
 // are recorded in the view.
 func ModUpgradeDiagnostics(ctx context.Context, snapshot source.Snapshot, fh source.FileHandle) (upgradeDiagnostics []*source.Diagnostic, err error) {
@@ -0,0 +1,127 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/gopls/internal/lsp/cache"
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/lsp/source"
+	"golang.org/x/tools/gopls/internal/lsp/tests"
+	"golang.org/x/tools/gopls/internal/span"
+)
+
+func TestToolDiagnostics(t *testing.T) {
+	const src = `module example.com/a
+
+go 1.24
+
+tool example.com/a/cmd/gen
+
+tool (
+	example.com/a/cmd/missing
+	example.com/missing/cmd/tool
+)
+`
+	folder := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod":          src,
+		"cmd/gen/main.go": "package main\n\nfunc main() {}\n",
+	} {
+		filename := filepath.Join(folder, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := tests.Context(t)
+	session := cache.NewSession(ctx, cache.New(nil, nil), nil)
+	options := source.DefaultOptions().Clone()
+	tests.DefaultOptions(options)
+	options.Env = map[string]string{"GOPACKAGESDRIVER": "off", "GOROOT": ""}
+	_, snapshot, release, err := session.NewView(ctx, "tool_test", span.URIFromPath(folder), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	uri := span.URIFromPath(filepath.Join(folder, "go.mod"))
+	pm := &source.ParsedModule{
+		URI:    uri,
+		Mapper: protocol.NewMapper(uri, []byte(src)),
+	}
+	diags, err := toolDiagnostics(ctx, snapshot, pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []uint32
+	for _, d := range diags {
+		got = append(got, d.Range.Start.Line)
+	}
+	// The tool in the main module exists; the tool of an unknown module,
+	// and the missing tool of the main module, do not.
+	if want := []uint32{8, 7}; !reflect.DeepEqual(got, want) {
+		t.Errorf("toolDiagnostics reported lines %v, want %v: %v", got, want, diags)
+	}
+}
+
+// TestToolDiagnosticsParseMod checks that tool directives are diagnosed
+// even though ParseMod rejects the go.mod files that contain them.
+func TestToolDiagnosticsParseMod(t *testing.T) {
+	const src = `module example.com/a
+
+go 1.24
+
+tool example.com/missing/cmd/tool
+`
+	folder := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(folder, "go.mod"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := tests.Context(t)
+	session := cache.NewSession(ctx, cache.New(nil, nil), nil)
+	options := source.DefaultOptions().Clone()
+	tests.DefaultOptions(options)
+	options.Env = map[string]string{"GOPACKAGESDRIVER": "off", "GOROOT": ""}
+	_, snapshot, release, err := session.NewView(ctx, "tool_test", span.URIFromPath(folder), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	fh, err := snapshot.GetFile(ctx, span.URIFromPath(filepath.Join(folder, "go.mod")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := snapshot.ParseMod(ctx, fh); err == nil {
+		t.Fatal("ParseMod succeeded, want an error for the unknown tool directive")
+	}
+	diags, err := ModDiagnostics(ctx, snapshot, fh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, d := range diags {
+		if strings.Contains(d.Message, "tool example.com/missing/cmd/tool is not provided") {
+			found = true
+			if got, want := d.Range.Start.Line, uint32(4); got != want {
+				t.Errorf("diagnostic on line %d, want %d", got, want)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("ModDiagnostics() = %v, want a diagnostic for the unresolvable tool", diags)
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"sort"
+
+	"golang.org/x/tools/go/types/objectpath"
+)
+
+// ExportedAPIDiff returns the changes, sorted by objectpath, to the exported
+// API of the specified package between the old and new snapshots, as
+// reported by Snapshot.ExportedAPI. A declaration whose kind or signature
+// differs is reported as changed.
+func ExportedAPIDiff(ctx context.Context, old, new Snapshot, id PackageID) ([]APIChange, error) {
+	oldDecls, err := old.ExportedAPI(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	newDecls, err := new.ExportedAPI(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return diffExportedAPI(oldDecls, newDecls), nil
+}
+
+// diffExportedAPI returns the changes, sorted by objectpath, from the old
+// to the new exported declarations.
+func diffExportedAPI(old, new []ExportedDecl) []APIChange {
+	oldByPath := make(map[objectpath.Path]ExportedDecl, len(old))
+	for _, decl := range old {
+		oldByPath[decl.Path] = decl
+	}
+	var changes []APIChange
+	for _, decl := range new {
+		prev, ok := oldByPath[decl.Path]
+		switch {
+		case !ok:
+			changes = append(changes, APIChange{Path: decl.Path, Change: "added", New: decl.Signature})
+		case prev.Kind != decl.Kind || prev.Signature != decl.Signature:
+			changes = append(changes, APIChange{Path: decl.Path, Change: "changed", Old: prev.Signature, New: decl.Signature})
+		}
+		delete(oldByPath, decl.Path)
+	}
+	for _, decl := range oldByPath {
+		changes = append(changes, APIChange{Path: decl.Path, Change: "removed", Old: decl.Signature})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
@@ -0,0 +1,36 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffExportedAPI(t *testing.T) {
+	old := []ExportedDecl{
+		{Path: "F", Kind: "func", Signature: "func F(x int) error"},
+		{Path: "T", Kind: "type", Signature: "type T struct{X int}"},
+		{Path: "T.M0", Kind: "method", Signature: "func (T) M()"},
+		{Path: "V", Kind: "var", Signature: "var V int"},
+	}
+	new := []ExportedDecl{
+		{Path: "F", Kind: "func", Signature: "func F(x int, y int) error"},
+		{Path: "G", Kind: "func", Signature: "func G()"},
+		{Path: "T", Kind: "type", Signature: "type T struct{X int}"},
+		{Path: "T.M0", Kind: "method", Signature: "func (T) M()"},
+	}
+	want := []APIChange{
+		{Path: "F", Change: "changed", Old: "func F(x int) error", New: "func F(x int, y int) error"},
+		{Path: "G", Change: "added", New: "func G()"},
+		{Path: "V", Change: "removed", Old: "var V int"},
+	}
+	if got := diffExportedAPI(old, new); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffExportedAPI() = %+v, want %+v", got, want)
+	}
+	if got := diffExportedAPI(old, old); got != nil {
+		t.Errorf("diffExportedAPI(old, old) = %+v, want none", got)
+	}
+}
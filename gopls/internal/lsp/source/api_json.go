@@ -88,6 +88,14 @@ var GeneratedAPIJSON = &APIJSON{
 				Status:    "experimental",
 				Hierarchy: "build",
 			},
+			{
+				Name:      "preferModuleCacheOverVendor",
+				Type:      "bool",
+				Doc:       "preferModuleCacheOverVendor makes gopls load the dependencies of a\nmodule from the module cache rather than from its vendor directory,\neven when the go command would use -mod=vendor, so that navigation\nleads to the source of truth of each dependency.\n\nLocal patches to vendored packages are then ignored, and dependencies\nmissing from the module cache are reported as errors unless\nallowImplicitNetworkAccess is also set.\n",
+				Default:   "false",
+				Status:    "experimental",
+				Hierarchy: "build",
+			},
 			{
 				Name:      "standaloneTags",
 				Type:      "[]string",
@@ -516,6 +524,22 @@ var GeneratedAPIJSON = &APIJSON{
 				Status:    "experimental",
 				Hierarchy: "ui.diagnostic",
 			},
+			{
+				Name:      "suppressionComments",
+				Type:      "[]string",
+				Doc:       "suppressionComments specifies the comment prefixes, such as `nolint`,\nthat mark a line as suppressing diagnostics, for tools that audit\nsuppressions. gopls still reports diagnostics on such lines.\n\nA comment suppresses diagnostics on its own line, or on the following\nline if it is the only content of its line.\n",
+				Default:   "[\"nolint\"]",
+				Status:    "experimental",
+				Hierarchy: "ui.diagnostic",
+			},
+			{
+				Name:      "allowedWorkUses",
+				Type:      "[]string",
+				Doc:       "allowedWorkUses lists the `use` paths of go.work files, as written,\nthat gopls should not warn about for being absolute or for leading\noutside of the workspace folder, such as those of intentional setups\ninvolving sibling repositories.\n",
+				Default:   "[]",
+				Status:    "experimental",
+				Hierarchy: "ui.diagnostic",
+			},
 			{
 				Name:      "diagnosticsDelay",
 				Type:      "time.Duration",
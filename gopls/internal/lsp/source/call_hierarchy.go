@@ -12,12 +12,17 @@ import (
 	"go/token"
 	"go/types"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
 
 	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/types/objectpath"
 	"golang.org/x/tools/gopls/internal/lsp/protocol"
 	"golang.org/x/tools/gopls/internal/span"
 	"golang.org/x/tools/internal/event"
 	"golang.org/x/tools/internal/event/tag"
+	"golang.org/x/tools/internal/typeparams"
 )
 
 // PrepareCallHierarchy returns an array of CallHierarchyItem for a file and the position within the file.
@@ -212,19 +217,34 @@ func OutgoingCalls(ctx context.Context, snapshot Snapshot, fh FileHandle, pos pr
 
 // collectCallExpressions collects call expression ranges inside a function.
 func collectCallExpressions(pgf *ParsedGoFile, node ast.Node) ([]protocol.Range, error) {
-	type callPos struct {
-		start, end token.Pos
+	callRanges := []protocol.Range{}
+	var err error
+	inspectCalls(node, func(call *ast.CallExpr, id *ast.Ident) {
+		if err != nil {
+			return
+		}
+		var callRange protocol.Range
+		callRange, err = pgf.PosRange(id.NamePos, call.Lparen)
+		callRanges = append(callRanges, callRange)
+	})
+	if err != nil {
+		return nil, err
 	}
-	callPositions := []callPos{}
+	return callRanges, nil
+}
 
+// inspectCalls calls f for each outgoing call expression inside node, along
+// with the identifier denoting the callee. Calls of function literals are
+// not considered outgoing, but calls within function literals are.
+func inspectCalls(node ast.Node, f func(call *ast.CallExpr, id *ast.Ident)) {
 	ast.Inspect(node, func(n ast.Node) bool {
 		if call, ok := n.(*ast.CallExpr); ok {
-			var start, end token.Pos
+			var id *ast.Ident
 			switch n := call.Fun.(type) {
 			case *ast.SelectorExpr:
-				start, end = n.Sel.NamePos, call.Lparen
+				id = n.Sel
 			case *ast.Ident:
-				start, end = n.NamePos, call.Lparen
+				id = n
 			case *ast.FuncLit:
 				// while we don't add the function literal as an 'outgoing' call
 				// we still want to traverse into it
@@ -234,20 +254,346 @@ func collectCallExpressions(pgf *ParsedGoFile, node ast.Node) ([]protocol.Range,
 				// for ex: direct function literal calls since that's not an 'outgoing' call
 				return false
 			}
-			callPositions = append(callPositions, callPos{start: start, end: end})
+			f(call, id)
 		}
 		return true
 	})
+}
 
-	callRanges := []protocol.Range{}
-	for _, call := range callPositions {
-		callRange, err := pgf.PosRange(call.start, call.end)
+// CallGraph returns the intra-package call graph of pkg: for each function
+// and method declared in pkg, identified by its objectpath, the sorted
+// objectpaths of the functions and methods of pkg that it calls directly.
+//
+// Calls within function literals are attributed to the enclosing declaration.
+//
+// Only functions with an objectpath appear in the graph. Unexported
+// package-level functions have none (objectpath.For fails with "no path for
+// non-exported"), so they are omitted, along with all their incoming and
+// outgoing edges: if F calls an unexported helper that calls G, the graph
+// records neither edge, nor any edge from F to G. Exported functions and
+// the methods of package-level types, exported or not, are all present.
+func CallGraph(pkg Package) map[objectpath.Path][]objectpath.Path {
+	graph := make(map[objectpath.Path][]objectpath.Path)
+	for caller, callees := range funcCallGraph(pkg) {
+		callerPath, err := objectpath.For(caller)
 		if err != nil {
-			return nil, err
+			continue
 		}
-		callRanges = append(callRanges, callRange)
+		paths := make([]objectpath.Path, 0, len(callees))
+		for _, callee := range callees {
+			if path, err := objectpath.For(callee); err == nil {
+				paths = append(paths, path)
+			}
+		}
+		sort.Slice(paths, func(i, j int) bool { return paths[i] < paths[j] })
+		graph[callerPath] = paths
 	}
-	return callRanges, nil
+	return graph
+}
+
+// funcCallGraph returns the intra-package call graph of pkg, like CallGraph,
+// but identifies functions by their declaring objects, so that it includes
+// every function and method declared in pkg with a body. The callees of
+// each function are de-duplicated but unordered.
+func funcCallGraph(pkg Package) map[*types.Func][]*types.Func {
+	info := pkg.GetTypesInfo()
+	graph := make(map[*types.Func][]*types.Func)
+	for _, pgf := range pkg.CompiledGoFiles() {
+		for _, decl := range pgf.File.Decls {
+			decl, ok := decl.(*ast.FuncDecl)
+			if !ok || decl.Body == nil {
+				continue
+			}
+			caller, ok := info.Defs[decl.Name].(*types.Func)
+			if !ok {
+				continue
+			}
+			seen := make(map[*types.Func]bool)
+			callees := []*types.Func{}
+			inspectCalls(decl.Body, func(_ *ast.CallExpr, id *ast.Ident) {
+				callee, ok := info.Uses[id].(*types.Func)
+				if !ok || callee.Pkg() != pkg.GetTypes() {
+					return
+				}
+				callee = typeparams.OriginMethod(callee)
+				if !seen[callee] {
+					seen[callee] = true
+					callees = append(callees, callee)
+				}
+			})
+			graph[caller] = callees
+		}
+	}
+	return graph
+}
+
+// CallersMatching returns the call sites, within pkgs, of each function and
+// method declared in pkgs whose name matches re. The result maps the
+// package path and objectpath of each such function to the sorted
+// locations of the identifiers denoting it in calls; functions that are
+// never called map to an empty list.
+func CallersMatching(pkgs []Package, re *regexp.Regexp) (map[PackagePath]map[objectpath.Path][]protocol.Location, error) {
+	result := make(map[PackagePath]map[objectpath.Path][]protocol.Location)
+	addTarget := func(pkgPath PackagePath, obj types.Object) {
+		path, err := objectpath.For(obj)
+		if err != nil {
+			return
+		}
+		targets := result[pkgPath]
+		if targets == nil {
+			targets = make(map[objectpath.Path][]protocol.Location)
+			result[pkgPath] = targets
+		}
+		if _, ok := targets[path]; !ok {
+			targets[path] = []protocol.Location{}
+		}
+	}
+	for _, pkg := range pkgs {
+		scope := pkg.GetTypes().Scope()
+		for _, name := range scope.Names() {
+			switch obj := scope.Lookup(name).(type) {
+			case *types.Func:
+				if re.MatchString(obj.Name()) {
+					addTarget(pkg.PkgPath(), obj)
+				}
+			case *types.TypeName:
+				if named, ok := obj.Type().(*types.Named); ok && !obj.IsAlias() {
+					for i := 0; i < named.NumMethods(); i++ {
+						if m := named.Method(i); re.MatchString(m.Name()) {
+							addTarget(pkg.PkgPath(), m)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	// Packages and their test variants share files, so call sites may be
+	// found more than once.
+	seen := make(map[protocol.Location]bool)
+	for _, pkg := range pkgs {
+		info := pkg.GetTypesInfo()
+		for _, pgf := range pkg.CompiledGoFiles() {
+			var err error
+			inspectCalls(pgf.File, func(_ *ast.CallExpr, id *ast.Ident) {
+				callee, ok := info.Uses[id].(*types.Func)
+				if !ok || callee.Pkg() == nil || !re.MatchString(callee.Name()) || err != nil {
+					return
+				}
+				targets := result[PackagePath(callee.Pkg().Path())]
+				if targets == nil {
+					return
+				}
+				path, pathErr := objectpath.For(typeparams.OriginMethod(callee))
+				if pathErr != nil {
+					return
+				}
+				locs, ok := targets[path]
+				if !ok {
+					return
+				}
+				var loc protocol.Location
+				loc, err = pgf.Mapper.PosLocation(pgf.Tok, id.Pos(), id.End())
+				if err == nil && !seen[loc] {
+					seen[loc] = true
+					targets[path] = append(locs, loc)
+				}
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, targets := range result {
+		for _, locs := range targets {
+			sort.Slice(locs, func(i, j int) bool {
+				if locs[i].URI != locs[j].URI {
+					return locs[i].URI < locs[j].URI
+				}
+				return protocol.CompareRange(locs[i].Range, locs[j].Range) < 0
+			})
+		}
+	}
+	return result, nil
+}
+
+// TestsReaching returns the test and benchmark functions declared in pkgs
+// that may call, directly or through calls to functions declared in pkgs,
+// the function or method fn. Functions are identified by their full names,
+// so that fn and pkgs need not share their types. The result is sorted by
+// package path and name.
+//
+// The analysis is static: a test is reported if any chain of call
+// expressions leads from it to fn, whether or not the calls are executed.
+// It is conservative about dynamic calls, in the manner of
+// UnreachableFunctions: every test is reported if fn may be called by
+//   - a method with the name of a method of any interface type used in
+//     pkgs or declared in their transitive dependencies, since it may be
+//     called through the interface;
+//   - a function or method referenced other than as the callee of a call
+//     within a function or method: for example, as a function value, or
+//     by calls in package-level initializers and init functions.
+func TestsReaching(pkgs []Package, fn *types.Func) ([]TestFunc, error) {
+	// The full name of a package-level function or method, such as
+	// "(*example.com/a.T).M", identifies it within the test variants of
+	// its package too.
+	type funcKey string
+	keyOf := func(fn *types.Func) (funcKey, bool) {
+		if fn.Pkg() == nil {
+			return "", false // builtin
+		}
+		return funcKey(typeparams.OriginMethod(fn).FullName()), true
+	}
+
+	// Record the names of the methods of all interfaces, including those
+	// declared in dependencies, such as fmt.Stringer: a method with one of
+	// these names may be called dynamically.
+	ifaceMethods := make(map[string]bool)
+	addIface := func(t types.Type) {
+		if iface, ok := t.Underlying().(*types.Interface); ok {
+			for i := 0; i < iface.NumMethods(); i++ {
+				ifaceMethods[iface.Method(i).Name()] = true
+			}
+		}
+	}
+	seen := make(map[*types.Package]bool)
+	var addPackage func(pkg *types.Package)
+	addPackage = func(pkg *types.Package) {
+		if seen[pkg] {
+			return
+		}
+		seen[pkg] = true
+		scope := pkg.Scope()
+		for _, name := range scope.Names() {
+			if tname, ok := scope.Lookup(name).(*types.TypeName); ok {
+				addIface(tname.Type())
+			}
+		}
+		for _, imp := range pkg.Imports() {
+			addPackage(imp)
+		}
+	}
+	for _, pkg := range pkgs {
+		for _, tv := range pkg.GetTypesInfo().Types {
+			addIface(tv.Type)
+		}
+		addPackage(pkg.GetTypes())
+	}
+
+	callers := make(map[funcKey]map[funcKey]bool)
+	dynamic := make(map[funcKey]bool) // functions that every test may reach
+	tests := make(map[funcKey]TestFunc)
+	for _, pkg := range pkgs {
+		info := pkg.GetTypesInfo()
+		addDynamic := func(n ast.Node, callees map[*ast.Ident]bool) {
+			ast.Inspect(n, func(n ast.Node) bool {
+				id, ok := n.(*ast.Ident)
+				if !ok || callees[id] {
+					return true
+				}
+				if fn, ok := info.Uses[id].(*types.Func); ok {
+					if key, ok := keyOf(fn); ok {
+						dynamic[key] = true
+					}
+				}
+				return true
+			})
+		}
+		for _, pgf := range pkg.CompiledGoFiles() {
+			isTestFile := strings.HasSuffix(pgf.URI.Filename(), "_test.go")
+			for _, d := range pgf.File.Decls {
+				decl, ok := d.(*ast.FuncDecl)
+				if !ok {
+					addDynamic(d, nil)
+					continue
+				}
+				if decl.Body == nil {
+					continue
+				}
+				caller, ok := info.Defs[decl.Name].(*types.Func)
+				if !ok {
+					continue
+				}
+				if decl.Recv == nil && caller.Name() == "init" {
+					// Calls from init functions are made before every test.
+					addDynamic(decl, nil)
+					continue
+				}
+				callerKey, ok := keyOf(caller)
+				if !ok {
+					continue
+				}
+				if decl.Recv != nil && ifaceMethods[caller.Name()] {
+					dynamic[callerKey] = true
+				}
+				if isTestFile && decl.Recv == nil && (matchTestFunc(decl, pkg, testRe, "T") || matchTestFunc(decl, pkg, benchmarkRe, "B")) {
+					if _, ok := tests[callerKey]; !ok { // test variants share files
+						loc, err := pgf.Mapper.PosLocation(pgf.Tok, decl.Name.Pos(), decl.Name.End())
+						if err != nil {
+							return nil, err
+						}
+						tests[callerKey] = TestFunc{Name: decl.Name.Name, Package: pkg.PkgPath(), Location: loc}
+					}
+				}
+				callees := make(map[*ast.Ident]bool)
+				inspectCalls(decl.Body, func(_ *ast.CallExpr, id *ast.Ident) {
+					callee, ok := info.Uses[id].(*types.Func)
+					if !ok {
+						return
+					}
+					calleeKey, ok := keyOf(callee)
+					if !ok {
+						return
+					}
+					callees[id] = true
+					if callers[calleeKey] == nil {
+						callers[calleeKey] = make(map[funcKey]bool)
+					}
+					callers[calleeKey][callerKey] = true
+				})
+				addDynamic(decl.Body, callees)
+			}
+		}
+	}
+
+	var (
+		result []TestFunc
+		all    bool // whether every test may reach fn
+	)
+	reached := make(map[funcKey]bool)
+	var visit func(key funcKey)
+	visit = func(key funcKey) {
+		if reached[key] {
+			return
+		}
+		reached[key] = true
+		all = all || dynamic[key]
+		if test, ok := tests[key]; ok {
+			result = append(result, test)
+		}
+		for caller := range callers[key] {
+			visit(caller)
+		}
+	}
+	if key, ok := keyOf(fn); ok {
+		visit(key)
+	}
+	if all {
+		result = result[:0]
+		for _, test := range tests {
+			result = append(result, test)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		ti, tj := result[i], result[j]
+		if ti.Package != tj.Package {
+			return ti.Package < tj.Package
+		}
+		return ti.Name < tj.Name
+	})
+	return result, nil
 }
 
 // toProtocolOutgoingCalls returns an array of protocol.CallHierarchyOutgoingCall for ast call expressions.
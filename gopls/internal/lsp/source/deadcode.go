@@ -0,0 +1,133 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"go/ast"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/internal/typeparams"
+)
+
+// UnreachableFunctions returns the functions and methods declared in pkg
+// that cannot be reached, through the calls of the intra-package call graph,
+// from any root, in order of declaration. Unlike CallGraph, it identifies
+// functions by their declaring objects, so unexported package-level
+// functions, which have no objectpath, are reported too.
+//
+// The analysis is conservative, and so may fail to report dead code, but
+// should not report live code. The roots are:
+//   - exported functions and methods, and main and init functions;
+//   - Test, Benchmark, Fuzz, and Example functions of _test.go files;
+//   - methods with the name of a method of any interface type known to
+//     pkg, since they may satisfy the interface and be called dynamically;
+//   - functions and methods referenced other than as the callee of a call
+//     within a function or method: for example, function values, and calls
+//     in package-level initializers.
+func UnreachableFunctions(pkg Package) []*types.Func {
+	info := pkg.GetTypesInfo()
+	self := pkg.GetTypes()
+
+	// Record the names of the methods of all interfaces.
+	ifaceMethods := make(map[string]bool)
+	for _, tv := range info.Types {
+		if iface, ok := tv.Type.Underlying().(*types.Interface); ok {
+			for i := 0; i < iface.NumMethods(); i++ {
+				ifaceMethods[iface.Method(i).Name()] = true
+			}
+		}
+	}
+
+	var declared []*types.Func
+	roots := make(map[*types.Func]bool)
+	addRefRoots := func(n ast.Node, callees map[*ast.Ident]bool) {
+		ast.Inspect(n, func(n ast.Node) bool {
+			id, ok := n.(*ast.Ident)
+			if !ok || callees[id] {
+				return true
+			}
+			if fn, ok := info.Uses[id].(*types.Func); ok && fn.Pkg() == self {
+				roots[typeparams.OriginMethod(fn)] = true
+			}
+			return true
+		})
+	}
+	for _, pgf := range pkg.CompiledGoFiles() {
+		isTest := strings.HasSuffix(pgf.URI.Filename(), "_test.go")
+		for _, d := range pgf.File.Decls {
+			decl, ok := d.(*ast.FuncDecl)
+			if !ok {
+				addRefRoots(d, nil)
+				continue
+			}
+			fn, ok := info.Defs[decl.Name].(*types.Func)
+			if !ok {
+				continue
+			}
+			if decl.Body != nil {
+				declared = append(declared, fn)
+			}
+			if isRoot(fn, decl, self, isTest, ifaceMethods) {
+				roots[fn] = true
+			}
+			callees := make(map[*ast.Ident]bool)
+			if decl.Body != nil {
+				inspectCalls(decl.Body, func(_ *ast.CallExpr, id *ast.Ident) {
+					callees[id] = true
+				})
+			}
+			addRefRoots(decl, callees)
+		}
+	}
+
+	// Mark everything reachable from the roots.
+	graph := funcCallGraph(pkg)
+	reached := make(map[*types.Func]bool)
+	var visit func(fn *types.Func)
+	visit = func(fn *types.Func) {
+		if reached[fn] {
+			return
+		}
+		reached[fn] = true
+		for _, callee := range graph[fn] {
+			visit(callee)
+		}
+	}
+	for fn := range roots {
+		visit(fn)
+	}
+
+	var unreachable []*types.Func
+	for _, fn := range declared {
+		if !reached[fn] {
+			unreachable = append(unreachable, fn)
+		}
+	}
+	return unreachable
+}
+
+// isRoot reports whether the function fn, declared by decl, is a root of
+// the reachability analysis of UnreachableFunctions, on account of its name.
+func isRoot(fn *types.Func, decl *ast.FuncDecl, self *types.Package, isTest bool, ifaceMethods map[string]bool) bool {
+	name := fn.Name()
+	if fn.Exported() {
+		return true
+	}
+	if decl.Recv != nil {
+		return ifaceMethods[name]
+	}
+	if name == "init" || name == "main" && self.Name() == "main" {
+		return true
+	}
+	if isTest {
+		for _, prefix := range []string{"Test", "Benchmark", "Fuzz", "Example"} {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -131,7 +131,8 @@ func DefaultOptions() *Options {
 							Inline: true,
 							Nil:    true,
 						},
-						Vulncheck: ModeVulncheckOff,
+						Vulncheck:           ModeVulncheckOff,
+						SuppressionComments: []string{"nolint"},
 					},
 					InlayHintOptions: InlayHintOptions{},
 					DocumentationOptions: DocumentationOptions{
@@ -293,6 +294,16 @@ type BuildOptions struct {
 	// be removed.
 	AllowImplicitNetworkAccess bool `status:"experimental"`
 
+	// PreferModuleCacheOverVendor makes gopls load the dependencies of a
+	// module from the module cache rather than from its vendor directory,
+	// even when the go command would use -mod=vendor, so that navigation
+	// leads to the source of truth of each dependency.
+	//
+	// Local patches to vendored packages are then ignored, and dependencies
+	// missing from the module cache are reported as errors unless
+	// allowImplicitNetworkAccess is also set.
+	PreferModuleCacheOverVendor bool `status:"experimental"`
+
 	// StandaloneTags specifies a set of build constraints that identify
 	// individual Go source files that make up the entire main package of an
 	// executable.
@@ -434,6 +445,20 @@ type DiagnosticOptions struct {
 	// Vulncheck enables vulnerability scanning.
 	Vulncheck VulncheckMode `status:"experimental"`
 
+	// SuppressionComments specifies the comment prefixes, such as `nolint`,
+	// that mark a line as suppressing diagnostics, for tools that audit
+	// suppressions. gopls still reports diagnostics on such lines.
+	//
+	// A comment suppresses diagnostics on its own line, or on the following
+	// line if it is the only content of its line.
+	SuppressionComments []string `status:"experimental"`
+
+	// AllowedWorkUses lists the `use` paths of go.work files, as written,
+	// that gopls should not warn about for being absolute or for leading
+	// outside of the workspace folder, such as those of intentional setups
+	// involving sibling repositories.
+	AllowedWorkUses []string `status:"experimental"`
+
 	// DiagnosticsDelay controls the amount of time that gopls waits
 	// after the most recent file modification before computing deep diagnostics.
 	// Simple diagnostics (parsing and type-checking) are always run immediately
@@ -619,6 +644,21 @@ type InternalOptions struct {
 	// file change. If unset, gopls only reports diagnostics when they change, or
 	// when a file is opened or closed.
 	ChattyDiagnostics bool
+
+	// TypeCheckConcurrency bounds the number of packages of a view,
+	// including dependencies, that are type-checked in parallel across all
+	// requests. If zero, type-checking is unbounded. Background operations
+	// may further bound their own requests; see WithTypeCheckConcurrency.
+	TypeCheckConcurrency int
+
+	// GoCommandConcurrency bounds the number of go commands that a session
+	// runs concurrently. If zero, a default bound is used.
+	GoCommandConcurrency int
+
+	// GoCommandTimeout bounds the duration of each go command invocation.
+	// If zero, only invocations that write a temporary go.mod file, which
+	// may not otherwise be canceled, are bounded, by a generous default.
+	GoCommandTimeout time.Duration
 }
 
 type ImportShortcut string
@@ -1033,6 +1073,12 @@ func (o *Options) set(name string, value interface{}, seen map[string]struct{})
 			o.Vulncheck = VulncheckMode(s)
 		}
 
+	case "suppressionComments":
+		result.setStringSlice(&o.SuppressionComments)
+
+	case "allowedWorkUses":
+		result.setStringSlice(&o.AllowedWorkUses)
+
 	case "codelenses", "codelens":
 		var lensOverrides map[string]bool
 		result.setBoolMap(&lensOverrides)
@@ -1146,6 +1192,9 @@ func (o *Options) set(name string, value interface{}, seen map[string]struct{})
 	case "allowImplicitNetworkAccess":
 		result.setBool(&o.AllowImplicitNetworkAccess)
 
+	case "preferModuleCacheOverVendor":
+		result.setBool(&o.PreferModuleCacheOverVendor)
+
 	case "experimentalUseInvalidMetadata":
 		result.deprecated("")
 
@@ -1162,6 +1211,15 @@ func (o *Options) set(name string, value interface{}, seen map[string]struct{})
 	case "chattyDiagnostics":
 		result.setBool(&o.ChattyDiagnostics)
 
+	case "typeCheckConcurrency":
+		result.setNonNegativeInt(&o.TypeCheckConcurrency)
+
+	case "goCommandConcurrency":
+		result.setNonNegativeInt(&o.GoCommandConcurrency)
+
+	case "goCommandTimeout":
+		result.setDuration(&o.GoCommandTimeout)
+
 	// Replaced settings.
 	case "experimentalDisabledAnalyses":
 		result.deprecated("analyses")
@@ -1259,6 +1317,16 @@ func (r *OptionResult) setBool(b *bool) {
 	}
 }
 
+func (r *OptionResult) setNonNegativeInt(i *int) {
+	// JSON numbers are decoded as float64.
+	v, ok := r.Value.(float64)
+	if !ok || v < 0 || v != float64(int(v)) {
+		r.parseErrorf("invalid value %v, expect non-negative integer", r.Value)
+		return
+	}
+	*i = int(v)
+}
+
 func (r *OptionResult) setDuration(d *time.Duration) {
 	if v, ok := r.asString(); ok {
 		parsed, err := time.ParseDuration(v)
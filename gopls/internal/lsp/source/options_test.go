@@ -156,6 +156,13 @@ func TestSetOption(t *testing.T) {
 				return len(o.DirectoryFilters) == 0
 			},
 		},
+		{
+			name:  "allowedWorkUses",
+			value: []interface{}{"../shared", "/opt/go/mod"},
+			check: func(o Options) bool {
+				return len(o.AllowedWorkUses) == 2 && o.AllowedWorkUses[1] == "/opt/go/mod"
+			},
+		},
 		{
 			name: "annotations",
 			value: map[string]interface{}{
@@ -182,6 +189,27 @@ func TestSetOption(t *testing.T) {
 				return o.Vulncheck == ModeVulncheckImports // For invalid value, default to 'off'.
 			},
 		},
+		{
+			name:  "typeCheckConcurrency",
+			value: 4.0,
+			check: func(o Options) bool { return o.TypeCheckConcurrency == 4 },
+		},
+		{
+			name:      "typeCheckConcurrency",
+			value:     -1.0,
+			wantError: true,
+			check:     func(o Options) bool { return o.TypeCheckConcurrency == 0 },
+		},
+		{
+			name:  "goCommandConcurrency",
+			value: 2.0,
+			check: func(o Options) bool { return o.GoCommandConcurrency == 2 },
+		},
+		{
+			name:  "goCommandTimeout",
+			value: "90s",
+			check: func(o Options) bool { return o.GoCommandTimeout == 90*time.Second },
+		},
 		{
 			name:  "vulncheck",
 			value: "imports",
@@ -251,6 +251,84 @@ func NewSignature(ctx context.Context, s Snapshot, pkg Package, sig *types.Signa
 	}
 }
 
+// FormatSignature returns the declaration of the function or method obj as
+// it would appear in source, without its body, such as
+// "func (r *T) M(x int, ys ...string) (n int, err error)". Type names are
+// qualified by qf.
+//
+// Unlike NewSignature, FormatSignature uses only type information, so type
+// aliases are not preserved. Objects other than functions are formatted by
+// types.ObjectString.
+func FormatSignature(obj types.Object, qf types.Qualifier) string {
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		return types.ObjectString(obj, qf)
+	}
+	sig := fn.Type().(*types.Signature)
+
+	var b strings.Builder
+	b.WriteString("func ")
+	if recv := sig.Recv(); recv != nil {
+		b.WriteByte('(')
+		if recv.Name() != "" && recv.Name() != "_" {
+			b.WriteString(recv.Name())
+			b.WriteByte(' ')
+		}
+		b.WriteString(types.TypeString(recv.Type(), qf))
+		b.WriteString(") ")
+	}
+	b.WriteString(fn.Name())
+
+	if tparams := typeparams.ForSignature(sig); tparams.Len() > 0 {
+		b.WriteByte('[')
+		for i := 0; i < tparams.Len(); i++ {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			tparam := tparams.At(i)
+			b.WriteString(tparam.Obj().Name())
+			b.WriteByte(' ')
+			b.WriteString(types.TypeString(tparam.Constraint(), qf))
+		}
+		b.WriteByte(']')
+	}
+
+	writeTuple := func(tuple *types.Tuple, variadic bool) {
+		for i := 0; i < tuple.Len(); i++ {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			v := tuple.At(i)
+			if v.Name() != "" {
+				b.WriteString(v.Name())
+				b.WriteByte(' ')
+			}
+			if variadic && i == tuple.Len()-1 {
+				b.WriteString("...")
+				b.WriteString(types.TypeString(v.Type().(*types.Slice).Elem(), qf))
+			} else {
+				b.WriteString(types.TypeString(v.Type(), qf))
+			}
+		}
+	}
+	b.WriteByte('(')
+	writeTuple(sig.Params(), sig.Variadic())
+	b.WriteByte(')')
+
+	results := sig.Results()
+	if results.Len() > 0 {
+		b.WriteByte(' ')
+		if results.Len() == 1 && results.At(0).Name() == "" {
+			writeTuple(results, false)
+		} else {
+			b.WriteByte('(')
+			writeTuple(results, false)
+			b.WriteByte(')')
+		}
+	}
+	return b.String()
+}
+
 // FormatVarType formats a *types.Var, accounting for type aliases.
 // To do this, it looks in the AST of the file in which the object is declared.
 // On any errors, it always falls back to types.TypeString.
@@ -0,0 +1,62 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+)
+
+func TestFormatSignature(t *testing.T) {
+	const src = `package p
+
+type T struct{}
+
+func F() {}
+func G(x, y int, s ...string) error { return nil }
+func H(p *T) (n int, err error) { return }
+func (t *T) M(f func(int) bool) T { return *t }
+func (T) N(...interface{}) {}
+
+var V int
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := new(types.Config).Check("p", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	T := pkg.Scope().Lookup("T").Type()
+	lookup := func(name string) types.Object {
+		if obj := pkg.Scope().Lookup(name); obj != nil {
+			return obj
+		}
+		obj, _, _ := types.LookupFieldOrMethod(T, true, pkg, name)
+		return obj
+	}
+
+	qf := types.RelativeTo(pkg)
+	tests := []struct {
+		name, want string
+	}{
+		{"F", "func F()"},
+		{"G", "func G(x int, y int, s ...string) error"},
+		{"H", "func H(p *T) (n int, err error)"},
+		{"M", "func (t *T) M(f func(int) bool) T"},
+		{"N", "func (T) N(...interface{})"},
+		{"V", "var V int"},
+	}
+	for _, test := range tests {
+		if got := FormatSignature(lookup(test.name), qf); got != test.want {
+			t.Errorf("FormatSignature(%s) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
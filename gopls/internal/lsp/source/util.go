@@ -25,20 +25,21 @@ import (
 
 // IsGenerated gets and reads the file denoted by uri and reports
 // whether it contains a "go:generated" directive as described at
-// https://golang.org/s/generatedcode.
-//
-// TODO(adonovan): opt: this function does too much.
-// Move snapshot.GetFile into the caller (most of which have already done it).
+// https://golang.org/s/generatedcode. It returns false if the file cannot
+// be read; see Snapshot.IsGenerated.
 func IsGenerated(ctx context.Context, snapshot Snapshot, uri span.URI) bool {
-	fh, err := snapshot.GetFile(ctx, uri)
-	if err != nil {
-		return false
-	}
-	pgf, err := snapshot.ParseGo(ctx, fh, ParseHeader)
-	if err != nil {
-		return false
-	}
+	generated, err := snapshot.IsGenerated(ctx, uri)
+	return err == nil && generated
+}
+
+// IsGenerated reports whether the file contains a "Code generated ... DO
+// NOT EDIT." comment, at the start of a line, before its package clause, as
+// described at https://golang.org/s/generatedcode.
+func (pgf *ParsedGoFile) IsGenerated() bool {
 	for _, commentGroup := range pgf.File.Comments {
+		if pgf.File.Package.IsValid() && commentGroup.Pos() > pgf.File.Package {
+			break
+		}
 		for _, comment := range commentGroup.List {
 			if matched := generatedRx.MatchString(comment.Text); matched {
 				// Check if comment is at the beginning of the line in source.
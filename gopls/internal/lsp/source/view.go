@@ -11,15 +11,20 @@ import (
 	"errors"
 	"fmt"
 	"go/ast"
+	"go/build/constraint"
+	"go/doc"
 	"go/scanner"
 	"go/token"
 	"go/types"
 	"io"
+	"regexp"
+	"sort"
 	"strings"
 
 	"golang.org/x/mod/modfile"
 	"golang.org/x/mod/module"
 	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/ast/astutil"
 	"golang.org/x/tools/go/packages"
 	"golang.org/x/tools/go/types/objectpath"
 	"golang.org/x/tools/gopls/internal/govulncheck"
@@ -91,6 +96,18 @@ type Snapshot interface {
 	// AwaitInitialized waits until the snapshot's view is initialized.
 	AwaitInitialized(ctx context.Context)
 
+	// OverlayDiff returns the edits that transform the on-disk content of
+	// the specified file into the content of its overlay, or an empty slice
+	// if the overlay is saved. It returns an error if the file has no
+	// overlay. A missing file on disk is treated as empty.
+	OverlayDiff(ctx context.Context, uri span.URI) ([]protocol.TextEdit, error)
+
+	// CanonicalURI returns the URI of the file denoted by uri with all
+	// symbolic links in its path resolved, so that the same physical file
+	// has one URI. It returns uri unchanged if the links cannot be
+	// resolved, for example because the file does not exist on disk.
+	CanonicalURI(uri span.URI) span.URI
+
 	// IsOpen returns whether the editor currently has a file open.
 	IsOpen(uri span.URI) bool
 
@@ -109,6 +126,19 @@ type Snapshot interface {
 	// Analyze runs the specified analyzers on the given package at this snapshot.
 	Analyze(ctx context.Context, id PackageID, analyzers []*Analyzer) ([]*Diagnostic, error)
 
+	// InvalidateAnalyses replaces the latest snapshot of the view with a
+	// copy in which the cached analysis results that depend on any of the
+	// named analyzers have been discarded, and returns it. Parsed files,
+	// type-checked packages, and the results of other analyzers are
+	// retained. The caller must call the returned function to release the
+	// new snapshot. It is called when the set of enabled analyzers changes.
+	InvalidateAnalyses(names []string) (Snapshot, func())
+
+	// RunVet runs "go vet" on the specified package, and returns its findings
+	// as diagnostics. It complements Analyze for vet checks that are not
+	// available as analyzers in gopls.
+	RunVet(ctx context.Context, id PackageID) ([]*Diagnostic, error)
+
 	// RunGoCommandPiped runs the given `go` command, writing its output
 	// to stdout and stderr. Verb, Args, and WorkingDir must be specified.
 	//
@@ -129,6 +159,15 @@ type Snapshot interface {
 	// Note: the process env contains cached module and filesystem state.
 	RunProcessEnvFunc(ctx context.Context, fn func(*imports.Options) error) error
 
+	// SuggestImports returns, for each of the given identifiers that could
+	// be resolved, the import to add to the given file so that the
+	// identifier refers to an imported package. Each identifier is a package
+	// name, such as "rand", or a qualified identifier, such as "rand.Int", in
+	// which case only packages exporting the selected name are candidates.
+	// Identifiers whose package name is already imported by the file are
+	// ignored.
+	SuggestImports(ctx context.Context, uri span.URI, identifiers []string) (map[string]ImportSuggestion, error)
+
 	// ModFiles are the go.mod files enclosed in the snapshot's view and known
 	// to the snapshot.
 	ModFiles() []span.URI
@@ -144,16 +183,76 @@ type Snapshot interface {
 	// the given go.mod file.
 	ModTidy(ctx context.Context, pm *ParsedModule) (*TidiedModule, error)
 
+	// UntidyModules returns the sorted URIs of the active go.mod files whose
+	// content would be changed by "go mod tidy". No files are modified.
+	// Modules whose go.mod file is not on disk are not reported.
+	UntidyModules(ctx context.Context) ([]span.URI, error)
+
+	// BuildList returns the build list of the module of the given go.mod
+	// file, as reported by "go list -m all": the main module first, followed
+	// by the selected version of each module in the module graph.
+	BuildList(ctx context.Context, modURI span.URI) ([]module.Version, error)
+
+	// ModuleDependencyTree returns the requirement graph of the module of
+	// the given go.mod file as a tree rooted at the main module, in which
+	// each required module appears at the version selected by MVS. See
+	// ModuleNode for the representation of shared modules and cycles.
+	ModuleDependencyTree(ctx context.Context, modURI span.URI) (*ModuleNode, error)
+
+	// UnusedRequires returns diagnostics for the require directives of the
+	// given go.mod file that are not needed by any loaded package. Unlike
+	// ModTidy, it does not run the go command, and is derived from metadata
+	// for the current build configuration only, so its diagnostics are
+	// informational and offer no fix.
+	UnusedRequires(ctx context.Context, pm *ParsedModule) ([]*Diagnostic, error)
+
 	// ModVuln returns import vulnerability analysis for the given go.mod URI.
 	// Concurrent requests are combined into a single command.
 	ModVuln(ctx context.Context, modURI span.URI) (*govulncheck.Result, error)
 
+	// ModVulnAsync is like ModVuln, but runs the analysis in the background,
+	// reporting its progress to onProgress. The returned channel receives the
+	// result on success, and is closed when the analysis completes.
+	ModVulnAsync(ctx context.Context, modURI span.URI, onProgress func(fraction float64)) (<-chan *govulncheck.Result, error)
+
+	// UpdateGoSumForRequire downloads the module path@version, and returns
+	// the contents of the go.sum file of the module modURI updated with its
+	// checksums, without modifying any files on disk. It fails if network
+	// access is disabled.
+	UpdateGoSumForRequire(ctx context.Context, modURI span.URI, path, version string) ([]byte, error)
+
+	// ReplaceTargetDirs returns the local directories to which the replace
+	// directives of the active go.mod files point, sorted.
+	ReplaceTargetDirs(ctx context.Context) ([]span.URI, error)
+
+	// GoVersions returns the minor Go version declared by the go directive of
+	// each active go.mod file, such as 18 for "go 1.18".
+	GoVersions(ctx context.Context) (map[span.URI]int, error)
+
 	// GoModForFile returns the URI of the go.mod file for the given URI.
 	GoModForFile(uri span.URI) span.URI
 
+	// EnclosingGoMod returns the URI of the nearest go.mod file known to the
+	// snapshot in dir or one of its ancestors, or "" if there is none. Unlike
+	// GoModForFile, it considers all known go.mod files, not just the active
+	// ones, and dir need not contain any files.
+	EnclosingGoMod(dir span.URI) span.URI
+
+	// ModulePathForFile returns the module path declared by the go.mod file
+	// for the given URI, along with the URI of that go.mod file. It returns
+	// ErrNoModule if the file is not contained in any active module.
+	ModulePathForFile(ctx context.Context, uri span.URI) (string, span.URI, error)
+
 	// WorkFile, if non-empty, is the go.work file for the workspace.
 	WorkFile() span.URI
 
+	// WorkspaceModFile returns the content of the module file that
+	// determines the module graph of the workspace, for debugging: the
+	// formatted synthetic go.mod file passed to the go command with
+	// -modfile in multi-module modes, the go.work file in go.work mode, or
+	// the real go.mod file otherwise.
+	WorkspaceModFile(ctx context.Context) ([]byte, error)
+
 	// ParseWork is used to parse go.work files.
 	ParseWork(ctx context.Context, fh FileHandle) (*ParsedWorkFile, error)
 
@@ -169,6 +268,17 @@ type Snapshot interface {
 	// excluding id itself.
 	ReverseDependencies(ctx context.Context, id PackageID, transitive bool) (map[PackageID]*Metadata, error)
 
+	// DirectImporters returns the sorted IDs of the packages that directly
+	// import the package denoted by id. It is equivalent to the keys of
+	// ReverseDependencies(ctx, id, false).
+	DirectImporters(ctx context.Context, id PackageID) ([]PackageID, error)
+
+	// AffectedFiles returns the sorted set of files whose diagnostics may
+	// change as a result of the given modifications: the modified files
+	// themselves, plus the files of every package that directly or
+	// transitively depends on a package containing a modified file.
+	AffectedFiles(ctx context.Context, changes []FileModification) ([]span.URI, error)
+
 	// CachedImportPaths returns all the imported packages loaded in this
 	// snapshot, indexed by their package path (not import path, despite the name)
 	// and checked in TypecheckWorkspace mode.
@@ -184,16 +294,53 @@ type Snapshot interface {
 	// mode, this is just the reverse transitive closure of open packages.
 	ActiveMetadata(ctx context.Context) ([]*Metadata, error)
 
+	// WhyActive returns a description of the reason that the specified
+	// package is among those returned by ActiveMetadata, such as "workspace
+	// package" in normal memory mode, or, in degraded memory mode, the open
+	// file of the package or of the nearest dependency that makes it active.
+	// It returns an error if the package is not active. Unlike
+	// ActiveMetadata, it does not wait for loading to complete.
+	WhyActive(id PackageID) (string, error)
+
+	// PackagesWithErrors type-checks the active packages in
+	// TypecheckWorkspace mode and returns the list, parse, and type errors of
+	// each package that has any, keyed by package ID.
+	PackagesWithErrors(ctx context.Context) (map[PackageID][]*Diagnostic, error)
+
+	// MainFunctions returns the locations of the names of the main functions
+	// of the active main packages, sorted by location. Test variants are
+	// ignored.
+	MainFunctions(ctx context.Context) ([]protocol.Location, error)
+
 	// AllMetadata returns a new unordered array of metadata for all packages in the workspace.
 	AllMetadata(ctx context.Context) ([]*Metadata, error)
 
 	// Symbols returns all symbols in the snapshot.
 	Symbols(ctx context.Context) map[span.URI][]Symbol
 
+	// StreamSymbols symbolizes the Go files of the snapshot's packages in
+	// parallel, calling onPackage (never concurrently) with the symbols of
+	// each package as soon as they are available. It starts with the
+	// packages already loaded, without waiting for the workspace load,
+	// and continues with those of the completed load. Packages are visited
+	// in order of ID, and each file is reported once, with the first
+	// package visited that contains it: a package precedes its test
+	// variants.
+	StreamSymbols(ctx context.Context, onPackage func(PackageID, map[span.URI][]Symbol)) error
+
+	// MatchSymbols returns at most limit symbols of the snapshot that match
+	// the query, ranked using the symbol matcher and style of the view.
+	MatchSymbols(ctx context.Context, query string, limit int) ([]SymbolMatch, error)
+
 	// Metadata returns the metadata for the specified package,
 	// or nil if it was not found.
 	Metadata(id PackageID) *Metadata
 
+	// LoadErrors returns the errors reported by go/packages when loading
+	// the specified package, or nil if it has none or was not found.
+	// The result must not be modified.
+	LoadErrors(id PackageID) []packages.Error
+
 	// MetadataForFile returns a new slice containing metadata for each
 	// package containing the Go file identified by uri, ordered by the
 	// number of CompiledGoFiles (i.e. "narrowest" to "widest" package).
@@ -202,18 +349,302 @@ type Snapshot interface {
 	// It returns an error if the context was cancelled.
 	MetadataForFile(ctx context.Context, uri span.URI) ([]*Metadata, error)
 
+	// SamePackage reports whether the Go files identified by a and b belong
+	// to the same package, comparing the package paths of the narrowest
+	// packages containing them. Thus a _test.go file of package p is in the
+	// same package as the non-test files of p, but files of its external
+	// test package p_test are not. It returns an error if either file
+	// belongs to no package.
+	SamePackage(ctx context.Context, a, b span.URI) (bool, error)
+
+	// StaleMetadataForFile is like MetadataForFile, but does not wait for
+	// the metadata of the packages containing uri to be reloaded after it
+	// has been invalidated. Instead, it returns the metadata from before
+	// the invalidation, and reports stale=true. Once the packages have been
+	// reloaded, it returns the same as MetadataForFile.
+	StaleMetadataForFile(ctx context.Context, uri span.URI) (metas []*Metadata, stale bool, err error)
+
+	// EmbeddedFiles returns a mapping from each Go file of the specified
+	// package containing //go:embed directives to the files embedded by
+	// those directives, resolved as the go command would resolve them.
+	EmbeddedFiles(ctx context.Context, id PackageID) (map[span.URI][]span.URI, error)
+
 	// TypeCheck parses and type-checks the specified packages,
 	// and returns them in the same order as the ids.
+	// The number of packages type-checked in parallel may be bounded;
+	// see WithTypeCheckConcurrency.
 	TypeCheck(ctx context.Context, mode TypecheckMode, ids ...PackageID) ([]Package, error)
 
+	// PackageCallGraph returns the call graph of the specified package,
+	// mapping each function or method with an objectpath to those of the
+	// same package that it calls directly. Unexported package-level
+	// functions have no objectpath and are omitted with their edges; see
+	// CallGraph for details.
+	PackageCallGraph(ctx context.Context, id PackageID) (map[objectpath.Path][]objectpath.Path, error)
+
+	// UnreachableFunctions returns the functions and methods of the
+	// specified package, in order of declaration, that are not reachable
+	// through calls within the package from its exported functions, entry
+	// points, or tests. See UnreachableFunctions for the conservative
+	// assumptions.
+	UnreachableFunctions(ctx context.Context, id PackageID) ([]*types.Func, error)
+
+	// CallersMatching returns, for each function and method of the workspace
+	// whose name matches re, the locations of its calls within the
+	// workspace, keyed by package path and objectpath.
+	CallersMatching(ctx context.Context, re *regexp.Regexp) (map[PackagePath]map[objectpath.Path][]protocol.Location, error)
+
+	// TestsReaching returns the test and benchmark functions, of the test
+	// packages of the package of the given file or of its importers, that
+	// may call the function or method enclosing the given position. The
+	// result is a static approximation, not runtime coverage: see
+	// TestsReaching for its assumptions.
+	TestsReaching(ctx context.Context, uri span.URI, pos protocol.Position) ([]TestFunc, error)
+
+	// SatisfiedInterfaces returns the sorted locations of the interfaces
+	// declared in workspace packages that are implemented by the type with the
+	// given objectpath in the specified package (or by a pointer to it).
+	SatisfiedInterfaces(ctx context.Context, id PackageID, typ objectpath.Path) ([]protocol.Location, error)
+
+	// WorkspaceInterfaces returns the package-level interface types declared
+	// by workspace packages, sorted by location.
+	WorkspaceInterfaces(ctx context.Context) ([]InterfaceDecl, error)
+
+	// MethodSet returns the methods of the method set of the named type
+	// denoted by typ in the specified package, including methods promoted
+	// through embedded fields, sorted by name. For a non-interface type T,
+	// the method set of *T is used, so that methods with pointer receivers
+	// are included.
+	MethodSet(ctx context.Context, id PackageID, typ objectpath.Path) ([]MethodInfo, error)
+
+	// TypesWithMethod returns the sorted objectpaths, grouped by package, of
+	// the non-interface named types declared in workspace packages that have
+	// a method, possibly with a pointer receiver or promoted through an
+	// embedded field, with the given name and signature. The signature, such
+	// as "func() string" or "() string", is evaluated in the scope of each
+	// package and compared structurally, so it may refer to predeclared and
+	// package-level types but not to imported ones. Packages in which the
+	// signature does not evaluate are skipped; it is an error only if it
+	// evaluates in none of them.
+	TypesWithMethod(ctx context.Context, methodName, signature string) (map[PackagePath][]objectpath.Path, error)
+
+	// PackageDoc returns the documentation of the exported declarations of
+	// the specified package, as computed by go/doc from its compiled Go
+	// files. Its positions are relative to FileSet().
+	PackageDoc(ctx context.Context, id PackageID) (*doc.Package, error)
+
+	// ExportedAPI returns the exported declarations of the specified
+	// package, including the exported methods and fields of its exported
+	// types, in a deterministic order.
+	ExportedAPI(ctx context.Context, id PackageID) ([]ExportedDecl, error)
+
+	// OrphanedTestFiles returns the _test.go files in the directories of
+	// workspace packages that cannot belong to any loadable package, each
+	// paired with the reason why.
+	OrphanedTestFiles(ctx context.Context) ([]OrphanedTestFile, error)
+
+	// OrphanedFiles returns the Go files in the directories of workspace
+	// packages, or of files known to the snapshot within the workspace
+	// folder, that do not belong to any loaded package, each paired with
+	// the probable reason why.
+	OrphanedFiles(ctx context.Context) ([]OrphanedFile, error)
+
+	// IsGenerated reports whether the Go file denoted by uri is generated,
+	// according to the convention described at
+	// https://golang.org/s/generatedcode.
+	IsGenerated(ctx context.Context, uri span.URI) (bool, error)
+
+	// GeneratedFiles returns the sorted URIs of the generated Go files of
+	// workspace packages, other than those in the module cache.
+	GeneratedFiles(ctx context.Context) ([]span.URI, error)
+
+	// FormatScope returns the sorted URIs of the files whose formatting may
+	// be affected by a change to the given file, including the file itself.
+	// For a Go file, these are the non-generated Go files of the packages
+	// containing it, as the imports that goimports adds or removes depend on
+	// the declarations and imports of the package's other files. For any
+	// other file, it is the file alone.
+	FormatScope(ctx context.Context, uri span.URI) ([]span.URI, error)
+
+	// SuppressedDiagnostics returns the diagnostics of the given file that
+	// are on lines marked by a suppression comment (see the
+	// SuppressionComments option), each tagged with SuppressedTag and
+	// related to its suppressing comment.
+	SuppressedDiagnostics(ctx context.Context, uri span.URI) ([]*Diagnostic, error)
+
+	// ShadowedImports returns hint diagnostics for the local declarations of
+	// the given file that shadow the name of an imported package within a
+	// function that uses that package.
+	ShadowedImports(ctx context.Context, uri span.URI) ([]*Diagnostic, error)
+
+	// DuplicateImports returns warnings for the import specs of the given
+	// file that import a path already imported by an earlier spec. Where
+	// removing the redundant spec cannot break the file, because it has the
+	// same name as the earlier one or is a blank import, the diagnostic
+	// carries a quick fix to remove it.
+	DuplicateImports(ctx context.Context, uri span.URI) ([]*Diagnostic, error)
+
+	// SpecialImports returns the blank (_) and dot (.) imports of the Go
+	// files of workspace packages, other than those in the module cache,
+	// keyed by file. Files without such imports are omitted.
+	SpecialImports(ctx context.Context) (map[span.URI][]SpecialImport, error)
+
+	// UnusedLocals returns warnings for the local variables of the given
+	// file whose value is updated (by x++ or x += y, for example) but never
+	// read. Variables that are only assigned are not reported, as the
+	// compiler reports them as declared and not used.
+	UnusedLocals(ctx context.Context, uri span.URI) ([]*Diagnostic, error)
+
+	// UnusedFields returns hints for the struct fields declared by the
+	// specified package that are never referenced, or written but never
+	// read, by it or the workspace packages that import it.
+	//
+	// The result is a heuristic: fields may be accessed through reflection,
+	// by packages outside the workspace, or implicitly by struct comparison
+	// or conversion. To limit false positives, fields with struct tags and
+	// embedded fields are never reported.
+	UnusedFields(ctx context.Context, id PackageID) ([]*Diagnostic, error)
+
+	// TaskComments returns the task comments, such as "TODO(name): ...",
+	// of the Go files of workspace packages and open Go files, excluding
+	// files in the module cache, keyed by file and in file order. A task
+	// comment is a comment line beginning with one of the given markers,
+	// or DefaultTaskMarkers if markers is empty.
+	TaskComments(ctx context.Context, markers []string) (map[span.URI][]TaskComment, error)
+
+	// DeprecatedUsages returns hints, tagged as deprecated, for the
+	// references in the given file to symbols of other packages whose doc
+	// comment contains a paragraph beginning with "Deprecated: ".
+	DeprecatedUsages(ctx context.Context, uri span.URI) ([]*Diagnostic, error)
+
+	// DefinitionOf returns the location of the declaration of the named
+	// package-level object of the package with the given path. The name
+	// may also have the form "T.M", denoting a method or field M of the
+	// package-level type T.
+	DefinitionOf(ctx context.Context, pkgPath PackagePath, name string) (protocol.Location, error)
+
+	// TypeAt type-checks the narrowest package containing the given file
+	// and returns the type of the innermost expression at the given
+	// position. It returns ErrNoExpression if the position is not within
+	// a typed expression.
+	TypeAt(ctx context.Context, uri span.URI, pos protocol.Position) (types.Type, error)
+
+	// ReceiverTypeAt type-checks the narrowest package containing the given
+	// file and returns the named type that declares the method of the
+	// innermost method call, method value, or method declaration enclosing
+	// the given position. It returns ErrNoMethod if there is none, or if
+	// the receiver type is not named.
+	ReceiverTypeAt(ctx context.Context, uri span.URI, pos protocol.Position) (*types.Named, error)
+
+	// DeclarationAt returns the symbol of the top-level declaration
+	// enclosing the start of the given location, or a symbol (of kind File)
+	// for the entire file if the location is not within a declaration.
+	DeclarationAt(ctx context.Context, loc protocol.Location) (*protocol.DocumentSymbol, error)
+
+	// AllModules returns the modules that provide the packages of the
+	// snapshot, including indirect dependencies, sorted by path.
+	AllModules(ctx context.Context) ([]*packages.Module, error)
+
+	// MissingRequirements returns the import paths referenced by workspace
+	// packages that are not provided by the main module or by any module
+	// required by an active go.mod file. Each is mapped to a representative
+	// file that imports it. Standard library imports are not reported, nor
+	// is anything reported outside of module mode.
+	MissingRequirements(ctx context.Context) (map[ImportPath]span.URI, error)
+
+	// AllFilesForPackage returns the sorted Go files of the package with the
+	// given path, together with those of its test variants and external test
+	// package.
+	AllFilesForPackage(ctx context.Context, pkgPath PackagePath) ([]span.URI, error)
+
+	// PackageNameConflicts reports the package names declared by the Go
+	// files of the given directory, if they declare more than one, in which
+	// case the go command cannot load the directory. The "_test" suffix of
+	// test files is disregarded.
+	PackageNameConflicts(ctx context.Context, dir span.URI) ([]PackageNameConflict, error)
+
+	// BuildTags returns the build tags referenced by the build constraints
+	// of the Go files in the directories of workspace packages, including
+	// files excluded by the current build configuration. Each tag is mapped
+	// to the sorted files that reference it.
+	BuildTags(ctx context.Context) (map[string][]span.URI, error)
+
+	// TypeCheckByPath is like TypeCheck, but identifies packages by their
+	// package path. If a path denotes more than one package (for example, a
+	// package and its test variant), it returns an *AmbiguousPackagePathError;
+	// use PackageForPath to select among the candidates.
+	TypeCheckByPath(ctx context.Context, mode TypecheckMode, paths ...PackagePath) ([]Package, error)
+
+	// ShadowingReplaces returns the replace directives of the go.mod files
+	// of go.work workspace members that replace another member with a
+	// different target, sorted by location. It returns nil outside of
+	// go.work workspaces.
+	ShadowingReplaces(ctx context.Context) ([]ReplaceConflict, error)
+
+	// ReplaceDowngrades returns hints for the replace directives of the
+	// given go.mod file that replace a required module with an older
+	// version of the same module, silently downgrading it.
+	ReplaceDowngrades(ctx context.Context, pm *ParsedModule) ([]*Diagnostic, error)
+
+	// ImportPathForDir returns the import path that the go command would
+	// assign to a package in the given directory, based on the active
+	// module or local replace target containing it. It returns an error if
+	// the directory is not within an active module.
+	ImportPathForDir(ctx context.Context, dir span.URI) (ImportPath, error)
+
+	// FilesImporting returns the sorted files of workspace packages that
+	// import the package with the given path.
+	FilesImporting(ctx context.Context, target PackagePath) ([]span.URI, error)
+
+	// PackagesWithoutTests returns the sorted IDs of the workspace packages
+	// that have no test files, neither in the package itself nor in an
+	// external test package.
+	PackagesWithoutTests(ctx context.Context) ([]PackageID, error)
+
+	// AffectedTests returns the sorted IDs of the test packages whose
+	// results may change as a result of a change to the specified package:
+	// the test variants (in-package and external) of every package that
+	// depends on it, directly or transitively, including the package itself.
+	// Intermediate test variants are traversed but not reported.
+	AffectedTests(ctx context.Context, changed PackageID) ([]PackageID, error)
+
+	// MinimalRequires returns the modules, sorted by path, that provide the
+	// packages transitively imported by the specified packages, other than
+	// the specified packages themselves. This is the require list needed by
+	// a new module containing just those packages. A module of the
+	// workspace appears with an empty version if its remaining packages are
+	// needed. Standard library packages are not included.
+	MinimalRequires(ctx context.Context, ids []PackageID) ([]module.Version, error)
+
+	// PackagesInModule returns the sorted IDs of the loaded packages that
+	// belong to the module with the given path, excluding test variants.
+	PackagesInModule(ctx context.Context, modulePath string) ([]PackageID, error)
+
+	// PackagesNeedingRequire returns the sorted IDs of the workspace
+	// packages that directly import a package provided by the module with
+	// the given path. It is the inverse of ModWhy, at package granularity.
+	PackagesNeedingRequire(ctx context.Context, modPath string) ([]PackageID, error)
+
+	// PackageCompiles type-checks the specified package and reports whether
+	// it is free of list, parse, and type errors.
+	PackageCompiles(ctx context.Context, id PackageID) (bool, error)
+
 	// GetCriticalError returns any critical errors in the workspace.
 	//
 	// A nil result may mean success, or context cancellation.
 	GetCriticalError(ctx context.Context) *CriticalError
 
+	// CacheStats reports the effectiveness of the caches of parsed files,
+	// type-checked packages, and analysis results.
+	CacheStats() CacheStats
+
 	// BuildGoplsMod generates a go.mod file for all modules in the workspace.
-	// It bypasses any existing gopls.mod.
+	// It bypasses any existing gopls.mod, and does not write the result.
 	BuildGoplsMod(ctx context.Context) (*modfile.File, error)
+
+	// DiffGoplsMod reports the replace directives that BuildGoplsMod would
+	// add to, or remove from, the existing gopls.mod file.
+	DiffGoplsMod(ctx context.Context) (added, removed []modfile.Replace, err error)
 }
 
 // SnapshotLabels returns a new slice of labels that should be used for events
@@ -255,6 +686,174 @@ func PackageForFile(ctx context.Context, snapshot Snapshot, uri span.URI, mode T
 	return pkg, pgf, err
 }
 
+// PackageForPath is a convenience function that type-checks the package
+// with the given package path. If the path denotes more than one package,
+// pkgSel determines which one is returned.
+func PackageForPath(ctx context.Context, snapshot Snapshot, path PackagePath, mode TypecheckMode, pkgSel PackageSelector) (Package, error) {
+	pkgs, err := snapshot.TypeCheckByPath(ctx, mode, path)
+	var ambiguous *AmbiguousPackagePathError
+	if errors.As(err, &ambiguous) {
+		var metas []*Metadata
+		for _, id := range ambiguous.IDs {
+			if m := snapshot.Metadata(id); m != nil {
+				metas = append(metas, m)
+			}
+		}
+		if len(metas) == 0 {
+			return nil, err
+		}
+		sort.Slice(metas, func(i, j int) bool {
+			return len(metas[i].CompiledGoFiles) < len(metas[j].CompiledGoFiles)
+		})
+		switch pkgSel {
+		case NarrowestPackage:
+			metas = metas[:1]
+		case WidestPackage:
+			metas = metas[len(metas)-1:]
+		}
+		pkgs, err = snapshot.TypeCheck(ctx, mode, metas[0].ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pkgs[0], nil
+}
+
+// An OrphanedTestFile is a _test.go file that does not belong to any
+// loadable package.
+type OrphanedTestFile struct {
+	URI    span.URI
+	Reason string // e.g. "no non-test Go files in /path/to/dir"
+}
+
+// An OrphanedFile is a Go file that does not belong to any loaded package.
+type OrphanedFile struct {
+	URI    span.URI
+	Reason string // e.g. "excluded by build constraint ignore"
+}
+
+// DefaultTaskMarkers are the markers of task comments reported by
+// Snapshot.TaskComments, if no others are specified.
+var DefaultTaskMarkers = []string{"TODO", "FIXME", "BUG"}
+
+// A TaskComment is a comment line beginning with a task marker.
+type TaskComment struct {
+	Marker string         // e.g. "TODO"
+	Text   string         // the comment line from the marker, e.g. "TODO(rfindley): fix"
+	Range  protocol.Range // the range of Text
+}
+
+// An ExportedDecl describes an exported declaration of a package.
+type ExportedDecl struct {
+	Path      objectpath.Path
+	Kind      string // one of "const", "var", "func", "type", "method", or "field"
+	Signature string // e.g. "func F(x int) error", qualified relative to the package
+}
+
+// An APIChange describes a difference in an exported declaration of a
+// package, as reported by ExportedAPIDiff.
+type APIChange struct {
+	Path     objectpath.Path
+	Change   string // one of "added", "removed", or "changed"
+	Old, New string // the signatures before and after the change, if any
+}
+
+// An InterfaceDecl describes the declaration of a package-level interface
+// type.
+type InterfaceDecl struct {
+	Package  PackagePath
+	Path     objectpath.Path
+	Location protocol.Location // the location of the type name
+	Methods  []string          // names of the methods, including embedded ones, sorted
+}
+
+// An ImportSuggestion is the import suggested by Snapshot.SuggestImports
+// for an identifier.
+type ImportSuggestion struct {
+	Path         ImportPath   // the best candidate
+	Name         string       // the explicit import name required, if any
+	Alternatives []ImportPath // other candidates, best first
+}
+
+// A MethodInfo describes a method of a method set.
+type MethodInfo struct {
+	Name      string
+	Signature string            // e.g. "func (*T) M(x int) error", qualified relative to the package
+	Location  protocol.Location // the method's name in its declaration; zero if unavailable
+	Promoted  bool              // the method is promoted through an embedded field
+}
+
+// A TestFunc is a test or benchmark function.
+type TestFunc struct {
+	Name     string
+	Package  PackagePath       // the package path of the test package
+	Location protocol.Location // the function's name in its declaration
+}
+
+// A SpecialImport is a blank or dot import.
+type SpecialImport struct {
+	Dot   bool           // a dot import; otherwise a blank import
+	Path  ImportPath     // the imported path
+	Range protocol.Range // the range of the import spec
+}
+
+// A ModuleNode is a node of the tree returned by
+// Snapshot.ModuleDependencyTree.
+//
+// A module required along several paths is represented by a single node,
+// so the tree is really a directed acyclic graph. Requirement cycles, which
+// MVS permits, are broken by representing the requirement that closes the
+// cycle as a distinct leaf node with Cycle set.
+type ModuleNode struct {
+	Module   module.Version  // the selected version; the main module has none
+	Replace  *module.Version // the replacement, if the module is replaced
+	Indirect bool            // the module is an indirect requirement of the main module
+	Cycle    bool            // the requirement closes a cycle; Children is empty
+	Children []*ModuleNode   // the requirements of the module, sorted by path
+}
+
+// A PackageNameConflict is a package name declared by some of the Go files
+// of a directory whose files declare more than one package name.
+type PackageNameConflict struct {
+	Name  PackageName
+	Files []protocol.Location // locations of the package names, sorted
+}
+
+// A ReplaceConflict is a replace directive of a go.work workspace member's
+// go.mod file that replaces another member module with something other
+// than that module's directory.
+type ReplaceConflict struct {
+	ModulePath string            // the path of the replaced member module
+	Replace    protocol.Location // the replace directive
+	Use        protocol.Location // the go.work use directive of the member
+}
+
+// CacheStats reports the hits and misses of the caches of a view's
+// snapshots, over the lifetime of the view, and the number of entries in the
+// caches of a particular snapshot.
+type CacheStats struct {
+	ParseHits, ParseMisses         int64
+	TypeCheckHits, TypeCheckMisses int64
+	AnalysisHits, AnalysisMisses   int64
+
+	ParseEntries, TypeCheckEntries, AnalysisEntries int
+}
+
+// An AmbiguousPackagePathError is returned by Snapshot.TypeCheckByPath when a
+// package path denotes more than one package.
+type AmbiguousPackagePathError struct {
+	Path PackagePath
+	IDs  []PackageID // candidate packages, sorted
+}
+
+func (e *AmbiguousPackagePathError) Error() string {
+	ids := make([]string, len(e.IDs))
+	for i, id := range e.IDs {
+		ids[i] = string(id)
+	}
+	return fmt.Sprintf("package path %q is ambiguous: it matches packages %s", e.Path, strings.Join(ids, ", "))
+}
+
 // PackageSelector sets how a package is selected out from a set of packages
 // containing a given file.
 type PackageSelector int
@@ -358,6 +957,14 @@ type View interface {
 	// GoVersionString returns the go version string configured for this view.
 	// Unlike [GoVersion], this encodes the minor version and commit hash information.
 	GoVersionString() string
+
+	// GOPATH returns the entries of the GOPATH in use for this view, as
+	// reported by "go env GOPATH", in order. Empty entries are omitted.
+	GOPATH() []string
+
+	// WatchPatterns returns the glob patterns that must be watched to observe
+	// changes to files known to the view's current snapshot.
+	WatchPatterns(ctx context.Context) map[string]struct{}
 }
 
 // A FileSource maps uris to FileHandles. This abstraction exists both for
@@ -404,6 +1011,91 @@ func (pgf *ParsedGoFile) NodeRange(node ast.Node) (protocol.Range, error) {
 	return pgf.Mapper.NodeRange(pgf.Tok, node)
 }
 
+// PackageNameRange returns the range of the package name in the package
+// clause of this file, which may be preceded by comments such as build
+// constraints.
+func (pgf *ParsedGoFile) PackageNameRange() (protocol.Range, error) {
+	if pgf.File.Name == nil || !pgf.File.Name.Pos().IsValid() {
+		return protocol.Range{}, fmt.Errorf("%s has no package clause", pgf.URI.Filename())
+	}
+	return pgf.NodeRange(pgf.File.Name)
+}
+
+// EnclosingStatement returns the innermost statement of a statement list
+// (a block, or the body of a case or select clause) that encloses the
+// interval [start, end), along with its index within that list.
+// Case and select clauses themselves are never returned.
+func (pgf *ParsedGoFile) EnclosingStatement(start, end token.Pos) (ast.Stmt, int, error) {
+	path, _ := astutil.PathEnclosingInterval(pgf.File, start, end)
+	for i := 0; i+1 < len(path); i++ {
+		stmt, ok := path[i].(ast.Stmt)
+		if !ok {
+			continue
+		}
+		var list []ast.Stmt
+		switch parent := path[i+1].(type) {
+		case *ast.BlockStmt:
+			list = parent.List
+		case *ast.CaseClause:
+			list = parent.Body
+		case *ast.CommClause:
+			list = parent.Body
+		}
+		for j, s := range list {
+			if s == stmt {
+				return stmt, j, nil
+			}
+		}
+	}
+	return nil, 0, fmt.Errorf("no statement encloses the selection")
+}
+
+// EnclosingTypeSpec returns the innermost type specification, such as the
+// "T struct{...}" of "type T struct{...}", that encloses pos. It returns
+// ErrNoTypeSpec if there is none.
+func (pgf *ParsedGoFile) EnclosingTypeSpec(pos token.Pos) (*ast.TypeSpec, error) {
+	path, _ := astutil.PathEnclosingInterval(pgf.File, pos, pos)
+	for _, n := range path {
+		if spec, ok := n.(*ast.TypeSpec); ok {
+			return spec, nil
+		}
+	}
+	return nil, ErrNoTypeSpec
+}
+
+// ReturnStatements returns the return statements of the function fn, a
+// *ast.FuncDecl or *ast.FuncLit of the file, in source order. Return
+// statements within nested function literals belong to those literals, and
+// are not included. A function without a body has no return statements.
+func (pgf *ParsedGoFile) ReturnStatements(fn ast.Node) ([]*ast.ReturnStmt, error) {
+	var body *ast.BlockStmt
+	switch fn := fn.(type) {
+	case *ast.FuncDecl:
+		body = fn.Body
+	case *ast.FuncLit:
+		body = fn.Body
+	default:
+		return nil, fmt.Errorf("%T is not a function", fn)
+	}
+	if fn.Pos() < pgf.File.Pos() || fn.End() > pgf.File.End() {
+		return nil, fmt.Errorf("function is not in %s", pgf.URI)
+	}
+	if body == nil {
+		return nil, nil
+	}
+	var returns []*ast.ReturnStmt
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.ReturnStmt:
+			returns = append(returns, n)
+		}
+		return true
+	})
+	return returns, nil
+}
+
 // PosMappedRange returns a MappedRange for the token.Pos interval in this file.
 // A MappedRange can be converted to any other form.
 func (pgf *ParsedGoFile) PosMappedRange(startPos, endPos token.Pos) (protocol.MappedRange, error) {
@@ -423,6 +1115,93 @@ func (pgf *ParsedGoFile) RangeToTokenRange(r protocol.Range) (safetoken.Range, e
 	return safetoken.NewRange(pgf.Tok, pgf.Tok.Pos(start), pgf.Tok.Pos(end)), nil
 }
 
+// BuildConstraints returns the build constraint of the file, or nil if it
+// has none.
+//
+// As with the go command, only comments preceding the package clause are
+// considered, and "// +build" lines are ignored unless they are followed by
+// a blank line. A "//go:build" line takes precedence over "// +build" lines;
+// multiple "// +build" lines are combined with &&.
+func (pgf *ParsedGoFile) BuildConstraints() (constraint.Expr, error) {
+	var (
+		goBuild  constraint.Expr
+		plusExpr constraint.Expr
+	)
+	for _, cg := range pgf.File.Comments {
+		if cg.Pos() > pgf.File.Package {
+			break
+		}
+		// The package doc comment is not followed by a blank line.
+		isDoc := cg == pgf.File.Doc
+		for _, c := range cg.List {
+			switch {
+			case constraint.IsGoBuild(c.Text):
+				if goBuild != nil {
+					return nil, fmt.Errorf("%s: multiple //go:build comments", pgf.URI.Filename())
+				}
+				x, err := constraint.Parse(c.Text)
+				if err != nil {
+					return nil, err
+				}
+				goBuild = x
+
+			case constraint.IsPlusBuild(c.Text) && !isDoc:
+				x, err := constraint.Parse(c.Text)
+				if err != nil {
+					return nil, err
+				}
+				if plusExpr == nil {
+					plusExpr = x
+				} else {
+					plusExpr = &constraint.AndExpr{X: plusExpr, Y: x}
+				}
+			}
+		}
+	}
+	if goBuild != nil {
+		return goBuild, nil
+	}
+	return plusExpr, nil
+}
+
+// EnclosingBlock returns the innermost block statement enclosing the
+// interval [start, end) of the file.
+//
+// It returns an error if the interval is not within a block, such as when
+// it spans multiple top-level declarations, or if it partially overlaps a
+// statement of that block, as when it crosses the boundary of a nested
+// block.
+func (pgf *ParsedGoFile) EnclosingBlock(start, end token.Pos) (*ast.BlockStmt, error) {
+	path, _ := astutil.PathEnclosingInterval(pgf.File, start, end)
+	if len(path) < 2 {
+		// The innermost enclosing node is the file itself.
+		return nil, fmt.Errorf("selection is not within a single declaration")
+	}
+	var block *ast.BlockStmt
+	for _, n := range path {
+		if b, ok := n.(*ast.BlockStmt); ok {
+			block = b
+			break
+		}
+	}
+	if block == nil {
+		return nil, fmt.Errorf("selection is not within a block")
+	}
+	for _, stmt := range block.List {
+		if stmt.End() <= start || end <= stmt.Pos() {
+			continue // disjoint
+		}
+		if start <= stmt.Pos() && stmt.End() <= end {
+			continue // statement within selection
+		}
+		if stmt.Pos() <= start && end <= stmt.End() {
+			continue // selection within statement
+		}
+		return nil, fmt.Errorf("selection partially overlaps a statement")
+	}
+	return block, nil
+}
+
 // A ParsedModule contains the results of parsing a go.mod file.
 type ParsedModule struct {
 	URI         span.URI
@@ -513,6 +1292,22 @@ func RemoveIntermediateTestVariants(metas []*Metadata) []*Metadata {
 
 var ErrViewExists = errors.New("view already exists for session")
 
+// ErrNoExpression is returned by Snapshot.TypeAt when the position is not
+// within a typed expression.
+var ErrNoExpression = errors.New("no expression found")
+
+// ErrNoMethod is returned by Snapshot.ReceiverTypeAt when the position is
+// not within a method call, method value, or method declaration.
+var ErrNoMethod = errors.New("no method found")
+
+// ErrNoModule is returned by Snapshot.ModulePathForFile when the file is not
+// contained in any active module.
+var ErrNoModule = errors.New("file is not in a module")
+
+// ErrNoTypeSpec is returned by ParsedGoFile.EnclosingTypeSpec when the
+// position is not within a type declaration.
+var ErrNoTypeSpec = errors.New("no type declaration found")
+
 // Overlay is the type for a file held in memory on a session.
 type Overlay interface {
 	Kind() FileKind
@@ -613,6 +1408,30 @@ const (
 	TypecheckWorkspace
 )
 
+type typeCheckConcurrencyKey struct{}
+
+// WithTypeCheckConcurrency returns a context with which Snapshot.TypeCheck
+// awaits at most n of the requested packages in parallel. Background
+// operations, such as workspace-wide diagnostics, may use it to avoid
+// starving interactive requests.
+//
+// Since type-checking results are shared between requests, the bound does
+// not apply to the dependencies of the requested packages. The
+// TypeCheckConcurrency option bounds all type-checking of a view.
+func WithTypeCheckConcurrency(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, typeCheckConcurrencyKey{}, n)
+}
+
+// TypeCheckConcurrency returns the bound set by WithTypeCheckConcurrency on
+// the number of packages awaited in parallel by Snapshot.TypeCheck calls
+// made with ctx, or zero if there is none.
+func TypeCheckConcurrency(ctx context.Context) int {
+	if n, ok := ctx.Value(typeCheckConcurrencyKey{}).(int); ok && n > 0 {
+		return n
+	}
+	return 0
+}
+
 type VersionedFileHandle interface {
 	FileHandle
 	Version() int32
@@ -792,8 +1611,9 @@ type Package interface {
 	ResolveImportPath(path ImportPath) (Package, error)
 	Imports() []Package // new slice of all direct dependencies, unordered
 	HasTypeErrors() bool
-	DiagnosticsForFile(uri span.URI) []*Diagnostic                 // new array of list/parse/type errors
-	ReferencesTo(PackagePath, objectpath.Path) []protocol.Location // new sorted array of xrefs
+	DiagnosticsForFile(uri span.URI) []*Diagnostic                               // new array of list/parse/type errors
+	DiagnosticsForFileBySource(uri span.URI, src DiagnosticSource) []*Diagnostic // as above, but only from src
+	ReferencesTo(PackagePath, objectpath.Path) []protocol.Location               // new sorted array of xrefs
 }
 
 // A CriticalError is a workspace-wide error that generally prevents gopls from
@@ -807,6 +1627,79 @@ type CriticalError struct {
 	Diagnostics []*Diagnostic
 }
 
+// A MissingGoSumError is the MainError of a CriticalError caused by a
+// go command failure due to missing go.sum entries.
+type MissingGoSumError struct {
+	// Modules holds the modules identified by the go command as lacking
+	// go.sum entries. Versions may be empty.
+	Modules []module.Version
+
+	// Packages holds the packages whose providing module lacks go.sum
+	// entries, when the go command does not identify the module.
+	Packages []string
+
+	// Importers holds, for each element of Packages, the package that
+	// imports it, or "" if unknown.
+	Importers []string
+
+	Err error // the go command error
+}
+
+func (e *MissingGoSumError) Error() string {
+	var missing []string
+	for _, m := range e.Modules {
+		if m.Version != "" {
+			missing = append(missing, m.Path+"@"+m.Version)
+		} else {
+			missing = append(missing, m.Path)
+		}
+	}
+	for _, pkg := range e.Packages {
+		missing = append(missing, "module providing "+pkg)
+	}
+	if len(missing) == 0 {
+		return fmt.Sprintf("go.sum is missing entries; run %q to add them", strings.Join(e.FixCommand(), " "))
+	}
+	return fmt.Sprintf("go.sum is missing entries for %s; run %q to add them", strings.Join(missing, ", "), strings.Join(e.FixCommand(), " "))
+}
+
+func (e *MissingGoSumError) Unwrap() error { return e.Err }
+
+// FixCommand returns the go command that adds the missing go.sum entries,
+// following the suggestions of the go command: "go mod download" for the
+// modules it identifies, and "go get" for the importers of packages whose
+// module it does not. Since Go 1.17, "go mod download" without arguments
+// no longer adds go.sum entries for the packages of the build, so
+// "go mod tidy" is suggested if an importer is unknown.
+func (e *MissingGoSumError) FixCommand() []string {
+	if len(e.Packages) == 0 {
+		if len(e.Modules) == 0 {
+			return []string{"go", "mod", "tidy"}
+		}
+		args := []string{"go", "mod", "download"}
+		for _, m := range e.Modules {
+			args = append(args, m.Path)
+		}
+		return args
+	}
+	args := []string{"go", "get"}
+	seen := make(map[string]bool)
+	for i := range e.Packages {
+		importer := ""
+		if i < len(e.Importers) {
+			importer = e.Importers[i]
+		}
+		if importer == "" {
+			return []string{"go", "mod", "tidy"}
+		}
+		if !seen[importer] {
+			seen[importer] = true
+			args = append(args, importer)
+		}
+	}
+	return args
+}
+
 // An Diagnostic corresponds to an LSP Diagnostic.
 // https://microsoft.github.io/language-server-protocol/specification#diagnostic
 type Diagnostic struct {
@@ -842,6 +1735,13 @@ const (
 	ParseError               DiagnosticSource = "syntax"
 	TypeError                DiagnosticSource = "compiler"
 	ModTidyError             DiagnosticSource = "go mod tidy"
+	VetError                 DiagnosticSource = "go vet"
+	ShadowedImport           DiagnosticSource = "shadowed import"
+	DuplicateImport          DiagnosticSource = "duplicate import"
+	UnusedLocal              DiagnosticSource = "unused local"
+	UnusedField              DiagnosticSource = "unused field"
+	Deprecation              DiagnosticSource = "deprecation"
+	ReplaceDowngrade         DiagnosticSource = "replace downgrade"
 	OptimizationDetailsError DiagnosticSource = "optimizer details"
 	UpgradeNotification      DiagnosticSource = "upgrade available"
 	Vulncheck                DiagnosticSource = "govulncheck"
@@ -849,6 +1749,11 @@ const (
 	WorkFileError            DiagnosticSource = "go.work file"
 )
 
+// SuppressedTag is the tag of the diagnostics returned by
+// Snapshot.SuppressedDiagnostics. It is not part of the LSP protocol, so
+// diagnostics with this tag must not be published to clients.
+const SuppressedTag protocol.DiagnosticTag = 1000
+
 func AnalyzerErrorKind(name string) DiagnosticSource {
 	return DiagnosticSource(name)
 }
@@ -0,0 +1,321 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/span"
+)
+
+func TestBuildConstraints(t *testing.T) {
+	tests := []struct {
+		src     string
+		want    string // "" => no constraint
+		wantErr bool
+	}{
+		{"package p", "", false},
+		{"//go:build linux && amd64\n\npackage p", "linux && amd64", false},
+		{"// +build linux darwin\n// +build amd64\n\npackage p", "(linux || darwin) && amd64", false},
+		{"//go:build linux\n// +build darwin\n\npackage p", "linux", false},
+		{"// +build linux\npackage p", "", false}, // not followed by a blank line
+		{"package p\n\n//go:build linux\n", "", false},
+		{"//go:build linux\n//go:build darwin\n\npackage p", "", true},
+		{"//go:build (linux\n\npackage p", "", true},
+	}
+	for _, test := range tests {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "p.go", test.src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pgf := &ParsedGoFile{File: f, Tok: fset.File(f.Pos())}
+		x, err := pgf.BuildConstraints()
+		if (err != nil) != test.wantErr {
+			t.Errorf("BuildConstraints(%q) returned error %v, want error: %t", test.src, err, test.wantErr)
+			continue
+		}
+		got := ""
+		if x != nil {
+			got = x.String()
+		}
+		if got != test.want {
+			t.Errorf("BuildConstraints(%q) = %q, want %q", test.src, got, test.want)
+		}
+	}
+}
+
+func TestEnclosingBlock(t *testing.T) {
+	const src = `package p
+
+func f() {
+	a := 1
+	if a > 0 {
+		a++
+		a--
+	}
+	_ = a
+}
+
+func g() {}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pgf := &ParsedGoFile{File: f, Tok: fset.File(f.Pos())}
+	pos := func(substr string) token.Pos {
+		i := strings.Index(src, substr)
+		if i < 0 {
+			t.Fatalf("%q not found", substr)
+		}
+		return pgf.Tok.Pos(i)
+	}
+
+	tests := []struct {
+		name       string
+		start, end token.Pos
+		wantLine   int // line of the block's opening brace, or 0 for an error
+	}{
+		{"statements of function body", pos("a := 1"), pos("_ = a"), 3},
+		{"statements of nested block", pos("a++"), pos("a--") + 3, 5},
+		{"expression", pos("a > 0"), pos("a > 0") + 5, 3},
+		{"crosses nested block", pos("a--"), pos("_ = a") + 5, 0},
+		{"multiple declarations", pos("_ = a"), pos("g()"), 0},
+	}
+	for _, test := range tests {
+		block, err := pgf.EnclosingBlock(test.start, test.end)
+		if test.wantLine == 0 {
+			if err == nil {
+				t.Errorf("%s: EnclosingBlock succeeded unexpectedly", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: EnclosingBlock failed: %v", test.name, err)
+			continue
+		}
+		if got := pgf.Tok.Line(block.Lbrace); got != test.wantLine {
+			t.Errorf("%s: EnclosingBlock returned block at line %d, want %d", test.name, got, test.wantLine)
+		}
+	}
+}
+
+func TestIsGenerated(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{"// Code generated by stringer; DO NOT EDIT.\n\npackage p", true},
+		{"// Copyright 2023.\n\n// Code generated by hand. DO NOT EDIT.\npackage p", true},
+		{"package p\n\n// Code generated by stringer; DO NOT EDIT.\n", false},
+		{"// Package p is not generated.\npackage p", false},
+	}
+	for _, test := range tests {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "p.go", test.src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pgf := &ParsedGoFile{File: f, Tok: fset.File(f.Pos())}
+		if got := pgf.IsGenerated(); got != test.want {
+			t.Errorf("IsGenerated(%q) = %t, want %t", test.src, got, test.want)
+		}
+	}
+}
+
+func TestPackageNameRange(t *testing.T) {
+	const src = `// Copyright notice.
+
+//go:build linux
+
+// Package foo does things.
+package foo
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "foo.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uri := span.URIFromPath("/foo.go")
+	pgf := &ParsedGoFile{URI: uri, File: f, Tok: fset.File(f.Pos()), Mapper: protocol.NewMapper(uri, []byte(src))}
+	got, err := pgf.PackageNameRange()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := protocol.Range{
+		Start: protocol.Position{Line: 5, Character: 8},
+		End:   protocol.Position{Line: 5, Character: 11},
+	}
+	if got != want {
+		t.Errorf("PackageNameRange() = %v, want %v", got, want)
+	}
+}
+
+func TestEnclosingStatement(t *testing.T) {
+	const src = `package p
+
+func f(x int) int {
+	y := x + 1
+	if y > 2 {
+		y *= 2
+		return y
+	}
+	switch y {
+	case 1:
+		x++
+		y--
+	}
+	return x
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pgf := &ParsedGoFile{File: f, Tok: fset.File(f.Pos())}
+	tests := []struct {
+		sel       string // selected text, which must occur exactly once in src
+		wantStmt  string // prefix of the statement
+		wantIndex int
+	}{
+		{"x + 1", "y := x + 1", 0},
+		{"y *= 2", "y *= 2", 0},
+		{"return y", "return y", 1},
+		{"y > 2", "if y > 2", 1},
+		{"y--", "y--", 1},
+		{"return x", "return x", 3},
+	}
+	for _, test := range tests {
+		offset := strings.Index(src, test.sel)
+		start := pgf.Tok.Pos(offset)
+		stmt, index, err := pgf.EnclosingStatement(start, start+token.Pos(len(test.sel)))
+		if err != nil {
+			t.Errorf("EnclosingStatement(%q) failed: %v", test.sel, err)
+			continue
+		}
+		got := src[pgf.Tok.Offset(stmt.Pos()):pgf.Tok.Offset(stmt.End())]
+		if !strings.HasPrefix(got, test.wantStmt) || index != test.wantIndex {
+			t.Errorf("EnclosingStatement(%q) = (%q, %d), want (%q..., %d)", test.sel, got, index, test.wantStmt, test.wantIndex)
+		}
+	}
+
+	// A selection of a function's parameters is not within any statement.
+	offset := strings.Index(src, "x int")
+	if _, _, err := pgf.EnclosingStatement(pgf.Tok.Pos(offset), pgf.Tok.Pos(offset+len("x int"))); err == nil {
+		t.Errorf("EnclosingStatement(parameters) succeeded unexpectedly")
+	}
+}
+
+func TestReturnStatements(t *testing.T) {
+	const src = `package p
+
+func f(x int) (int, error) {
+	if x > 0 {
+		for {
+			return x, nil
+		}
+	}
+	g := func() int {
+		return 0
+	}
+	switch {
+	case x < 0:
+		return g(), nil
+	}
+	return 1, nil
+}
+
+func h()
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pgf := &ParsedGoFile{File: f, Tok: fset.File(f.Pos())}
+	text := func(n ast.Node) string {
+		return src[pgf.Tok.Offset(n.Pos()):pgf.Tok.Offset(n.End())]
+	}
+
+	fdecl := f.Decls[0].(*ast.FuncDecl)
+	returns, err := pgf.ReturnStatements(fdecl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, ret := range returns {
+		got = append(got, text(ret))
+	}
+	if want := []string{"return x, nil", "return g(), nil", "return 1, nil"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ReturnStatements(f) = %q, want %q", got, want)
+	}
+
+	lit := fdecl.Body.List[1].(*ast.AssignStmt).Rhs[0].(*ast.FuncLit)
+	returns, err = pgf.ReturnStatements(lit)
+	if err != nil || len(returns) != 1 || text(returns[0]) != "return 0" {
+		t.Errorf("ReturnStatements(func literal) = %v, %v, want [return 0]", returns, err)
+	}
+
+	if returns, err := pgf.ReturnStatements(f.Decls[1]); err != nil || len(returns) != 0 {
+		t.Errorf("ReturnStatements(h) = %v, %v, want none", returns, err)
+	}
+	if _, err := pgf.ReturnStatements(fdecl.Body); err == nil {
+		t.Errorf("ReturnStatements(block) succeeded unexpectedly")
+	}
+}
+
+func TestEnclosingTypeSpec(t *testing.T) {
+	const src = `package p
+
+type (
+	A int
+	B struct {
+		x int
+	}
+)
+
+func f() {
+	type C []string
+	_ = C{}
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pgf := &ParsedGoFile{File: f, Tok: fset.File(f.Pos())}
+	for _, test := range []struct {
+		at   string // text that must occur exactly once in src
+		want string // name of the enclosing type, or "" for none
+	}{
+		{"A int", "A"},
+		{"x int", "B"},
+		{"[]string", "C"},
+		{"_ = C{}", ""},
+		{"func f", ""},
+	} {
+		pos := pgf.Tok.Pos(strings.Index(src, test.at))
+		spec, err := pgf.EnclosingTypeSpec(pos)
+		if test.want == "" {
+			if err != ErrNoTypeSpec {
+				t.Errorf("EnclosingTypeSpec(%q) = %v, %v, want ErrNoTypeSpec", test.at, spec, err)
+			}
+			continue
+		}
+		if err != nil || spec.Name.Name != test.want {
+			t.Errorf("EnclosingTypeSpec(%q) = %v, %v, want %s", test.at, spec, err, test.want)
+		}
+	}
+}
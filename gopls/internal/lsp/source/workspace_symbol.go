@@ -59,19 +59,69 @@ func WorkspaceSymbols(ctx context.Context, matcher SymbolMatcher, style SymbolSt
 		return nil, nil
 	}
 
-	var s symbolizer
+	return collectSymbols(ctx, views, matcher, symbolizerFor(style), query)
+}
+
+// A SymbolMatch is a symbol matching a query, as returned by MatchSymbols.
+type SymbolMatch struct {
+	Name      string // the symbol, formatted according to the SymbolStyle
+	Kind      protocol.SymbolKind
+	Container string // the package path
+	Location  protocol.Location
+	Score     float64 // higher is better
+}
+
+// MatchSymbols returns at most limit symbols of the snapshot's files that
+// match the query, using the matcher and symbol style configured for the
+// snapshot's view, in decreasing order of score. Unlike WorkspaceSymbols,
+// it considers only a single snapshot.
+func MatchSymbols(ctx context.Context, snapshot Snapshot, query string, limit int) ([]SymbolMatch, error) {
+	ctx, done := event.Start(ctx, "source.MatchSymbols")
+	defer done()
+	if query == "" || limit <= 0 {
+		return nil, nil
+	}
+
+	opts := snapshot.View().Options()
+	folder := snapshot.View().Folder()
+	work, err := symbolFiles(ctx, snapshot, folder, opts.DirectoryFilters, nil)
+	if err != nil {
+		return nil, err
+	}
+	roots := []string{strings.TrimRight(string(folder), "/")}
+	store := matchSymbols(work, roots, opts.SymbolMatcher, symbolizerFor(opts.SymbolStyle), query, limit)
+
+	var matches []SymbolMatch
+	for _, si := range store.res {
+		if si.score <= 0 {
+			break
+		}
+		matches = append(matches, SymbolMatch{
+			Name:      si.symbol,
+			Kind:      si.kind,
+			Container: si.container,
+			Location: protocol.Location{
+				URI:   protocol.URIFromSpanURI(si.uri),
+				Range: si.rng,
+			},
+			Score: si.score,
+		})
+	}
+	return matches, nil
+}
+
+// symbolizerFor returns the symbolizer for the given symbol style.
+func symbolizerFor(style SymbolStyle) symbolizer {
 	switch style {
 	case DynamicSymbols:
-		s = dynamicSymbolMatch
+		return dynamicSymbolMatch
 	case FullyQualifiedSymbols:
-		s = fullyQualifiedSymbolMatch
+		return fullyQualifiedSymbolMatch
 	case PackageQualifiedSymbols:
-		s = packageSymbolMatch
+		return packageSymbolMatch
 	default:
 		panic(fmt.Errorf("unknown symbol style: %v", style))
 	}
-
-	return collectSymbols(ctx, views, matcher, s, query)
 }
 
 // A matcherFunc returns the index and score of a symbol match.
@@ -312,33 +362,54 @@ func collectSymbols(ctx context.Context, views []View, matcherType SymbolMatcher
 		// whether a URI is in any open workspace.
 		roots = append(roots, strings.TrimRight(string(v.Folder()), "/"))
 
-		filters := v.Options().DirectoryFilters
-		filterer := NewFilterer(filters)
-		folder := filepath.ToSlash(v.Folder().Filename())
-		for uri, syms := range snapshot.Symbols(ctx) {
-			norm := filepath.ToSlash(uri.Filename())
-			nm := strings.TrimPrefix(norm, folder)
-			if filterer.Disallow(nm) {
-				continue
-			}
-			// Only scan each file once.
-			if seen[uri] {
-				continue
-			}
-			mds, err := snapshot.MetadataForFile(ctx, uri)
-			if err != nil {
-				event.Error(ctx, fmt.Sprintf("missing metadata for %q", uri), err)
-				continue
-			}
-			if len(mds) == 0 {
-				// TODO: should use the bug reporting API
-				continue
-			}
+		files, err := symbolFiles(ctx, snapshot, v.Folder(), v.Options().DirectoryFilters, seen)
+		if err != nil {
+			return nil, err
+		}
+		work = append(work, files...)
+	}
+
+	return matchSymbols(work, roots, matcherType, symbolizer, query, maxSymbols).results(), nil
+}
+
+// symbolFiles returns the files of the snapshot that are not excluded by
+// the directory filters relative to folder, together with their symbols.
+// Files in seen, if non-nil, are skipped; the returned files are added to
+// it.
+func symbolFiles(ctx context.Context, snapshot Snapshot, folder span.URI, filters []string, seen map[span.URI]bool) ([]symbolFile, error) {
+	var work []symbolFile
+	filterer := NewFilterer(filters)
+	dir := filepath.ToSlash(folder.Filename())
+	for uri, syms := range snapshot.Symbols(ctx) {
+		norm := filepath.ToSlash(uri.Filename())
+		nm := strings.TrimPrefix(norm, dir)
+		if filterer.Disallow(nm) {
+			continue
+		}
+		// Only scan each file once.
+		if seen[uri] {
+			continue
+		}
+		mds, err := snapshot.MetadataForFile(ctx, uri)
+		if err != nil {
+			event.Error(ctx, fmt.Sprintf("missing metadata for %q", uri), err)
+			continue
+		}
+		if len(mds) == 0 {
+			// TODO: should use the bug reporting API
+			continue
+		}
+		if seen != nil {
 			seen[uri] = true
-			work = append(work, symbolFile{uri, mds[0], syms})
 		}
+		work = append(work, symbolFile{uri, mds[0], syms})
 	}
+	return work, nil
+}
 
+// matchSymbols matches the symbols of the given files against the query,
+// and returns a store of the best limit matches.
+func matchSymbols(work []symbolFile, roots []string, matcherType SymbolMatcher, symbolizer symbolizer, query string, limit int) *symbolStore {
 	// Match symbols in parallel.
 	// Each worker has its own symbolStore,
 	// which we merge at the end.
@@ -347,7 +418,7 @@ func collectSymbols(ctx context.Context, views []View, matcherType SymbolMatcher
 	for i := 0; i < nmatchers; i++ {
 		go func(i int) {
 			matcher := buildMatcher(matcherType, query)
-			store := new(symbolStore)
+			store := newSymbolStore(limit)
 			// Assign files to workers in round-robin fashion.
 			for j := i; j < len(work); j += nmatchers {
 				matchFile(store, symbolizer, matcher, roots, work[j])
@@ -357,14 +428,14 @@ func collectSymbols(ctx context.Context, views []View, matcherType SymbolMatcher
 	}
 
 	// Gather and merge results as they arrive.
-	var unified symbolStore
+	unified := newSymbolStore(limit)
 	for i := 0; i < nmatchers; i++ {
 		store := <-results
 		for _, syms := range store.res {
 			unified.store(syms)
 		}
 	}
-	return unified.results(), nil
+	return unified
 }
 
 type Filterer struct {
@@ -372,6 +443,7 @@ type Filterer struct {
 	// Slices filters and excluded then should have the same length.
 	filters  []*regexp.Regexp
 	excluded []bool
+	raw      []string // the raw filters, for reporting
 }
 
 // NewFilterer computes regular expression form of all raw filters
@@ -385,6 +457,7 @@ func NewFilterer(rawFilters []string) *Filterer {
 		// For example, it prevents [+foobar, -foo] from excluding "foobar".
 		f.filters = append(f.filters, convertFilterToRegexp(filepath.ToSlash(prefix)))
 		f.excluded = append(f.excluded, op == '-')
+		f.raw = append(f.raw, filter)
 	}
 
 	return &f
@@ -392,6 +465,14 @@ func NewFilterer(rawFilters []string) *Filterer {
 
 // Disallow return true if the path is excluded from the filterer's filters.
 func (f *Filterer) Disallow(path string) bool {
+	_, excluded := f.Match(path)
+	return excluded
+}
+
+// Match returns the last of the filterer's filters that matches path, as
+// written (but cleaned), and whether it excludes path. It returns ("",
+// false) if no filter matches.
+func (f *Filterer) Match(path string) (filter string, excluded bool) {
 	// Ensure trailing but not leading slash.
 	path = strings.TrimPrefix(path, "/")
 	if !strings.HasSuffix(path, "/") {
@@ -399,13 +480,12 @@ func (f *Filterer) Disallow(path string) bool {
 	}
 
 	// TODO(adonovan): opt: iterate in reverse and break at first match.
-	excluded := false
-	for i, filter := range f.filters {
-		if filter.MatchString(path) {
-			excluded = f.excluded[i] // last match wins
+	for i, re := range f.filters {
+		if re.MatchString(path) {
+			filter, excluded = f.raw[i], f.excluded[i] // last match wins
 		}
 	}
-	return excluded
+	return filter, excluded
 }
 
 // convertFilterToRegexp replaces glob-like operator substrings in a string file path to their equivalent regex forms.
@@ -533,7 +613,12 @@ func matchFile(store *symbolStore, symbolizer symbolizer, matcher matcherFunc, r
 }
 
 type symbolStore struct {
-	res [maxSymbols]symbolInformation
+	res []symbolInformation // sorted by decreasing score; unused entries have score 0
+}
+
+// newSymbolStore returns a store of the best limit symbols.
+func newSymbolStore(limit int) *symbolStore {
+	return &symbolStore{res: make([]symbolInformation, limit)}
 }
 
 // store inserts si into the sorted results, if si has a high enough score.
@@ -5,6 +5,7 @@
 package source
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -134,3 +135,37 @@ func TestFiltererDisallow(t *testing.T) {
 		}
 	}
 }
+
+func TestFiltererMatch(t *testing.T) {
+	filterer := NewFilterer([]string{"-gen", "+gen/keep", "-gen/keep/old/"})
+	tests := []struct {
+		path         string
+		wantFilter   string
+		wantExcluded bool
+	}{
+		{"src/a.go", "", false},
+		{"gen/a.go", "-gen", true},
+		{"gen/keep/a.go", "+gen/keep", false},
+		{"gen/keep/old/a.go", "-gen/keep/old", true},
+	}
+	for _, test := range tests {
+		filter, excluded := filterer.Match(test.path)
+		if filter != test.wantFilter || excluded != test.wantExcluded {
+			t.Errorf("Match(%q) = (%q, %t), want (%q, %t)", test.path, filter, excluded, test.wantFilter, test.wantExcluded)
+		}
+	}
+}
+
+func TestSymbolStoreLimit(t *testing.T) {
+	store := newSymbolStore(2)
+	for i, name := range []string{"a", "b", "c", "d"} {
+		store.store(symbolInformation{score: float64(i + 1), symbol: name})
+	}
+	var got []string
+	for _, si := range store.results() {
+		got = append(got, si.Name)
+	}
+	if want := []string{"d", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("results() = %v, want %v", got, want)
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	"golang.org/x/mod/modfile"
 	"golang.org/x/tools/gopls/internal/lsp/protocol"
@@ -52,17 +54,46 @@ func DiagnosticsForWork(ctx context.Context, snapshot source.Snapshot, fh source
 		if pw == nil || len(pw.ParseErrors) == 0 {
 			return nil, err
 		}
-		return pw.ParseErrors, nil
+		// The go.work file may fail to parse only because its go or
+		// toolchain directive is too new for the modfile package, which is
+		// still worth checking against the go command.
+		toolchainDiags, err := toolchainDiagnostics(snapshot, pw)
+		if err != nil {
+			return nil, err
+		}
+		return append(pw.ParseErrors, toolchainDiags...), nil
+	}
+
+	allowed := make(map[string]bool)
+	for _, path := range snapshot.View().Options().AllowedWorkUses {
+		allowed[filepath.Clean(filepath.FromSlash(path))] = true
+	}
+
+	// Warn if the go or toolchain directive requires a newer Go than the
+	// go command.
+	diagnostics, err := toolchainDiagnostics(snapshot, pw)
+	if err != nil {
+		return nil, err
 	}
 
 	// Add diagnostic if a directory does not contain a module.
-	var diagnostics []*source.Diagnostic
 	for _, use := range pw.File.Use {
 		rng, err := pw.Mapper.OffsetRange(use.Syntax.Start.Byte, use.Syntax.End.Byte)
 		if err != nil {
 			return nil, err
 		}
 
+		// Warn about paths that other developers are unlikely to share.
+		if msg := nonPortableUse(snapshot.View().Folder(), pw, use, allowed); msg != "" {
+			diagnostics = append(diagnostics, &source.Diagnostic{
+				URI:      fh.URI(),
+				Range:    rng,
+				Severity: protocol.SeverityWarning,
+				Source:   source.WorkFileError,
+				Message:  msg,
+			})
+		}
+
 		modfh, err := snapshot.GetFile(ctx, modFileURI(pw, use))
 		if err != nil {
 			return nil, err
@@ -80,6 +111,215 @@ func DiagnosticsForWork(ctx context.Context, snapshot source.Snapshot, fh source
 	return diagnostics, nil
 }
 
+// toolchainDiagnostics returns warnings for the go and toolchain directives
+// of the go.work file that require a newer Go than the go command of the view.
+//
+// The modfile package rejects toolchain directives and go directives with a
+// patch version, such as "go 1.21.5", so both are read from the syntax tree
+// of a lenient parse, which does not reject them, and from the raw contents,
+// as the lenient parse truncates such go versions.
+func toolchainDiagnostics(snapshot source.Snapshot, pw *source.ParsedWorkFile) ([]*source.Diagnostic, error) {
+	content := pw.Mapper.Content
+	file, err := modfile.ParseLax(pw.URI.Filename(), content, nil)
+	if err != nil {
+		return nil, nil // the parse errors of ParseWork are reported instead
+	}
+	view := snapshot.View()
+	var diagnostics []*source.Diagnostic
+	for _, stmt := range file.Syntax.Stmt {
+		line, ok := stmt.(*modfile.Line)
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(string(content[line.Start.Byte:line.End.Byte]))
+		if len(fields) != 2 {
+			continue
+		}
+		var version string
+		switch fields[0] {
+		case "go":
+			version = fields[1]
+		case "toolchain":
+			// e.g. "go1.21.5" or "go1.21.5-custom"; "default" requires nothing.
+			if !strings.HasPrefix(fields[1], "go") {
+				continue
+			}
+			version = strings.TrimPrefix(fields[1], "go")
+			if i := strings.IndexByte(version, '-'); i >= 0 {
+				version = version[:i]
+			}
+		default:
+			continue
+		}
+		msg := toolchainMismatch(strings.Join(fields, " "), version, view.GoVersionString(), view.GoVersion())
+		if msg == "" {
+			continue
+		}
+		rng, err := pw.Mapper.OffsetRange(line.Start.Byte, line.End.Byte)
+		if err != nil {
+			return nil, err
+		}
+		diagnostics = append(diagnostics, &source.Diagnostic{
+			URI:      pw.URI,
+			Range:    rng,
+			Severity: protocol.SeverityWarning,
+			Source:   source.WorkFileError,
+			Message:  msg,
+		})
+	}
+	return diagnostics, nil
+}
+
+// toolchainMismatch returns a message describing the effect of the go.work
+// directive requiring Go version, such as "1.21", "1.21rc1" or "1.21.5", on
+// the go command, or "" if the go command is recent enough. The go command
+// is described by its full version goCmd, such as "go1.21.0", if known, and
+// by its Go minor version goMinor.
+//
+// From Go 1.21, the go command switches to (and may download) a newer
+// toolchain when the go.work file requires one; earlier versions refuse to
+// use the workspace.
+func toolchainMismatch(directive, version, goCmd string, goMinor int) string {
+	if goMinor <= 0 {
+		return ""
+	}
+	required, ok := parseGoVersion(version)
+	if !ok {
+		return ""
+	}
+	if cmd, ok := parseGoVersion(strings.TrimPrefix(goCmd, "go")); ok && cmd.minor == goMinor {
+		if compareGoVersions(required, cmd) <= 0 {
+			return ""
+		}
+	} else {
+		// Compare only the major and minor versions, which is all that
+		// is known of the go command.
+		goCmd = fmt.Sprintf("go1.%d", goMinor)
+		if required.major < 1 || required.major == 1 && required.minor <= goMinor {
+			return ""
+		}
+	}
+	if goMinor >= 21 {
+		return fmt.Sprintf("go.work requires %s, so the go command (%s) will switch to a newer toolchain, downloading it if necessary", directive, goCmd)
+	}
+	return fmt.Sprintf("go.work requires %s, but the go command is %s", directive, goCmd)
+}
+
+// A goVersion is a parsed Go version, such as 1.21, 1.21rc1, or 1.21.5.
+type goVersion struct {
+	major, minor int
+	patch        int    // -1 if absent
+	kind         string // "", "alpha", "beta", or "rc"
+	pre          int    // the number of the prerelease
+}
+
+// parseGoVersion parses a Go version without its "go" prefix.
+func parseGoVersion(s string) (goVersion, bool) {
+	v := goVersion{patch: -1}
+	num := func() (int, bool) {
+		end := 0
+		for end < len(s) && '0' <= s[end] && s[end] <= '9' {
+			end++
+		}
+		if end == 0 {
+			return 0, false
+		}
+		n, err := strconv.Atoi(s[:end])
+		s = s[end:]
+		return n, err == nil
+	}
+	var ok bool
+	if v.major, ok = num(); !ok || !strings.HasPrefix(s, ".") {
+		return v, false
+	}
+	s = s[1:]
+	if v.minor, ok = num(); !ok {
+		return v, false
+	}
+	switch {
+	case s == "":
+	case strings.HasPrefix(s, "."):
+		s = s[1:]
+		if v.patch, ok = num(); !ok || s != "" {
+			return v, false
+		}
+	default:
+		for _, kind := range []string{"alpha", "beta", "rc"} {
+			if strings.HasPrefix(s, kind) {
+				v.kind = kind
+				s = s[len(kind):]
+				if v.pre, ok = num(); !ok || s != "" {
+					return v, false
+				}
+				return v, true
+			}
+		}
+		return v, false
+	}
+	if v.major == 1 && v.minor < 21 && v.patch < 0 {
+		v.patch = 0 // before Go 1.21, "1.20" denotes the release 1.20.0
+	}
+	return v, true
+}
+
+// compareGoVersions returns -1, 0, or +1 as x is older than, the same as,
+// or newer than y. A language version such as 1.21 precedes its
+// prereleases, such as 1.21rc1, which precede its releases, such as 1.21.0.
+func compareGoVersions(x, y goVersion) int {
+	cmp := func(a, b int) int {
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return +1
+		}
+		return 0
+	}
+	rank := func(v goVersion) int {
+		switch {
+		case v.patch >= 0:
+			return 4
+		case v.kind == "rc":
+			return 3
+		case v.kind == "beta":
+			return 2
+		case v.kind == "alpha":
+			return 1
+		}
+		return 0 // language version
+	}
+	if c := cmp(x.major, y.major); c != 0 {
+		return c
+	}
+	if c := cmp(x.minor, y.minor); c != 0 {
+		return c
+	}
+	if c := cmp(rank(x), rank(y)); c != 0 {
+		return c
+	}
+	if c := cmp(x.patch, y.patch); c != 0 {
+		return c
+	}
+	return cmp(x.pre, y.pre)
+}
+
+// nonPortableUse returns a message describing why the use directive is
+// unlikely to work for other developers of the workspace folder, or "" if
+// it is portable or its path is allowed.
+func nonPortableUse(folder span.URI, pw *source.ParsedWorkFile, use *modfile.Use, allowed map[string]bool) string {
+	path := filepath.FromSlash(use.Path)
+	if allowed[filepath.Clean(path)] {
+		return ""
+	}
+	if filepath.IsAbs(path) {
+		return fmt.Sprintf("use of absolute path %v; prefer a path relative to the go.work file", use.Path)
+	}
+	if !source.InDir(folder.Filename(), span.Dir(modFileURI(pw, use)).Filename()) {
+		return fmt.Sprintf("%v is outside of the workspace folder", use.Path)
+	}
+	return ""
+}
+
 func modFileURI(pw *source.ParsedWorkFile, use *modfile.Use) span.URI {
 	workdir := filepath.Dir(pw.URI.Filename())
 
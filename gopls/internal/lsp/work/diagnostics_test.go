@@ -0,0 +1,147 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package work
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/gopls/internal/lsp/protocol"
+	"golang.org/x/tools/gopls/internal/lsp/source"
+	"golang.org/x/tools/gopls/internal/span"
+)
+
+func TestToolchainMismatch(t *testing.T) {
+	for _, test := range []struct {
+		version string
+		goCmd   string // full version of the go command; empty => unknown
+		goMinor int
+		want    string // substring of the message; empty => no message
+	}{
+		{"1.20", "go1.21.0", 21, ""},
+		{"1.21", "go1.21.0", 21, ""},
+		{"1.21.0", "go1.21.0", 21, ""},
+		{"1.21rc1", "go1.21.0", 21, ""},
+		{"1.21.3", "go1.21.3", 21, ""},
+		{"1.20", "go1.20", 20, ""},
+		{"1.21", "", 0, ""},
+		{"", "go1.20", 20, ""},
+		{"1.x", "go1.20", 20, ""},
+		{"1.21.5", "go1.21.0", 21, "(go1.21.0) will switch to a newer toolchain"},
+		{"1.21.0", "go1.21rc2", 21, "will switch to a newer toolchain"},
+		{"1.21rc3", "go1.21rc2", 21, "will switch to a newer toolchain"},
+		{"1.21", "go1.20.5", 20, "but the go command is go1.20.5"},
+		{"1.21.0", "go1.20", 20, "but the go command is go1.20"},
+		{"1.21rc1", "go1.20.1", 20, "but the go command is go1.20.1"},
+		{"1.100", "go1.20", 20, "but the go command is go1.20"},
+		{"1.22", "go1.21.5", 21, "will switch to a newer toolchain"},
+		{"1.22.1", "go1.21.5", 21, "will switch to a newer toolchain"},
+		{"1.22rc2", "go1.21.5", 21, "will switch to a newer toolchain"},
+
+		// Without the full version of the go command, such as for a
+		// development build, only major and minor versions are compared.
+		{"1.21.5", "devel go1.21-abcdef", 21, ""},
+		{"1.21.5", "", 21, ""},
+		{"1.22", "", 21, "(go1.21) will switch to a newer toolchain"},
+		{"1.21", "", 20, "but the go command is go1.20"},
+	} {
+		got := toolchainMismatch("go "+test.version, test.version, test.goCmd, test.goMinor)
+		if test.want == "" && got != "" || !strings.Contains(got, test.want) {
+			t.Errorf("toolchainMismatch(%q, %q, %d) = %q, want %q", test.version, test.goCmd, test.goMinor, got, test.want)
+		}
+	}
+}
+
+func TestToolchainDiagnostics(t *testing.T) {
+	for _, test := range []struct {
+		name, content string
+		want          []string // directive of each diagnostic
+	}{
+		{"go", "go 1.22\n", []string{"go 1.22"}},
+		{"patch", "go 1.21.5\n", []string{"go 1.21.5"}},
+		{"toolchain", "go 1.21\ntoolchain go1.21.5\n", []string{"toolchain go1.21.5"}},
+		{"custom toolchain", "go 1.21\ntoolchain go1.22.1-custom\n", []string{"toolchain go1.22.1-custom"}},
+		{"default toolchain", "go 1.21\ntoolchain default\n", nil},
+		{"both", "go 1.21.2\n\ntoolchain go1.22.0\n\nuse ./a\n", []string{"go 1.21.2", "toolchain go1.22.0"}},
+		{"up to date", "go 1.21.0\ntoolchain go1.21.0\n", nil},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			uri := span.URIFromPath(filepath.Join(t.TempDir(), "go.work"))
+			pw := &source.ParsedWorkFile{
+				URI:    uri,
+				Mapper: protocol.NewMapper(uri, []byte(test.content)),
+			}
+			diags, err := toolchainDiagnostics(fakeSnapshot{view: fakeView{goVersion: "go1.21.0", goMinor: 21}}, pw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, d := range diags {
+				if !strings.Contains(d.Message, "(go1.21.0) will switch") {
+					t.Errorf("unexpected message %q", d.Message)
+				}
+				got = append(got, strings.Split(test.content, "\n")[d.Range.Start.Line])
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("diagnostics on lines %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// fakeSnapshot and fakeView provide just the go command version to
+// toolchainDiagnostics.
+type fakeSnapshot struct {
+	source.Snapshot
+	view fakeView
+}
+
+func (s fakeSnapshot) View() source.View { return s.view }
+
+type fakeView struct {
+	source.View
+	goVersion string
+	goMinor   int
+}
+
+func (v fakeView) GoVersion() int          { return v.goMinor }
+func (v fakeView) GoVersionString() string { return v.goVersion }
+
+func TestNonPortableUse(t *testing.T) {
+	folder := t.TempDir()
+	abs := filepath.ToSlash(filepath.Join(t.TempDir(), "abs"))
+	allowed := make(map[string]bool)
+	for _, path := range []string{"../allowed/", abs + "/allowed"} {
+		allowed[filepath.Clean(filepath.FromSlash(path))] = true // as in Diagnostics
+	}
+
+	for _, test := range []struct {
+		workDir, path string
+		want          string // substring of the message; empty => no message
+	}{
+		{".", "./a", ""},
+		{".", "a/b", ""},
+		{".", ".", ""},
+		{"sub", "../a", ""},
+		{".", abs, "use of absolute path"},
+		{".", "../sibling", "is outside of the workspace folder"},
+		{".", "./a/../../sibling", "is outside of the workspace folder"},
+		{"sub", "../../sibling", "is outside of the workspace folder"},
+		{".", "../allowed", ""},
+		{".", abs + "/allowed/", ""},
+	} {
+		pw := &source.ParsedWorkFile{
+			URI: span.URIFromPath(filepath.Join(folder, test.workDir, "go.work")),
+		}
+		use := &modfile.Use{Path: test.path}
+		got := nonPortableUse(span.URIFromPath(folder), pw, use, allowed)
+		if test.want == "" && got != "" || !strings.Contains(got, test.want) {
+			t.Errorf("nonPortableUse(%s, %q) = %q, want %q", test.workDir, test.path, got, test.want)
+		}
+	}
+}
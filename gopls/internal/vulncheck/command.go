@@ -250,6 +250,8 @@ func vulnerablePackages(ctx context.Context, snapshot source.Snapshot, modfile s
 		goVersion = snapshot.View().GoVersionString()
 	}
 	group.SetLimit(10)
+	report := progressReporter(ctx)
+	done := 0 // number of modules checked, guarded by mu
 	stdlibModule := &packages.Module{
 		Path:    "stdlib",
 		Version: goVersion,
@@ -257,6 +259,12 @@ func vulnerablePackages(ctx context.Context, snapshot source.Snapshot, modfile s
 	for path, mds := range metadataByModule {
 		path, mds := path, mds
 		group.Go(func() error {
+			defer func() {
+				mu.Lock()
+				done++
+				report(float64(done) / float64(len(metadataByModule)))
+				mu.Unlock()
+			}()
 			effectiveModule := stdlibModule
 			if m := mds[0].Module; m != nil {
 				effectiveModule = m
@@ -23,3 +23,20 @@ var Main func(cfg packages.Config, patterns ...string) error = nil
 // apply to this snapshot. The result contains a set of packages,
 // grouped by vuln ID and by module.
 var VulnerablePackages func(ctx context.Context, snapshot source.Snapshot, modfile source.FileHandle) (*govulncheck.Result, error) = nil
+
+type progressKey struct{}
+
+// WithProgress returns a context that causes VulnerablePackages to report
+// the fraction of its work that has completed to report.
+func WithProgress(ctx context.Context, report func(fraction float64)) context.Context {
+	return context.WithValue(ctx, progressKey{}, report)
+}
+
+// progressReporter returns the progress reporting function of ctx, or a
+// no-op function if there is none.
+func progressReporter(ctx context.Context) func(fraction float64) {
+	if report, ok := ctx.Value(progressKey{}).(func(float64)); ok {
+		return report
+	}
+	return func(float64) {}
+}
@@ -26,27 +26,131 @@ import (
 
 // An Runner will run go command invocations and serialize
 // them if it sees a concurrency error.
+//
+// The number of concurrent invocations is bounded; see SetMaxConcurrency.
 type Runner struct {
 	// once guards the runner initialization.
 	once sync.Once
 
-	// inFlight tracks available workers.
-	inFlight chan struct{}
+	// mu guards the fields below.
+	mu sync.Mutex
+
+	// maxInFlight bounds the number of concurrent invocations.
+	// If zero, defaultMaxInFlight is used.
+	maxInFlight int
+
+	inFlight int // number of running concurrent invocations
+	queued   int // number of invocations waiting to run
 
-	// serialized guards the ability to run a go command serially,
-	// to avoid deadlocks when claiming workers.
-	serialized chan struct{}
+	// serialized is set while a go command runs serially, and
+	// serialWaiters counts the serial invocations waiting to run.
+	// Concurrent invocations are not started while either is non-zero.
+	serialized    bool
+	serialWaiters int
+
+	// changed is closed, and replaced, whenever the fields above
+	// change in a way that may allow a waiting invocation to run.
+	changed chan struct{}
 }
 
-const maxInFlight = 10
+const defaultMaxInFlight = 10
 
 func (runner *Runner) initialize() {
 	runner.once.Do(func() {
-		runner.inFlight = make(chan struct{}, maxInFlight)
-		runner.serialized = make(chan struct{}, 1)
+		runner.changed = make(chan struct{})
 	})
 }
 
+// SetMaxConcurrency sets the maximum number of go command invocations that
+// the runner runs concurrently. If n is not positive, a default is used.
+//
+// It may be called at any time. Invocations that are already running are
+// not affected; if the new bound is lower, new invocations wait until
+// enough of them finish.
+func (runner *Runner) SetMaxConcurrency(n int) {
+	runner.initialize()
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+	if n < 0 {
+		n = 0
+	}
+	runner.maxInFlight = n
+	runner.notifyLocked()
+}
+
+// Stats reports the number of go command invocations that are running, and
+// the number that are waiting to run.
+func (runner *Runner) Stats() (active, queued int) {
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+	active = runner.inFlight
+	if runner.serialized {
+		active++
+	}
+	return active, runner.queued
+}
+
+// notifyLocked wakes up the invocations waiting to run.
+// runner.mu must be held.
+func (runner *Runner) notifyLocked() {
+	close(runner.changed)
+	runner.changed = make(chan struct{})
+}
+
+// acquire waits until an invocation may run, either concurrently with
+// others or, if serial is set, alone. It returns a function that must be
+// called when the invocation has completed.
+func (runner *Runner) acquire(ctx context.Context, serial bool) (release func(), err error) {
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+
+	runner.queued++
+	defer func() { runner.queued-- }()
+	if serial {
+		runner.serialWaiters++
+		defer func() { runner.serialWaiters-- }()
+	}
+
+	for {
+		max := runner.maxInFlight
+		if max == 0 {
+			max = defaultMaxInFlight
+		}
+		switch {
+		case runner.serialized:
+			// Wait for the serial invocation to complete.
+		case serial && runner.inFlight == 0:
+			runner.serialized = true
+			return func() {
+				runner.mu.Lock()
+				defer runner.mu.Unlock()
+				runner.serialized = false
+				runner.notifyLocked()
+			}, nil
+		case !serial && runner.serialWaiters == 0 && runner.inFlight < max:
+			runner.inFlight++
+			return func() {
+				runner.mu.Lock()
+				defer runner.mu.Unlock()
+				runner.inFlight--
+				runner.notifyLocked()
+			}, nil
+		}
+
+		changed := runner.changed
+		runner.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-changed:
+		}
+		runner.mu.Lock()
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
 // 1.13: go: updates to go.mod needed, but contents have changed
 // 1.14: go: updating go.mod: existing contents have changed since last read
 var modConcurrencyError = regexp.MustCompile(`go:.*go.mod.*contents have changed`)
@@ -89,12 +193,11 @@ func (runner *Runner) RunRaw(ctx context.Context, inv Invocation) (*bytes.Buffer
 
 func (runner *Runner) runConcurrent(ctx context.Context, inv Invocation) (*bytes.Buffer, *bytes.Buffer, error, error) {
 	// Wait for 1 worker to become available.
-	select {
-	case <-ctx.Done():
-		return nil, nil, nil, ctx.Err()
-	case runner.inFlight <- struct{}{}:
-		defer func() { <-runner.inFlight }()
+	release, err := runner.acquire(ctx, false)
+	if err != nil {
+		return nil, nil, nil, err
 	}
+	defer release()
 
 	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
 	friendlyErr, err := inv.runWithFriendlyError(ctx, stdout, stderr)
@@ -105,26 +208,14 @@ func (runner *Runner) runPiped(ctx context.Context, inv Invocation, stdout, stde
 	// Make sure the runner is always initialized.
 	runner.initialize()
 
-	// Acquire the serialization lock. This avoids deadlocks between two
-	// runPiped commands.
-	select {
-	case <-ctx.Done():
-		return nil, ctx.Err()
-	case runner.serialized <- struct{}{}:
-		defer func() { <-runner.serialized }()
-	}
-
 	// Wait for all in-progress go commands to return before proceeding,
-	// to avoid load concurrency errors.
-	for i := 0; i < maxInFlight; i++ {
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		case runner.inFlight <- struct{}{}:
-			// Make sure we always "return" any workers we took.
-			defer func() { <-runner.inFlight }()
-		}
+	// to avoid load concurrency errors. No other go command starts until
+	// this one completes.
+	release, err := runner.acquire(ctx, true)
+	if err != nil {
+		return nil, err
 	}
+	defer release()
 
 	return inv.runWithFriendlyError(ctx, stdout, stderr)
 }
@@ -0,0 +1,78 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gocommand
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRunnerConcurrency(t *testing.T) {
+	ctx := context.Background()
+	runner := &Runner{}
+	runner.initialize()
+	runner.SetMaxConcurrency(2)
+
+	var releases []func()
+	for i := 0; i < 2; i++ {
+		release, err := runner.acquire(ctx, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		releases = append(releases, release)
+	}
+
+	// A third invocation must wait.
+	acquired := make(chan func())
+	go func() {
+		release, err := runner.acquire(ctx, false)
+		if err != nil {
+			t.Error(err)
+		}
+		acquired <- release
+	}()
+	waitFor(t, func() bool {
+		active, queued := runner.Stats()
+		return active == 2 && queued == 1
+	})
+
+	// Raising the bound lets it run.
+	runner.SetMaxConcurrency(3)
+	releases = append(releases, <-acquired)
+	if active, queued := runner.Stats(); active != 3 || queued != 0 {
+		t.Errorf("Stats() = %d, %d, want 3, 0", active, queued)
+	}
+
+	// A serial invocation waits for all others to complete.
+	ctx2, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := runner.acquire(ctx2, true); err == nil {
+		t.Fatal("serial acquire succeeded while invocations were running")
+	}
+	for _, release := range releases {
+		release()
+	}
+	release, err := runner.acquire(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if active, queued := runner.Stats(); active != 1 || queued != 0 {
+		t.Errorf("Stats() = %d, %d, want 1, 0", active, queued)
+	}
+	release()
+}
+
+// waitFor polls cond until it returns true, failing the test after a while.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for i := 0; i < 1000; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("condition not met")
+}